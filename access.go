@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// openPaths are reachable without access control: the probes and metrics which the platform calls, the
+// public keys and bucket and uploaded schemas which the runner fetches, and the admin endpoints which have their own ADMIN_TOKEN
+var openPaths = []string{"/status", "/healthcheck", "/ready", "/metrics", "/.well-known/jwks.json"}
+
+func isOpenPath(path string) bool {
+	for _, openPath := range openPaths {
+		if path == openPath {
+			return true
+		}
+	}
+	return strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, "/bucket-schemas/") || strings.HasPrefix(path, "/uploaded-schemas/")
+}
+
+// requireAccess guards the launcher with HTTP basic auth when BASIC_AUTH_USERNAME and BASIC_AUTH_PASSWORD
+// are set, and with a header set by an authenticating proxy, such as an OIDC proxy, when AUTH_PROXY_HEADER
+// is set. Anyone who can reach the launcher can otherwise mint valid runner tokens.
+func requireAccess(next http.Handler) http.Handler {
+	username := settings.Get("BASIC_AUTH_USERNAME")
+	password := settings.Get("BASIC_AUTH_PASSWORD")
+	proxyHeader := settings.Get("AUTH_PROXY_HEADER")
+
+	var allowedUsers []string
+	for _, user := range strings.Split(settings.Get("AUTH_PROXY_ALLOWED_USERS"), ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			allowedUsers = append(allowedUsers, user)
+		}
+	}
+
+	basicAuth := username != "" && password != ""
+	if !basicAuth && proxyHeader == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isOpenPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if proxyHeader != "" && !isAllowedProxyUser(r.Header.Get(proxyHeader), allowedUsers) {
+			logging.Warn("Request without an allowed authenticated user refused", "path", r.URL.Path, "header", proxyHeader)
+			http.Error(w, http.StatusText(403), 403)
+			return
+		}
+
+		if basicAuth {
+			requestUsername, requestPassword, ok := r.BasicAuth()
+			usernameMatches := subtle.ConstantTimeCompare([]byte(requestUsername), []byte(username)) == 1
+			passwordMatches := subtle.ConstantTimeCompare([]byte(requestPassword), []byte(password)) == 1
+			if !ok || !usernameMatches || !passwordMatches {
+				w.Header().Set("WWW-Authenticate", `Basic realm="eq-questionnaire-launcher", charset="UTF-8"`)
+				http.Error(w, http.StatusText(401), 401)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isAllowedProxyUser reports whether the user named by the proxy header is allowed: any user when no users
+// are listed, otherwise one listed exactly or, for an entry starting with @, any user of that email domain
+func isAllowedProxyUser(user string, allowedUsers []string) bool {
+	if user == "" {
+		return false
+	}
+	if len(allowedUsers) == 0 {
+		return true
+	}
+
+	for _, allowed := range allowedUsers {
+		if strings.HasPrefix(allowed, "@") && strings.HasSuffix(strings.ToLower(user), strings.ToLower(allowed)) {
+			return true
+		}
+		if strings.EqualFold(user, allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/reload"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// requireAdmin guards admin handlers with the ADMIN_TOKEN bearer token, hiding them entirely when it is unset
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminToken := settings.Get("ADMIN_TOKEN")
+		if adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			http.Error(w, http.StatusText(401), 401)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func postReloadHandler(w http.ResponseWriter, r *http.Request) {
+	result := reload.All()
+	logReloadResult(result)
+
+	status := 200
+	if len(result.Errors) > 0 {
+		status = 500
+	}
+	writeJSON(w, status, result)
+}
+
+func logReloadResult(result reload.Result) {
+	for _, name := range result.Reloaded {
+		logging.Info("Reloaded", "name", name)
+	}
+	for name, err := range result.Errors {
+		logging.Warn("Failed to reload, keeping current configuration", "name", name, "err", err)
+	}
+}
+
+// reloadOnSIGHUP reloads the on-disk configuration whenever the process receives SIGHUP
+func reloadOnSIGHUP() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			logging.Info("SIGHUP received, reloading configuration")
+			logReloadResult(reload.All())
+		}
+	}()
+}
+
+// reloadOnFileChange polls the registered config files, including the keys, every CONFIG_WATCH_SECONDS and
+// reloads the configuration when any of them changes, so that keys mounted from a secret store can be
+// rotated without a restart. It does nothing when CONFIG_WATCH_SECONDS is unset or zero.
+func reloadOnFileChange() {
+	seconds, err := strconv.Atoi(settings.Get("CONFIG_WATCH_SECONDS"))
+	if err != nil || seconds <= 0 {
+		return
+	}
+
+	logging.Info("Watching config files for changes", "interval_seconds", seconds, "files", strings.Join(reload.Files(), ","))
+	configModified := lastModified(reload.Files())
+
+	go func() {
+		for range time.Tick(time.Duration(seconds) * time.Second) {
+			if modified := lastModified(reload.Files()); !modified.Equal(configModified) {
+				configModified = modified
+				logging.Info("Config files changed, reloading configuration")
+				logReloadResult(reload.All())
+			}
+		}
+	}()
+}
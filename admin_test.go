@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/reload"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+func TestPostReloadHandler(t *testing.T) {
+	var reloadErr error
+	reload.Register("test", func() error { return reloadErr })
+	t.Cleanup(func() { reload.Unregister("test") })
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"every reload succeeds", nil, 200},
+		{"a reload fails", errors.New("invalid configuration"), 500},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reloadErr = test.err
+			recorder := httptest.NewRecorder()
+
+			postReloadHandler(recorder, httptest.NewRequest("POST", "/admin/reload", nil))
+
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body.String())
+			}
+
+			var result reload.Result
+			if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if test.err == nil && !contains(result.Reloaded, "test") {
+				t.Errorf("reloaded = %v, want test", result.Reloaded)
+			}
+			if test.err != nil && result.Errors["test"] != test.err.Error() {
+				t.Errorf("errors = %v, want test: %v", result.Errors, test.err)
+			}
+		})
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/authentication"
+)
+
+// The codes of API errors, which clients may rely on where the messages may change
+const (
+	errorInvalidRequest      = "invalid_request"
+	errorInvalidLaunchValues = "invalid_launch_values"
+	errorRequestTooLarge     = "request_too_large"
+	errorUnauthorized        = "unauthorized"
+	errorForbidden           = "forbidden"
+	errorNotFound            = "not_found"
+	errorRateLimited         = "rate_limited"
+	errorTokenFailed         = "token_generation_failed"
+	errorDecodeFailed        = "decode_failed"
+	errorLaunchFailed        = "launch_verification_failed"
+	errorInternal            = "internal_error"
+)
+
+// apiError is the error object of every error response from the JSON API
+type apiError struct {
+	Code    string                      `json:"code"`
+	Message string                      `json:"message"`
+	Fields  []authentication.FieldError `json:"fields,omitempty"`
+}
+
+// writeAPIError responds with {"error": {"code", "message", "fields"}}
+func writeAPIError(w http.ResponseWriter, status int, code string, message string, fields ...authentication.FieldError) {
+	writeJSON(w, status, map[string]interface{}{"error": apiError{Code: code, Message: message, Fields: fields}})
+}
+
+// getOpenAPIHandler serves the OpenAPI 3 description of the JSON API
+func getOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	http.ServeFile(w, r, "static/openapi.json")
+}
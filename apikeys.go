@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/audit"
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/gorilla/mux"
+)
+
+// apiKeyHeader carries the API key of a programmatic client
+const apiKeyHeader = "X-API-Key"
+
+type apiKeyNameContextKey struct{}
+
+// apiKey is one of the API_KEYS, named for the team or suite which uses it
+type apiKey struct {
+	Name string
+	Key  string
+}
+
+// apiKeys reads API_KEYS, a comma separated list of name:key pairs
+func apiKeys() ([]apiKey, error) {
+	var keys []apiKey
+	names := make(map[string]bool)
+	for _, pair := range strings.Split(settings.Get("API_KEYS"), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, errors.New("API_KEYS entries must be name:key pairs")
+		}
+		name := strings.TrimSpace(parts[0])
+		if names[name] {
+			return nil, fmt.Errorf("API_KEYS names must be unique, %s is repeated", name)
+		}
+		names[name] = true
+		keys = append(keys, apiKey{Name: name, Key: strings.TrimSpace(parts[1])})
+	}
+	return keys, nil
+}
+
+// apiKeyName is the name of the API key a request was made with, if any
+func apiKeyName(ctx context.Context) string {
+	name, _ := ctx.Value(apiKeyNameContextKey{}).(string)
+	return name
+}
+
+// requireAPIKey guards the JSON endpoints which issue tokens with the API_KEYS keys, sent in the X-API-Key header,
+// so that every token can be traced to the client it was issued to. It does nothing when API_KEYS is unset.
+func requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := apiKeys()
+		if err != nil {
+			writeAPIError(w, 500, errorInternal, err.Error())
+			return
+		}
+		if len(keys) == 0 {
+			next(w, r)
+			return
+		}
+
+		requestKey := r.Header.Get(apiKeyHeader)
+		name := ""
+		for _, key := range keys {
+			if subtle.ConstantTimeCompare([]byte(requestKey), []byte(key.Key)) == 1 {
+				name = key.Name
+			}
+		}
+		if name == "" {
+			logging.Warn("Request without a valid API key refused", "path", r.URL.Path, "client", clientIP(r))
+			writeAPIError(w, 401, errorUnauthorized, "A valid API key is required in the "+apiKeyHeader+" header")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), apiKeyNameContextKey{}, name)))
+	}
+}
+
+// auditCaller identifies who made a request for the audit log: the name of its API key, otherwise the user named
+// by the authenticating proxy or basic auth, otherwise its IP address
+func auditCaller(r *http.Request) string {
+	if name := apiKeyName(r.Context()); name != "" {
+		return "key:" + name
+	}
+	if header := settings.Get("AUTH_PROXY_HEADER"); header != "" && r.Header.Get(header) != "" {
+		return "user:" + r.Header.Get(header)
+	}
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		return "user:" + username
+	}
+	return "ip:" + clientIP(r)
+}
+
+// recordAudit adds a token issued, or a launch link created, by any request to the audit log
+func recordAudit(r *http.Request, schemaName string, txID string) {
+	endpoint := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			endpoint = template
+		}
+	}
+	audit.Record(auditCaller(r), r.Method+" "+endpoint, schemaName, txID)
+}
+
+// getAuditHandler lists the audit log newest first, narrowed by the caller, schema_name, tx_id, since and limit
+// query parameters
+func getAuditHandler(w http.ResponseWriter, r *http.Request) {
+	query := audit.Query{
+		Caller:     r.URL.Query().Get("caller"),
+		SchemaName: r.URL.Query().Get("schema_name"),
+		TxID:       r.URL.Query().Get("tx_id"),
+	}
+
+	if since := r.URL.Query().Get("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			writeAPIError(w, 400, errorInvalidRequest, "since must be an RFC 3339 time, such as 2024-01-31T18:00:00Z")
+			return
+		}
+		query.Since = parsed
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 {
+			writeAPIError(w, 400, errorInvalidRequest, "limit must be a positive number")
+			return
+		}
+		query.Limit = parsed
+	}
+
+	writeJSON(w, 200, audit.List(query))
+}
@@ -0,0 +1,190 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/ONSdigital/eq-questionnaire-launcher/storage"
+)
+
+// Entry is a request to an API endpoint which issues tokens, recording who made it and, for each token, its
+// schema and tx_id
+type Entry struct {
+	ID         int       `json:"id"`
+	Time       time.Time `json:"time"`
+	Caller     string    `json:"caller"`
+	Endpoint   string    `json:"endpoint"`
+	SchemaName string    `json:"schema_name,omitempty"`
+	TxID       string    `json:"tx_id,omitempty"`
+}
+
+// Query narrows the entries listed to those matching every field which is set
+type Query struct {
+	Caller     string
+	SchemaName string
+	TxID       string
+	Since      time.Time
+	Limit      int
+}
+
+// auditList and auditIDKey hold the entries and the last ID given to one in the state backend
+const (
+	auditList  = "audit"
+	auditIDKey = "audit_id"
+)
+
+var (
+	// auditMutex serialises appends to AUDIT_LOG_PATH so that the lines of concurrent requests don't interleave
+	auditMutex sync.Mutex
+	loadOnce   sync.Once
+)
+
+// Record adds an entry to the audit log. Entries are never changed or removed, though only the last
+// AUDIT_LOG_SIZE are kept for listing; AUDIT_LOG_PATH, when set, keeps every one.
+func Record(caller string, endpoint string, schemaName string, txID string) {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+	loadOnce.Do(readAuditLog)
+
+	backend := storage.Default()
+	id, err := backend.Incr(auditIDKey)
+	if err != nil {
+		logging.Warn("Failed to record audit entry", "caller", caller, "endpoint", endpoint, "tx_id", txID, "err", err)
+		return
+	}
+
+	data, err := json.Marshal(Entry{
+		ID:         int(id),
+		Time:       time.Now().UTC(),
+		Caller:     caller,
+		Endpoint:   endpoint,
+		SchemaName: schemaName,
+		TxID:       txID,
+	})
+	if err == nil {
+		err = backend.Append(auditList, data, auditLogSize())
+	}
+	if err != nil {
+		logging.Warn("Failed to record audit entry", "caller", caller, "endpoint", endpoint, "tx_id", txID, "err", err)
+		return
+	}
+
+	if err := appendAuditLog(data); err != nil {
+		logging.Warn("Failed to write audit log", "path", settings.Get("AUDIT_LOG_PATH"), "err", err)
+	}
+}
+
+// List returns the kept entries matching the query, newest first
+func List(query Query) []Entry {
+	auditMutex.Lock()
+	loadOnce.Do(readAuditLog)
+	auditMutex.Unlock()
+
+	values, err := storage.Default().Range(auditList)
+	if err != nil {
+		logging.Warn("Failed to read audit log", "err", err)
+		return []Entry{}
+	}
+
+	entries := []Entry{}
+	for i := len(values) - 1; i >= 0; i-- {
+		if query.Limit > 0 && len(entries) >= query.Limit {
+			break
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(values[i], &entry); err != nil {
+			logging.Warn("Ignoring unreadable audit entry", "err", err)
+			continue
+		}
+		if query.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (q Query) matches(entry Entry) bool {
+	return (q.Caller == "" || entry.Caller == q.Caller) &&
+		(q.SchemaName == "" || entry.SchemaName == q.SchemaName) &&
+		(q.TxID == "" || entry.TxID == q.TxID) &&
+		(q.Since.IsZero() || !entry.Time.Before(q.Since))
+}
+
+// auditLogSize is the number of entries kept for listing from AUDIT_LOG_SIZE
+func auditLogSize() int {
+	size, err := strconv.Atoi(settings.Get("AUDIT_LOG_SIZE"))
+	if err != nil || size <= 0 {
+		return 10000
+	}
+	return size
+}
+
+// appendAuditLog adds an entry to the end of AUDIT_LOG_PATH as a line of JSON, so that the file is only
+// ever appended to
+func appendAuditLog(data []byte) error {
+	path := settings.Get("AUDIT_LOG_PATH")
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readAuditLog loads the last AUDIT_LOG_SIZE entries of AUDIT_LOG_PATH, so that they can still be listed after a
+// restart. A shared state backend keeps the entries itself, so they are not loaded from the file.
+func readAuditLog() {
+	path := settings.Get("AUDIT_LOG_PATH")
+	if path == "" || storage.Shared() {
+		return
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		logging.Warn("Failed to read audit log", "path", path, "err", err)
+		return
+	}
+	defer file.Close()
+
+	backend := storage.Default()
+	lastID := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			logging.Warn("Ignoring unreadable audit entry", "path", path, "err", err)
+			continue
+		}
+		if err := backend.Append(auditList, scanner.Bytes(), auditLogSize()); err != nil {
+			logging.Warn("Failed to load audit log", "path", path, "err", err)
+			return
+		}
+		if entry.ID > lastID {
+			lastID = entry.ID
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logging.Warn("Failed to read audit log", "path", path, "err", err)
+	}
+
+	if err := backend.Put(auditIDKey, []byte(strconv.Itoa(lastID)), 0); err != nil {
+		logging.Warn("Failed to load audit log", "path", path, "err", err)
+	}
+}
@@ -0,0 +1,71 @@
+package authentication
+
+import (
+	"net/url"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+
+	"github.com/gofrs/uuid"
+)
+
+// validateAccountID checks the account_id claim of a launch from an authenticated respondent account.
+//
+// account_id identifies the respondent's account, whereas user_id identifies who (or what) launched
+// the survey. When both are supplied they should agree, so a differing user_id is logged as a warning.
+func validateAccountID(claims map[string]interface{}) *TokenError {
+	accountID, ok := claims["account_id"].(string)
+	if !ok || accountID == "" {
+		return nil
+	}
+
+	if _, err := uuid.FromString(accountID); err != nil {
+		return &TokenError{Desc: "account_id must be a UUID: " + accountID, From: err,
+			Fields: []FieldError{{Field: "account_id", Error: "must be a UUID"}}}
+	}
+
+	if userID, ok := claims["user_id"].(string); ok && userID != "" && userID != "UNKNOWN" && userID != accountID {
+		logging.Warn("account_id and user_id both supplied and differ")
+		logging.Sensitive("Differing account_id and user_id", "account_id", accountID, "user_id", userID)
+	}
+
+	return nil
+}
+
+// accountServiceURLSettings are the settings giving the account service URL claims when a launch leaves them out
+var accountServiceURLSettings = map[string]string{
+	"account_service_url":         "ACCOUNT_SERVICE_URL",
+	"account_service_log_out_url": "ACCOUNT_SERVICE_LOG_OUT_URL",
+}
+
+// applyAccountServiceURLs sets the account_service_url and account_service_log_out_url claims, which drive the
+// runner's sign out and "back to your account" links, from ACCOUNT_SERVICE_URL and ACCOUNT_SERVICE_LOG_OUT_URL
+// when the launch leaves them out, and checks they are absolute http or https URLs
+func applyAccountServiceURLs(claims map[string]interface{}) *TokenError {
+	var fields []FieldError
+	var desc string
+
+	for _, name := range []string{"account_service_url", "account_service_log_out_url"} {
+		value, _ := claims[name].(string)
+		if value == "" {
+			value = settings.Get(accountServiceURLSettings[name])
+			if value == "" {
+				continue
+			}
+			claims[name] = value
+		}
+
+		if parsed, err := url.Parse(value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			if desc != "" {
+				desc += "; "
+			}
+			desc += name + " must be an absolute http or https URL: " + value
+			fields = append(fields, FieldError{Field: name, Error: "must be an absolute http or https URL"})
+		}
+	}
+
+	if len(fields) > 0 {
+		return &TokenError{Desc: desc, Fields: fields}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package authentication
+
+import (
+	"bytes"
+	"net/url"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// additionalClaimsField is the launch value holding a JSON object of extra claims
+const additionalClaimsField = "additional_claims"
+
+// mergeAdditionalClaims adds the claims of the additional_claims JSON object to the token as given,
+// replacing any claim of the same name, so that new runner claims can be tried without a launcher change.
+// The object is merged after the claims are structured, so its claims are always at the top level.
+func mergeAdditionalClaims(claims map[string]interface{}, values url.Values) *TokenError {
+	additionalJSON := values.Get(additionalClaimsField)
+	if additionalJSON == "" {
+		return nil
+	}
+
+	var additional map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(additionalJSON)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&additional); err != nil || additional == nil {
+		return &TokenError{Desc: "additional_claims must be a JSON object", From: err,
+			Fields: []FieldError{{Field: additionalClaimsField, Error: "must be a JSON object"}}}
+	}
+
+	for name, value := range additional {
+		if _, exists := claims[name]; exists {
+			logging.Debug("Additional claim replaces generated claim", "claim", name)
+		}
+		claims[name] = value
+	}
+
+	return nil
+}
@@ -1,26 +1,35 @@
 package authentication
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"net/url"
 	"time"
+	"unicode"
 
 	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/kms"
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/metrics"
+	"github.com/ONSdigital/eq-questionnaire-launcher/runnertoken"
 	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
 	"github.com/ONSdigital/eq-questionnaire-launcher/surveys"
+	"github.com/ONSdigital/eq-questionnaire-launcher/tracing"
 	"github.com/gofrs/uuid"
-	"gopkg.in/square/go-jose.v2"
 	"gopkg.in/square/go-jose.v2/json"
 	"gopkg.in/square/go-jose.v2/jwt"
 
 	"bytes"
-	"log"
 	"path"
 	"strconv"
 	"strings"
@@ -43,56 +52,125 @@ func (e *KeyLoadError) Error() string {
 	return e.Op + ": " + e.Err
 }
 
+// newUUID generates the UUIDs used for claims, and may be replaced to make generated claims deterministic
+var newUUID = uuid.NewV4
+
 // PublicKeyResult is a wrapper for the public key and the kid that identifies it
 type PublicKeyResult struct {
-	key *rsa.PublicKey
+	key crypto.PublicKey
 	kid string
 }
 
 // PrivateKeyResult is a wrapper for the private key and the kid that identifies it
 type PrivateKeyResult struct {
-	key *rsa.PrivateKey
+	key crypto.Signer
 	kid string
 }
 
 func loadEncryptionKey() (*PublicKeyResult, *KeyLoadError) {
-	encryptionKeyPath := settings.Get("JWT_ENCRYPTION_KEY_PATH")
+	return cachedEncryptionKey(encryptionKeyProvider().source(), readEncryptionKey)
+}
 
-	keyData, err := ioutil.ReadFile(encryptionKeyPath)
-	if err != nil {
-		return nil, &KeyLoadError{Op: "read", Err: "Failed to read encryption key from file: " + encryptionKeyPath}
+// readEncryptionKey reads the encryption key from the configured provider
+func readEncryptionKey() (*PublicKeyResult, *KeyLoadError) {
+	return readEncryptionKeyFrom(encryptionKeyProvider())
+}
+
+func loadEncryptionKeyFromFile(encryptionKeyPath string) (*PublicKeyResult, *KeyLoadError) {
+	return readEncryptionKeyFrom(fileKeyProvider{path: encryptionKeyPath, name: "encryption"})
+}
+
+func readEncryptionKeyFrom(provider keyProvider) (*PublicKeyResult, *KeyLoadError) {
+	keyData, parseOp, keyErr := provider.read()
+	if keyErr != nil {
+		return nil, keyErr
 	}
 
+	return parseEncryptionKey(keyData, parseOp)
+}
+
+func parseEncryptionKey(keyData []byte, parseOp string) (*PublicKeyResult, *KeyLoadError) {
 	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return nil, &KeyLoadError{Op: parseOp, Err: "Failed to decode encryption key PEM"}
+	}
+
 	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
 	if err != nil {
-		return nil, &KeyLoadError{Op: "parse", Err: "Failed to parse encryption key PEM"}
+		return nil, &KeyLoadError{Op: parseOp, Err: "Failed to parse encryption key PEM"}
 	}
 
-	kid := fmt.Sprintf("%x", sha1.Sum(keyData))
+	kid := keyFingerprint(keyData)
 
-	publicKey, ok := pub.(*rsa.PublicKey)
-	if !ok {
-		return nil, &KeyLoadError{Op: "cast", Err: "Failed to cast key to rsa.PublicKey"}
+	switch pub.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, &KeyLoadError{Op: "cast", Err: "Failed to cast key to rsa.PublicKey or ecdsa.PublicKey"}
 	}
 
-	return &PublicKeyResult{publicKey, kid}, nil
+	return &PublicKeyResult{pub, kid}, nil
 }
 
 func loadSigningKey() (*PrivateKeyResult, *KeyLoadError) {
-	signingKeyPath := settings.Get("JWT_SIGNING_KEY_PATH")
-	keyData, err := ioutil.ReadFile(signingKeyPath)
+	return cachedSigningKey(signingKeySource(), readSigningKey)
+}
+
+// readSigningKey uses the KMS key JWT_SIGNING_KMS_KEY, or reads the signing key from the configured provider
+func readSigningKey() (*PrivateKeyResult, *KeyLoadError) {
+	if kmsKey := settings.Get("JWT_SIGNING_KMS_KEY"); kmsKey != "" {
+		return loadSigningKeyFromKMS(kmsKey)
+	}
+	return readSigningKeyFrom(signingKeyProvider())
+}
+
+func loadSigningKeyFromFile(signingKeyPath string) (*PrivateKeyResult, *KeyLoadError) {
+	return readSigningKeyFrom(fileKeyProvider{path: signingKeyPath, name: "signing"})
+}
+
+func readSigningKeyFrom(provider keyProvider) (*PrivateKeyResult, *KeyLoadError) {
+	keyData, parseOp, keyErr := provider.read()
+	if keyErr != nil {
+		return nil, keyErr
+	}
+
+	return parseSigningKey(keyData, parseOp)
+}
+
+// loadSigningKeyFromKMS fetches the public key of a KMS key, whose signatures are then made by the KMS
+func loadSigningKeyFromKMS(uri string) (*PrivateKeyResult, *KeyLoadError) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	signer, err := kms.NewSigner(ctx, uri)
 	if err != nil {
-		return nil, &KeyLoadError{Op: "read", Err: "Failed to read signing key from file: " + signingKeyPath}
+		return nil, &KeyLoadError{Op: "kms", Err: "Failed to load KMS signing key: " + err.Error()}
 	}
 
+	return newPrivateKeyResult(signer)
+}
+
+func parseSigningKey(keyData []byte, parseOp string) (*PrivateKeyResult, *KeyLoadError) {
 	block, _ := pem.Decode(keyData)
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		return nil, &KeyLoadError{Op: "parse", Err: "Failed to parse signing key from PEM"}
+	if block == nil {
+		return nil, &KeyLoadError{Op: parseOp, Err: "Failed to decode signing key PEM"}
+	}
+
+	block, keyErr := decryptSigningKeyBlock(block)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+
+	privateKey, keyErr := parsePrivateKey(block, parseOp)
+	if keyErr != nil {
+		return nil, keyErr
 	}
 
-	PublicKey, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	return newPrivateKeyResult(privateKey)
+}
+
+// newPrivateKeyResult pairs a signing key with the kid of its public key
+func newPrivateKeyResult(privateKey crypto.Signer) (*PrivateKeyResult, *KeyLoadError) {
+	PublicKey, err := x509.MarshalPKIXPublicKey(privateKey.Public())
 	if err != nil {
 		return nil, &KeyLoadError{Op: "marshal", Err: "Failed to marshal public key"}
 	}
@@ -101,11 +179,70 @@ func loadSigningKey() (*PrivateKeyResult, *KeyLoadError) {
 		Type:  "PUBLIC KEY",
 		Bytes: PublicKey,
 	})
-	kid := fmt.Sprintf("%x", sha1.Sum(pubBytes))
+	kid := keyFingerprint(pubBytes)
 
 	return &PrivateKeyResult{privateKey, kid}, nil
 }
 
+// keyFingerprint derives a kid from the PEM encoded public key, as the runner does. The digest is SHA-1,
+// or SHA-256 when JWT_KID_DIGEST is sha256.
+func keyFingerprint(publicKeyPEM []byte) string {
+	if settings.Get("JWT_KID_DIGEST") == "sha256" {
+		return fmt.Sprintf("%x", sha256.Sum256(publicKeyPEM))
+	}
+	return fmt.Sprintf("%x", sha1.Sum(publicKeyPEM))
+}
+
+// inlinePEM restores the newlines of PEM text which have been escaped to fit in an environment variable,
+// or decodes PEM text which has been base64 encoded whole
+func inlinePEM(inlineKey string) []byte {
+	if !strings.Contains(inlineKey, "-----BEGIN") {
+		if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(inlineKey)); err == nil {
+			return decoded
+		}
+	}
+	if !strings.Contains(inlineKey, `\n`) {
+		return []byte(inlineKey)
+	}
+	return []byte(strings.ReplaceAll(inlineKey, `\n`, "\n"))
+}
+
+// decryptSigningKeyBlock decrypts a passphrase protected PEM block with JWT_SIGNING_KEY_PASSPHRASE.
+// Only the traditional OpenSSL encryption is supported, encrypted PKCS#8 keys must be converted first.
+func decryptSigningKeyBlock(block *pem.Block) (*pem.Block, *KeyLoadError) {
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		return nil, &KeyLoadError{Op: "decrypt", Err: "Encrypted PKCS#8 signing keys are not supported, use openssl pkcs8 -traditional to convert the key"}
+	}
+
+	if !x509.IsEncryptedPEMBlock(block) {
+		return block, nil
+	}
+
+	passphrase := settings.Get("JWT_SIGNING_KEY_PASSPHRASE")
+	if passphrase == "" {
+		return nil, &KeyLoadError{Op: "decrypt", Err: "Signing key is encrypted but JWT_SIGNING_KEY_PASSPHRASE is not set"}
+	}
+
+	keyBytes, err := x509.DecryptPEMBlock(block, []byte(passphrase))
+	if err != nil {
+		return nil, &KeyLoadError{Op: "decrypt", Err: "Failed to decrypt signing key with JWT_SIGNING_KEY_PASSPHRASE"}
+	}
+
+	return &pem.Block{Type: block.Type, Bytes: keyBytes}, nil
+}
+
+// parsePrivateKey parses a PKCS#1 or SEC 1 private key, falling back to PKCS#8
+func parsePrivateKey(block *pem.Block, parseOp string) (crypto.Signer, *KeyLoadError) {
+	privateKey, err := runnertoken.ParsePrivateKey(block)
+	if errors.Is(err, runnertoken.ErrUnsupportedKeyType) {
+		return nil, &KeyLoadError{Op: "cast", Err: "Failed to cast key to rsa.PrivateKey or ecdsa.PrivateKey"}
+	}
+	if err != nil {
+		return nil, &KeyLoadError{Op: parseOp, Err: "Failed to parse signing key from PEM"}
+	}
+	return privateKey, nil
+}
+
 // QuestionnaireSchema is a minimal representation of a questionnaire schema used for extracting the metadata and questionnaire identifiers
 type QuestionnaireSchema struct {
 	Metadata   []Metadata `json:"metadata"`
@@ -121,30 +258,44 @@ type Metadata struct {
 
 func generateClaims(claimValues map[string][]string, launcherSchema surveys.LauncherSchema) (claims map[string]interface{}) {
 
-	var roles []string
+	roles := []string{"dumper"}
 	if rolesValues, ok := claimValues["roles"]; ok {
-		roles = rolesValues
-	} else {
-		roles = []string{"dumper"}
+		roles = splitRoles(rolesValues)
 	}
 
 	claims = make(map[string]interface{})
 
-	claims["roles"] = roles
-	TxID, _ := uuid.NewV4()
-	claims["tx_id"] = TxID.String()
+	claims["roles"] = rolesClaim(roles)
+	if txID := claimValues["tx_id"]; len(txID) > 0 && txID[0] != "" {
+		claims["tx_id"] = txID[0]
+	} else {
+		TxID, _ := newUUID()
+		claims["tx_id"] = TxID.String()
+	}
 
 	for key, value := range claimValues {
-		if key != "roles" {
-			if value[0] != "" {
-				claims[key] = value[0]
-			}
-		} else {
-			claims[key] = value
+		if key != "roles" && value[0] != "" {
+			claims[key] = value[0]
 		}
 	}
-	if len(claimValues["form_type"]) > 0 && len(claimValues["eq_id"]) > 0 {
-		log.Println("Deleting schema name from claims")
+
+	// expires_in only sets exp, randomise only fills values and additional_claims is merged once the claims are complete
+	delete(claims, "expires_in")
+	delete(claims, additionalClaimsField)
+	delete(claims, randomiseField)
+
+	if _, hasUserID := claims["user_id"]; !hasUserID && settings.Get("AUTO_USER_ID") == "true" {
+		userID, _ := newUUID()
+		claims["user_id"] = userID.String()
+	}
+	if _, hasCaseID := claims["case_id"]; !hasCaseID && settings.Get("AUTO_CASE_ID") == "true" {
+		caseID, _ := newUUID()
+		claims["case_id"] = caseID.String()
+	}
+	if len(claimValues["schema_url"]) > 0 && claimValues["schema_url"][0] != "" {
+		logging.Debug("Using schema_url, skipping eq_id and form_type")
+	} else if len(claimValues["form_type"]) > 0 && len(claimValues["eq_id"]) > 0 {
+		logging.Debug("Deleting schema name from claims")
 		delete(claims, "schema_name")
 	} else {
 		// When quicklaunching, schema_name will not be set, but launcherSchema will have the schema_name.
@@ -153,23 +304,108 @@ func generateClaims(claimValues map[string][]string, launcherSchema surveys.Laun
 		}
 	}
 
-	log.Printf("Using claims: %s", claims)
+	logging.Sensitive("Using claims", "claims", claims)
 
 	return claims
 }
 
+// splitRoles splits each submitted roles value on whitespace and commas, so "dumper flusher" and
+// "dumper,flusher" give the same roles as two separate values. No roles gives an empty array, never null.
+func splitRoles(values []string) []string {
+	roles := []string{}
+	for _, value := range values {
+		roles = append(roles, strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})...)
+	}
+	return roles
+}
+
+// rolesClaim is the roles as a JSON array, or comma separated when ROLES_FORMAT is string for runners
+// which still expect a single string
+func rolesClaim(roles []string) interface{} {
+	if settings.Get("ROLES_FORMAT") == "string" {
+		return strings.Join(roles, ",")
+	}
+	return roles
+}
+
+// applyDefaultChannel sets the channel claim, which identifies the launch source, to DEFAULT_CHANNEL when the launch omits it
+func applyDefaultChannel(claims map[string]interface{}) {
+	if channel, _ := claims["channel"].(string); channel != "" {
+		return
+	}
+
+	if defaultChannel := settings.Get("DEFAULT_CHANNEL"); defaultChannel != "" {
+		claims["channel"] = defaultChannel
+	}
+}
+
+const defaultExpiryMinutes = 10
+
+// defaultExpiry is the token lifetime from JWT_EXPIRY_MINUTES, or 10 minutes when that is unset or invalid
+func defaultExpiry() time.Duration {
+	minutes, err := strconv.Atoi(settings.Get("JWT_EXPIRY_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = defaultExpiryMinutes
+	}
+	return time.Minute * time.Duration(minutes)
+}
+
 // GenerateJwtClaims creates a jwtClaim needed to generate a token
 func GenerateJwtClaims() (jwtClaims map[string]interface{}) {
+	return generateJwtClaimsWithExpiry(defaultExpiry())
+}
+
+// expiryFromValues reads the token lifetime in seconds from the exp value, or its expires_in alias,
+// defaulting when absent or unparsable
+func expiryFromValues(values url.Values) (time.Duration, *TokenError) {
+	name := "exp"
+	if values.Get(name) == "" && values.Get("expires_in") != "" {
+		name = "expires_in"
+	}
+
+	seconds, err := strconv.Atoi(values.Get(name))
+	if err != nil {
+		return defaultExpiry(), nil
+	}
+
+	if seconds <= 0 {
+		return 0, &TokenError{Desc: fmt.Sprintf("%s must be a positive number of seconds, got %d", name, seconds)}
+	}
+
+	if maxSeconds, err := strconv.Atoi(settings.Get("JWT_MAX_EXPIRY_SECONDS")); err == nil && maxSeconds > 0 && seconds > maxSeconds {
+		return 0, &TokenError{Desc: fmt.Sprintf("%s must be at most %d seconds, got %d", name, maxSeconds, seconds)}
+	}
+
+	return time.Second * time.Duration(seconds), nil
+}
+
+func generateJwtClaimsWithExpiry(expiry time.Duration) (jwtClaims map[string]interface{}) {
 	issued := time.Now()
-	expires := issued.Add(time.Minute * 10) // TODO: Support custom exp: r.PostForm.Get("exp")
+	expires := issued.Add(expiry)
 
 	jwtClaims = make(map[string]interface{})
 
 	jwtClaims["iat"] = jwt.NewNumericDate(issued)
 	jwtClaims["exp"] = jwt.NewNumericDate(expires)
-	jti, _ := uuid.NewV4()
+	jti, _ := newUUID()
 	jwtClaims["jti"] = jti.String()
 
+	if issuer := settings.Get("JWT_ISSUER"); issuer != "" {
+		jwtClaims["iss"] = issuer
+	}
+
+	var audience jwt.Audience
+	for _, aud := range strings.Split(settings.Get("JWT_AUDIENCE"), ",") {
+		if aud = strings.TrimSpace(aud); aud != "" {
+			audience = append(audience, aud)
+		}
+	}
+	if len(audience) > 0 {
+		jwtClaims["aud"] = audience
+	}
+
 	return jwtClaims
 }
 
@@ -219,7 +455,7 @@ func launcherSchemaFromURL(url string) (launcherSchema surveys.LauncherSchema, e
 		schemaName = schema.SchemaName
 	}
 
-	log.Println("Quicklaunch schema_name set to: ", schemaName)
+	logging.Info("Quicklaunch schema_name set", "schema_name", schemaName)
 
 	launcherSchema = surveys.LauncherSchema{
 		URL:  url + cacheBust,
@@ -237,9 +473,9 @@ func validateSchema(payload []byte) (error string) {
 	validateURL, _ := url.Parse(settings.Get("SCHEMA_VALIDATOR_URL"))
 	validateURL.Path = path.Join(validateURL.Path, "validate")
 
-	log.Println("Validating schema: ", validateURL.String())
+	logging.Debug("Validating schema", "url", validateURL.String())
 
-	resp, err := http.Post(validateURL.String(), "application/json", bytes.NewBuffer(payload))
+	resp, err := clients.GetHTTPClient().Post(validateURL.String(), "application/json", bytes.NewBuffer(payload))
 	if err != nil {
 		return err.Error()
 	}
@@ -274,6 +510,12 @@ type TokenError struct {
 
 	// From is optionally the original error from which this one was caused.
 	From error
+
+	// Fields names each launch value at fault, when the error is caused by particular values
+	Fields []FieldError
+
+	// stage is the step of token generation that failed, for the failure metrics
+	stage string
 }
 
 func (e *TokenError) Error() string {
@@ -289,43 +531,147 @@ func (e *TokenError) Error() string {
 
 // generateTokenFromClaims creates a token though encryption using the private and public keys
 func generateTokenFromClaims(cl map[string]interface{}) (string, *TokenError) {
-	privateKeyResult, keyErr := loadSigningKey()
-	if keyErr != nil {
-		return "", &TokenError{Desc: "Error loading signing key", From: keyErr}
+	token, _, err := generateTokenFromClaimsForTarget(context.Background(), cl, defaultTokenTarget())
+	return token, err
+}
+
+// generateTokenFromClaimsForTarget creates a token using the keys and algorithms of the given target
+func generateTokenFromClaimsForTarget(ctx context.Context, cl map[string]interface{}, target TokenTarget) (string, TokenKeys, *TokenError) {
+	token, keys, tokenErr := signAndEncryptClaims(ctx, cl, target)
+	if tokenErr != nil {
+		metrics.TokenFailed(tokenErr.stage)
+		return "", TokenKeys{}, tokenErr
 	}
 
-	publicKeyResult, keyErr := loadEncryptionKey()
+	metrics.TokenGenerated()
+	return token, keys, nil
+}
+
+func signAndEncryptClaims(ctx context.Context, cl map[string]interface{}, target TokenTarget) (string, TokenKeys, *TokenError) {
+	_, keySpan := tracing.Start(ctx, "load keys", tracing.KindInternal)
+	privateKeyResult, keyErr := target.signingKey()
 	if keyErr != nil {
-		return "", &TokenError{Desc: "Error loading encryption key", From: keyErr}
+		keySpan.SetError(keyErr.Error())
+		keySpan.End()
+		return "", TokenKeys{}, &TokenError{Desc: "Error loading signing key", From: keyErr, stage: metrics.StageKeyLoad}
 	}
 
-	opts := jose.SignerOptions{}
-	opts.WithType("JWT")
-	opts.WithHeader("kid", privateKeyResult.kid)
+	encryptionKeys, tokenErr := target.encryptionRecipients()
+	if tokenErr != nil {
+		keySpan.SetError(tokenErr.Error())
+		keySpan.End()
+		return "", TokenKeys{}, tokenErr
+	}
+	keySpan.End()
 
-	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: privateKeyResult.key}, &opts)
-	if err != nil {
-		return "", &TokenError{Desc: "Error creating JWT signer", From: err}
+	signingAlgorithm, keyAlgorithm, contentAlgorithm, algorithmErr := target.withKeySigningAlgorithm(privateKeyResult.key).algorithms()
+	if algorithmErr != nil {
+		return "", TokenKeys{}, algorithmErr
 	}
 
-	encryptor, err := jose.NewEncrypter(
-		jose.A256GCM,
-		jose.Recipient{Algorithm: jose.RSA_OAEP, Key: publicKeyResult.key, KeyID: publicKeyResult.kid},
-		(&jose.EncrypterOptions{}).WithType("JWT").WithContentType("JWT"))
+	if err := checkSigningKeyAlgorithm(privateKeyResult.key, signingAlgorithm); err != nil {
+		return "", TokenKeys{}, err
+	}
+
+	keys := TokenKeys{SigningKid: privateKeyResult.kid}
+	var recipients []runnertoken.Recipient
+	for _, publicKeyResult := range encryptionKeys {
+		if err := checkEncryptionKeyAlgorithm(publicKeyResult.key, keyAlgorithm); err != nil {
+			return "", TokenKeys{}, err
+		}
+		keys.EncryptionKids = append(keys.EncryptionKids, publicKeyResult.kid)
+		recipients = append(recipients, runnertoken.Recipient{Key: publicKeyResult.key, Kid: publicKeyResult.kid})
+	}
+	if target.SigningKid != "" {
+		keys.SigningKid = target.SigningKid
+	}
+	if target.fault == FaultWrongKid {
+		keys.SigningKid = faultInjectionKid
+	}
 
+	signingKey := privateKeyResult.key
+	if target.fault == FaultWrongSigningKey {
+		faultKey, err := faultSigningKey(signingKey)
+		if err != nil {
+			return "", TokenKeys{}, &TokenError{Desc: "Error generating fault injection signing key", From: err, stage: metrics.StageSign}
+		}
+		signingKey = faultKey
+	}
+
+	if target.fault == FaultExpired {
+		cl = expireClaims(cl)
+	}
+
+	payload, tokenErr := marshalClaims(cl)
+	if tokenErr != nil {
+		return "", TokenKeys{}, tokenErr
+	}
+
+	// A KMS key signs with a call to the KMS, so the token only takes a worker to be encrypted and the wait for
+	// the KMS does not hold one
+	localSigning := signsLocally(signingKey)
+	release := func() {}
+	if localSigning {
+		release = acquireSigningWorker()
+	}
+	defer func() { release() }()
+
+	_, signSpan := tracing.Start(ctx, "sign token", tracing.KindInternal)
+	signSpan.SetAttribute("jwt.alg", string(signingAlgorithm))
+	signed, err := runnertoken.Sign(payload, signingKey, signingAlgorithm, keys.SigningKid)
 	if err != nil {
-		return "", &TokenError{Desc: "Error creating JWT signer", From: err}
+		signSpan.SetError(err.Error())
+		signSpan.End()
+		return "", TokenKeys{}, &TokenError{Desc: "Error signing JWT", From: err, stage: metrics.StageSign}
+	}
+	signSpan.End()
+
+	if target.fault == FaultBadSignature {
+		if signed, err = corruptSignature(signed); err != nil {
+			return "", TokenKeys{}, &TokenError{Desc: "Error corrupting JWT signature", From: err, stage: metrics.StageSign}
+		}
 	}
 
-	token, err := jwt.SignedAndEncrypted(signer, encryptor).Claims(cl).CompactSerialize()
+	if !localSigning {
+		release = acquireSigningWorker()
+	}
 
+	_, encryptSpan := tracing.Start(ctx, "encrypt token", tracing.KindInternal)
+	encryptSpan.SetAttribute("jwe.alg", string(keyAlgorithm))
+	encryptSpan.SetAttribute("jwe.enc", string(contentAlgorithm))
+	encryptSpan.SetAttribute("jwe.recipients", len(recipients))
+	token, err := runnertoken.EncryptForRecipients(signed, recipients, keyAlgorithm, contentAlgorithm)
 	if err != nil {
-		return "", &TokenError{Desc: "Error signing and encrypting JWT", From: err}
+		encryptSpan.SetError(err.Error())
+		encryptSpan.End()
+		return "", TokenKeys{}, &TokenError{Desc: "Error encrypting JWT", From: err, stage: metrics.StageEncrypt}
 	}
+	encryptSpan.End()
 
-	log.Println("Created signed/encrypted JWT:", token)
+	logging.Info("Created signed/encrypted JWT", "tx_id", cl["tx_id"], "token", logging.RedactToken(token), "signing_kid", keys.SigningKid, "encryption_kids", strings.Join(keys.EncryptionKids, ","))
 
-	return token, nil
+	return token, keys, nil
+}
+
+// marshalClaims serialises the claims in the style given by the CLAIMS_JSON_STYLE setting
+func marshalClaims(cl map[string]interface{}) ([]byte, *TokenError) {
+	var payload []byte
+	var err error
+
+	switch style := settings.Get("CLAIMS_JSON_STYLE"); style {
+	case "minified":
+		payload, err = json.Marshal(cl)
+	case "indented":
+		payload, err = json.MarshalIndent(cl, "", "  ")
+	default:
+		return nil, &TokenError{Desc: "Unsupported CLAIMS_JSON_STYLE: " + style, stage: metrics.StageValidation}
+	}
+
+	if err != nil {
+		return nil, &TokenError{Desc: "Error marshalling claims", From: err, stage: metrics.StageValidation}
+	}
+
+	return payload, nil
 }
 
 func getBooleanOrDefault(key string, values map[string][]string, defaultValue bool) bool {
@@ -347,45 +693,33 @@ func getStringOrDefault(key string, values map[string][]string, defaultValue str
 
 // GenerateTokenFromDefaults coverts a set of DEFAULT values into a JWT
 func GenerateTokenFromDefaults(surveyURL string, accountServiceURL string, accountServiceLogOutURL string, urlValues url.Values) (token string, error string) {
+	token, _, error = GenerateTokenAndClaimsFromDefaults(surveyURL, accountServiceURL, accountServiceLogOutURL, urlValues)
+	return token, error
+}
+
+// GenerateTokenAndClaimsFromDefaults coverts a set of DEFAULT values into a JWT like GenerateTokenFromDefaults,
+// also returning the claims it contains
+func GenerateTokenAndClaimsFromDefaults(surveyURL string, accountServiceURL string, accountServiceLogOutURL string, urlValues url.Values) (string, map[string]interface{}, string) {
 	launcherSchema, validationError := launcherSchemaFromURL(surveyURL)
 	if validationError != "" {
-		return "", validationError
+		return "", nil, validationError
 	}
 
-	claims := make(map[string]interface{})
 	urlValues["account_service_url"] = []string{accountServiceURL}
 	urlValues["account_service_log_out_url"] = []string{accountServiceLogOutURL}
-	claims = generateClaims(urlValues, launcherSchema)
 
-	requiredMetadata, error := GetRequiredMetadata(launcherSchema)
+	claims, error := buildClaims(context.Background(), urlValues, &launcherSchema)
 	if error != "" {
-		return "", fmt.Sprintf("GetRequiredMetadata failed err: %v", error)
-	}
-
-	for _, metadata := range requiredMetadata {
-		if metadata.Validator == "boolean" {
-			claims[metadata.Name] = getBooleanOrDefault(metadata.Name, urlValues, false)
-			continue
-		}
-		claims[metadata.Name] = getStringOrDefault(metadata.Name, urlValues, metadata.Default)
-	}
-
-	jwtClaims := GenerateJwtClaims()
-	for key, v := range jwtClaims {
-		claims[key] = v
-	}
-
-	schemaClaims := getSchemaClaims(launcherSchema)
-	for key, v := range schemaClaims {
-		claims[key] = v
+		return "", nil, error
 	}
 
 	token, tokenError := generateTokenFromClaims(claims)
 	if tokenError != nil {
-		return token, fmt.Sprintf("GenerateTokenFromDefaults failed err: %v", tokenError)
+		return token, nil, fmt.Sprintf("GenerateTokenFromDefaults failed err: %v", tokenError)
 	}
+	recordIssuedToken(claims)
 
-	return token, ""
+	return token, claims, ""
 }
 
 // TransformSchemaParamsToName Returns a schema name from business schema parameters
@@ -403,31 +737,181 @@ func TransformSchemaParamsToName(postValues url.Values) string {
 
 // GenerateTokenFromPost converts a set of POST values into a JWT
 func GenerateTokenFromPost(postValues url.Values) (string, string) {
-	log.Println("POST received: ", postValues)
+	token, _, error := GenerateTokenAndClaimsFromPost(postValues)
+	return token, error
+}
+
+// GenerateTokenAndClaimsFromPost converts a set of POST values into a JWT, also returning the claims it contains
+func GenerateTokenAndClaimsFromPost(postValues url.Values) (string, map[string]interface{}, string) {
+	return GenerateTokenAndClaimsFromPostWithContext(context.Background(), postValues)
+}
 
-	schema := TransformSchemaParamsToName(postValues)
+// GenerateTokenAndClaimsFromPostWithContext converts a set of POST values into a JWT like
+// GenerateTokenAndClaimsFromPost, tracing its generation as a child of the span in ctx and abandoning the
+// schema fetch when ctx is done
+func GenerateTokenAndClaimsFromPostWithContext(ctx context.Context, postValues url.Values) (string, map[string]interface{}, string) {
+	defer metrics.ObserveTokenGeneration(time.Now())
 
-	launcherSchema := surveys.FindSurveyByName(schema)
+	ctx, span := tracing.Start(ctx, "generate token", tracing.KindInternal)
+	defer span.End()
+
+	target, tokenError := signingTargetFromPost(postValues)
+	if tokenError != nil {
+		span.SetError(tokenError.Error())
+		return "", nil, fmt.Sprintf("GenerateTokenFromPost failed err: %v", tokenError)
+	}
+
+	claims, error := claimsFromPost(ctx, postValues)
+	if error != "" {
+		span.SetError(error)
+		return "", nil, error
+	}
+	span.SetAttribute("tx_id", fmt.Sprint(claims["tx_id"]))
+
+	token, _, tokenError := generateTokenFromClaimsForTarget(ctx, claims, target)
+	if tokenError != nil {
+		span.SetError(tokenError.Error())
+		return token, nil, fmt.Sprintf("GenerateTokenFromPost failed err: %v", tokenError)
+	}
+	recordIssuedToken(claims)
+
+	return token, claims, ""
+}
+
+// GenerateTokensForTargets converts a set of POST values into a JWT for each of the targets.
+// The tokens and the kids of the keys used to create them are keyed by target name, and every token has the
+// returned tx_id.
+func GenerateTokensForTargets(postValues url.Values, targets []TokenTarget) (map[string]string, map[string]TokenKeys, string, string) {
+	if len(targets) == 0 {
+		return nil, nil, "", "At least one target is required"
+	}
+
+	claims, error := claimsFromPost(context.Background(), postValues)
+	if error != "" {
+		return nil, nil, "", error
+	}
+
+	tokens := make(map[string]string)
+	tokenKeys := make(map[string]TokenKeys)
+	for _, target := range targets {
+		if target.Name == "" {
+			return nil, nil, "", "Every target must have a name"
+		}
+		if _, exists := tokens[target.Name]; exists {
+			return nil, nil, "", fmt.Sprintf("Duplicate target name: %s", target.Name)
+		}
+
+		configured, tokenError := target.withConfiguredKeys()
+		if tokenError != nil {
+			return nil, nil, "", fmt.Sprintf("GenerateTokensForTargets failed for target %s err: %v", target.Name, tokenError)
+		}
+
+		token, keys, tokenError := generateTokenFromClaimsForTarget(context.Background(), claims, configured.withDefaults())
+		if tokenError != nil {
+			return nil, nil, "", fmt.Sprintf("GenerateTokensForTargets failed for target %s err: %v", target.Name, tokenError)
+		}
+		tokens[target.Name] = token
+		tokenKeys[target.Name] = keys
+	}
+	// every target is sent the same claims, so they are one issued token
+	recordIssuedToken(claims)
+
+	txID, _ := claims["tx_id"].(string)
+	return tokens, tokenKeys, txID, ""
+}
+
+// claimsFromPost builds the claims for a set of POST values, counting any failure as a validation failure
+func claimsFromPost(ctx context.Context, postValues url.Values) (map[string]interface{}, string) {
+	claims, error := buildClaims(ctx, postValues, nil)
+	if error != "" {
+		metrics.TokenFailed(metrics.StageValidation)
+	}
+	return claims, error
+}
+
+// buildClaims builds the claims for a launch's values, finding its schema from them unless a quick launch gives
+// quickLaunchSchema. A quick launch fills the schema metadata it leaves out with the metadata defaults.
+func buildClaims(ctx context.Context, postValues url.Values, quickLaunchSchema *surveys.LauncherSchema) (map[string]interface{}, string) {
+	logging.Sensitive("POST received", "values", postValues.Encode())
+
+	operation := "GenerateTokenFromPost"
+	if quickLaunchSchema != nil {
+		operation = "GenerateTokenFromDefaults"
+	}
+
+	postValues = withRandomValues(postValues)
+	postValues, presetErr := withRegionPreset(postValues)
+	if presetErr != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, presetErr)
+	}
+	postValues, defaultsErr := withClaimDefaults(postValues)
+	if defaultsErr != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, defaultsErr)
+	}
+
+	var launcherSchema surveys.LauncherSchema
+	if quickLaunchSchema != nil {
+		launcherSchema = *quickLaunchSchema
+	} else {
+		var schemaError *TokenError
+		if launcherSchema, schemaError = launcherSchemaFromPost(postValues); schemaError != nil {
+			return nil, fmt.Sprintf("%s failed err: %v", operation, schemaError)
+		}
+	}
 
 	claims := generateClaims(postValues, launcherSchema)
 
-	jwtClaims := GenerateJwtClaims()
+	if schemaError := completeEqIDFormType(claims); schemaError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, schemaError)
+	}
+
+	// kid, encryption_kid, the environment, the region preset and the algorithm overrides select how the token is
+	// made and are not claims
+	delete(claims, "kid")
+	delete(claims, encryptionKidField)
+	delete(claims, environmentField)
+	delete(claims, regionPresetField)
+	for _, field := range algorithmOverrideFields {
+		delete(claims, field)
+	}
+
+	expiry, expiryError := expiryFromValues(postValues)
+	if expiryError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, expiryError)
+	}
+
+	jwtClaims := generateJwtClaimsWithExpiry(expiry)
 	for key, v := range jwtClaims {
 		claims[key] = v
 	}
 
+	if reissueError := applyReissuedJTI(claims); reissueError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, reissueError)
+	}
+
+	if skewError := applyClockSkew(claims); skewError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, skewError)
+	}
+
 	schemaClaims := getSchemaClaims(launcherSchema)
 	for key, v := range schemaClaims {
 		claims[key] = v
 	}
 
-	requiredMetadata, error := GetRequiredMetadata(launcherSchema)
+	requiredMetadata, error := GetRequiredMetadataWithContext(ctx, launcherSchema)
 	if error != "" {
-		return "", fmt.Sprintf("GetRequiredMetadata failed err: %v", error)
+		return nil, fmt.Sprintf("GetRequiredMetadata failed err: %v", error)
 	}
 
 	for _, metadata := range requiredMetadata {
-		if metadata.Validator == "boolean" {
+		switch {
+		case quickLaunchSchema != nil && metadata.Validator == "boolean":
+			claims[metadata.Name] = getBooleanOrDefault(metadata.Name, postValues, false)
+		case quickLaunchSchema != nil:
+			if _, isset := claims[metadata.Name]; !isset {
+				claims[metadata.Name] = getStringOrDefault(metadata.Name, postValues, metadata.Default)
+			}
+		case metadata.Validator == "boolean":
 			_, isset := claims[metadata.Name]
 			claims[metadata.Name] = isset
 		}
@@ -437,16 +921,81 @@ func GenerateTokenFromPost(postValues url.Values) (string, string) {
 		claims["schema_name"] = launcherSchema.Name
 	}
 
-	token, tokenError := generateTokenFromClaims(claims)
-	if tokenError != nil {
-		return token, fmt.Sprintf("GenerateTokenFromPost failed err: %v", tokenError)
+	if profileError := applyValidationProfile(claims); profileError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, profileError)
+	}
+
+	if dateError := normalizeDateClaims(claims); dateError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, dateError)
+	}
+
+	if responseExpiryError := applyResponseExpiresAt(claims); responseExpiryError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, responseExpiryError)
+	}
+
+	if accountError := validateAccountID(claims); accountError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, accountError)
+	}
+
+	if accountServiceError := applyAccountServiceURLs(claims); accountServiceError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, accountServiceError)
+	}
+
+	if caseError := validateCaseID(claims); caseError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, caseError)
+	}
+
+	if datasetError := validateSDSDatasetID(claims); datasetError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, datasetError)
+	}
+
+	if variantFlagsError := collectVariantFlags(claims); variantFlagsError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, variantFlagsError)
+	}
+
+	if languageError := validateLanguageCode(claims); languageError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, languageError)
+	}
+
+	if regionError := validateRegionCode(claims); regionError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, regionError)
+	}
+
+	if regionError := validateRegionLanguage(claims); regionError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, regionError)
 	}
 
-	return token, ""
+	if metadataError := validateSchemaMetadata(claims, requiredMetadata); metadataError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, metadataError)
+	}
+
+	if claimsError := validateClaims(claims); claimsError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, claimsError)
+	}
+
+	applyDefaultChannel(claims)
+	addCompositeReference(claims)
+	embedTxIDMetadata(claims)
+	collectSurveyMetadata(claims)
+
+	if versionError := applyClaimsVersion(claims); versionError != "" {
+		return nil, versionError
+	}
+
+	if additionalError := mergeAdditionalClaims(claims, postValues); additionalError != nil {
+		return nil, fmt.Sprintf("%s failed err: %v", operation, additionalError)
+	}
+
+	return claims, ""
 }
 
 // GetRequiredMetadata Gets the required metadata from a schema
 func GetRequiredMetadata(launcherSchema surveys.LauncherSchema) ([]Metadata, string) {
+	return GetRequiredMetadataWithContext(context.Background(), launcherSchema)
+}
+
+// GetRequiredMetadataWithContext gets the required metadata from a schema, abandoning the schema fetch when ctx is done
+func GetRequiredMetadataWithContext(ctx context.Context, launcherSchema surveys.LauncherSchema) ([]Metadata, string) {
 	var url string
 
 	if launcherSchema.URL != "" {
@@ -454,33 +1003,20 @@ func GetRequiredMetadata(launcherSchema surveys.LauncherSchema) ([]Metadata, str
 	} else {
 		hostURL := settings.Get("SURVEY_RUNNER_SCHEMA_URL")
 
-		log.Println("Name: ", launcherSchema.Name)
+		logging.Debug("Loading schema by name", "schema_name", launcherSchema.Name)
 		url = fmt.Sprintf("%s/schemas/%s", hostURL, launcherSchema.Name)
 	}
 
-	log.Println("Loading metadata from schema:", url)
+	logging.Debug("Loading metadata from schema", "url", url)
 
-	resp, err := clients.GetHTTPClient().Get(url)
-	if err != nil {
-		log.Println("Failed to load schema from:", url)
-		return nil, fmt.Sprintf("Failed to load Schema from %s", url)
-	}
-
-	if resp.StatusCode != 200 {
-		log.Print("Invalid response code for schema from: ", url)
-		return nil, fmt.Sprintf("Failed to load Schema from %s", url)
-	}
-
-	responseBody, err := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		log.Print(err)
-		return nil, fmt.Sprintf("Failed to load Schema from %s", url)
+	responseBody, schemaErr := fetchSchema(ctx, url)
+	if schemaErr != "" {
+		return nil, schemaErr
 	}
 
 	var schema QuestionnaireSchema
 	if err := json.Unmarshal(responseBody, &schema); err != nil {
-		log.Print(err)
+		logging.Error("Failed to unmarshal schema", "url", url, "err", err)
 		return nil, fmt.Sprintf("Failed to unmarshal Schema from %s", url)
 	}
 
@@ -497,12 +1033,35 @@ func GetRequiredMetadata(launcherSchema surveys.LauncherSchema) ([]Metadata, str
 	return schema.Metadata, ""
 }
 
-// GetDefaultValues Returns a map of default values for metadata keys
+// fetchSchema reads the schema JSON at url
+func fetchSchema(ctx context.Context, url string) ([]byte, string) {
+	resp, err := clients.GetWithHeaders(ctx, url, surveys.CIRHeaders(url))
+	if err != nil {
+		logging.Error("Failed to load schema", "url", url, "err", err)
+		return nil, fmt.Sprintf("Failed to load Schema from %s", url)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		logging.Error("Invalid response code for schema", "url", url, "status", resp.StatusCode)
+		return nil, fmt.Sprintf("Failed to load Schema from %s", url)
+	}
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		logging.Error("Failed to read schema", "url", url, "err", err)
+		return nil, fmt.Sprintf("Failed to load Schema from %s", url)
+	}
+
+	return responseBody, ""
+}
+
+// GetDefaultValues Returns a map of default values for metadata keys, with any CLAIM_DEFAULTS replacing the built in ones
 func GetDefaultValues() map[string]string {
 
 	defaults := make(map[string]string)
 
-	collectionExerciseSid, _ := uuid.NewV4()
+	collectionExerciseSid, _ := newUUID()
 
 	defaults["user_id"] = "UNKNOWN"
 	defaults["period_id"] = "201605"
@@ -526,5 +1085,10 @@ func GetDefaultValues() map[string]string {
 	defaults["display_address"] = "68 Abingdon Road, Goathill"
 	defaults["country"] = "E"
 
+	claimDefaults := ClaimDefaults()
+	for name := range claimDefaults {
+		defaults[name] = claimDefaults.Get(name)
+	}
+
 	return defaults
 }
@@ -0,0 +1,221 @@
+package authentication
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/json"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestMarshalClaimsStyles(t *testing.T) {
+	claims := map[string]interface{}{"tx_id": "abc", "roles": []string{"dumper"}, "exp": 1700000000}
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{"minified", `{"exp":1700000000,"roles":["dumper"],"tx_id":"abc"}`},
+		{"indented", "{\n  \"exp\": 1700000000,\n  \"roles\": [\n    \"dumper\"\n  ],\n  \"tx_id\": \"abc\"\n}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.style, func(t *testing.T) {
+			useSetting(t, "CLAIMS_JSON_STYLE", test.style)
+
+			payload, err := marshalClaims(claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(payload) != test.want {
+				t.Errorf("marshalClaims = %q, want %q", payload, test.want)
+			}
+		})
+	}
+}
+
+func TestMarshalClaimsUnsupportedStyle(t *testing.T) {
+	useSetting(t, "CLAIMS_JSON_STYLE", "pretty")
+
+	if _, err := marshalClaims(map[string]interface{}{}); err == nil || err.Desc != "Unsupported CLAIMS_JSON_STYLE: pretty" {
+		t.Errorf("marshalClaims error = %v, want Unsupported CLAIMS_JSON_STYLE: pretty", err)
+	}
+}
+
+// TestSignedPlaintextStyle checks that the bytes signed are those of the chosen style
+func TestSignedPlaintextStyle(t *testing.T) {
+	useTestKeys(t)
+	encryptionKeyPath, decryptionKeyPath := generateEncryptionKey(t)
+	useSetting(t, "JWT_ENCRYPTION_KEY_PATH", encryptionKeyPath)
+
+	claims := map[string]interface{}{"tx_id": "abc", "exp": 1700000000}
+
+	for _, style := range []string{"minified", "indented"} {
+		t.Run(style, func(t *testing.T) {
+			useSetting(t, "CLAIMS_JSON_STYLE", style)
+			want, _ := marshalClaims(claims)
+
+			token, _, tokenErr := signAndEncryptClaims(context.Background(), claims, defaultTokenTarget())
+			if tokenErr != nil {
+				t.Fatal(tokenErr)
+			}
+
+			if got := signedPayload(t, token, decryptionKeyPath); got != string(want) {
+				t.Errorf("signed payload = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// signedPayload decrypts a token with the key at decryptionKeyPath, returning the verified payload of the JWS inside
+func signedPayload(t *testing.T, token string, decryptionKeyPath string) string {
+	t.Helper()
+	decryptionKey, keyErr := loadDecryptionKeyFromFile(decryptionKeyPath)
+	if keyErr != nil {
+		t.Fatal(keyErr)
+	}
+
+	encrypted, err := jose.ParseEncrypted(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := encrypted.Decrypt(decryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := jose.ParseSigned(string(signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKey, keyErr := loadSigningKey()
+	if keyErr != nil {
+		t.Fatal(keyErr)
+	}
+	payload, err := jws.Verify(signingKey.key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(payload)
+}
+
+func TestIssuerAndAudienceRoundTrip(t *testing.T) {
+	useTestKeys(t)
+	encryptionKeyPath, decryptionKeyPath := generateEncryptionKey(t)
+	useSetting(t, "JWT_ENCRYPTION_KEY_PATH", encryptionKeyPath)
+
+	tests := []struct {
+		name         string
+		issuer       string
+		audience     string
+		wantIssuer   string
+		wantAudience jwt.Audience
+	}{
+		{"unset", "", "", "", nil},
+		{"issuer and audience", "eq-questionnaire-launcher", "eq-survey-runner", "eq-questionnaire-launcher", jwt.Audience{"eq-survey-runner"}},
+		{"several audiences", "eq-questionnaire-launcher", "runner-a, runner-b,", "eq-questionnaire-launcher", jwt.Audience{"runner-a", "runner-b"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useSetting(t, "JWT_ISSUER", test.issuer)
+			useSetting(t, "JWT_AUDIENCE", test.audience)
+
+			token, _, tokenErr := signAndEncryptClaims(context.Background(), GenerateJwtClaims(), defaultTokenTarget())
+			if tokenErr != nil {
+				t.Fatal(tokenErr)
+			}
+
+			var claims jwt.Claims
+			var raw map[string]interface{}
+			payload := signedPayload(t, token, decryptionKeyPath)
+			if err := json.Unmarshal([]byte(payload), &claims); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(payload), &raw); err != nil {
+				t.Fatal(err)
+			}
+
+			if claims.Issuer != test.wantIssuer {
+				t.Errorf("iss = %q, want %q", claims.Issuer, test.wantIssuer)
+			}
+			if !reflect.DeepEqual(claims.Audience, test.wantAudience) {
+				t.Errorf("aud = %v, want %v", claims.Audience, test.wantAudience)
+			}
+			if _, ok := raw["iss"]; test.wantIssuer == "" && ok {
+				t.Error("iss is present when JWT_ISSUER is unset")
+			}
+			if _, ok := raw["aud"]; test.wantAudience == nil && ok {
+				t.Error("aud is present when JWT_AUDIENCE is unset")
+			}
+			if test.wantAudience != nil && claims.Validate(jwt.Expected{Issuer: test.wantIssuer, Audience: test.wantAudience, Time: time.Now()}) != nil {
+				t.Error("claims do not validate against the expected issuer and audience")
+			}
+		})
+	}
+}
+
+// quickLaunchSchemaURL serves a schema requiring a uuid case_ref and a boolean flag, for quick launches by url
+func quickLaunchSchemaURL(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"metadata": [{"name": "case_ref", "type": "uuid"}, {"name": "flag", "type": "boolean"}], "survey_id": "001", "title": "Test"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/test_quick_launch.json"
+}
+
+func TestGenerateTokenAndClaimsFromDefaults(t *testing.T) {
+	useTestKeys(t)
+	useSetting(t, "CLAIM_DEFAULTS", `{"ru_name": "ESSENTIAL ENTERPRISE LTD."}`)
+	surveyURL := quickLaunchSchemaURL(t)
+
+	values := url.Values{
+		"tx_id":                   {"c2b3a7e2-7f6a-4a3e-9d3b-1c2d3e4f5a6b"},
+		"collection_exercise_sid": {"789"},
+		"kid":                     {"some-kid"},
+		"case_ref":                {"4f2a2b8e-3c1d-4e5f-8a9b-0c1d2e3f4a5b"},
+		"flag":                    {"true"},
+	}
+	token, claims, err := GenerateTokenAndClaimsFromDefaults(surveyURL, "https://example.com/account", "https://example.com/logout", values)
+	if err != "" {
+		t.Fatal(err)
+	}
+	if token == "" {
+		t.Error("token is empty")
+	}
+
+	if claims["tx_id"] != "c2b3a7e2-7f6a-4a3e-9d3b-1c2d3e4f5a6b" {
+		t.Errorf("tx_id = %v, want the tx_id given", claims["tx_id"])
+	}
+	if claims["ru_name"] != "ESSENTIAL ENTERPRISE LTD." {
+		t.Errorf("ru_name = %v, want the CLAIM_DEFAULTS value", claims["ru_name"])
+	}
+	if claims["flag"] != true {
+		t.Errorf("flag = %v, want true", claims["flag"])
+	}
+	if _, ok := claims["kid"]; ok {
+		t.Error("kid is a claim, want it to only select the key")
+	}
+	if claims["schema_name"] != "test_quick_launch" {
+		t.Errorf("schema_name = %v, want test_quick_launch", claims["schema_name"])
+	}
+}
+
+func TestGenerateTokenAndClaimsFromDefaultsValidatesMetadata(t *testing.T) {
+	useTestKeys(t)
+	useSetting(t, "VALIDATE_SCHEMA_METADATA", "true")
+	surveyURL := quickLaunchSchemaURL(t)
+
+	_, _, err := GenerateTokenAndClaimsFromDefaults(surveyURL, "", "", url.Values{"case_ref": {"not-a-uuid"}})
+	if !strings.Contains(err, "case_ref (uuid)") {
+		t.Errorf("error = %q, want case_ref rejected as an invalid uuid", err)
+	}
+}
@@ -0,0 +1,115 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sync"
+)
+
+// GenerateTokensFromPosts converts each of a list of sets of POST values into a JWT, returning the tx_id of each
+// token at the same index.
+//
+// The configured keys are loaded once up front, and a key load failure fails the whole batch.
+// Any other failure only affects its own set: its token is left empty and the reason is
+// reported in the returned map, keyed by the index of the set. Sets are generated by TOKEN_SIGNING_WORKERS at once.
+func GenerateTokensFromPosts(sets []url.Values) ([]string, []string, map[int]string, string) {
+	if len(sets) == 0 {
+		return nil, nil, nil, "At least one set of launch values is required"
+	}
+
+	target := defaultTokenTarget()
+	if _, keyErr := target.signingKey(); keyErr != nil {
+		return nil, nil, nil, fmt.Sprintf("GenerateTokensFromPosts failed err: %v", &TokenError{Desc: "Error loading signing key", From: keyErr})
+	}
+	if _, keyErr := target.encryptionKey(); keyErr != nil {
+		return nil, nil, nil, fmt.Sprintf("GenerateTokensFromPosts failed err: %v", &TokenError{Desc: "Error loading encryption key", From: keyErr})
+	}
+
+	tokens := make([]string, len(sets))
+	txIDs := make([]string, len(sets))
+	failures := make(map[int]string)
+	var failuresMutex sync.Mutex
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < signingWorkerCount() && worker < len(sets); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				token, txID, error := generateBatchToken(sets[i])
+				if error != "" {
+					failuresMutex.Lock()
+					failures[i] = error
+					failuresMutex.Unlock()
+					continue
+				}
+				tokens[i] = token
+				txIDs[i] = txID
+			}
+		}()
+	}
+
+	for i := range sets {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return tokens, txIDs, failures, ""
+}
+
+// generateBatchToken generates the token for a single set of a batch, reporting a failed schema lookup as an error.
+// The keys are served from the cache filled when the batch started.
+func generateBatchToken(postValues url.Values) (token string, txID string, error string) {
+	defer func() {
+		if r := recover(); r != nil {
+			token, txID, error = "", "", fmt.Sprint(r)
+		}
+	}()
+
+	target, tokenError := signingTargetFromPost(postValues)
+	if tokenError != nil {
+		return "", "", fmt.Sprintf("GenerateTokensFromPosts failed err: %v", tokenError)
+	}
+
+	claims, error := claimsFromPost(context.Background(), postValues)
+	if error != "" {
+		return "", "", error
+	}
+
+	token, _, tokenError = generateTokenFromClaimsForTarget(context.Background(), claims, target)
+	if tokenError != nil {
+		return "", "", fmt.Sprintf("GenerateTokensFromPosts failed err: %v", tokenError)
+	}
+	recordIssuedToken(claims)
+
+	txID, _ = claims["tx_id"].(string)
+	return token, txID, ""
+}
+
+// ExpandBatchTemplate makes count sets of launch values from the template, each with its own user_id,
+// ru_ref and tx_id so that every token starts a separate session. Any of these in the template are replaced.
+func ExpandBatchTemplate(template url.Values, count int) []url.Values {
+	// ru_refs are 11 digits and a check letter, numbered on from a random start so they are unique within the batch
+	ruRefStart := rand.Int63n(50000000000) + 10000000000
+
+	sets := make([]url.Values, count)
+	for i := range sets {
+		values := url.Values{}
+		for key, templateValues := range template {
+			values[key] = append([]string{}, templateValues...)
+		}
+
+		userID, _ := newUUID()
+		values.Set("user_id", userID.String())
+		values.Set("ru_ref", fmt.Sprintf("%011dA", ruRefStart+int64(i)))
+		values.Del("tx_id")
+
+		sets[i] = values
+	}
+
+	return sets
+}
@@ -0,0 +1,54 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BenchmarkTokens signs and encrypts count tokens with the configured keys and algorithms, at most concurrency at
+// once, returning how long each took. The claims are the defaults of a launch without a schema, so that no
+// schema is fetched and only token creation is timed.
+func BenchmarkTokens(count int, concurrency int) ([]time.Duration, string) {
+	target := defaultTokenTarget()
+	if _, keyErr := target.signingKey(); keyErr != nil {
+		return nil, fmt.Sprintf("BenchmarkTokens failed err: %v", &TokenError{Desc: "Error loading signing key", From: keyErr})
+	}
+	if _, keyErr := target.encryptionKey(); keyErr != nil {
+		return nil, fmt.Sprintf("BenchmarkTokens failed err: %v", &TokenError{Desc: "Error loading encryption key", From: keyErr})
+	}
+
+	latencies := make([]time.Duration, count)
+	errors := make([]*TokenError, count)
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i := 0; i < count; i++ {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			claims := GenerateJwtClaims()
+			for key, value := range GetDefaultValues() {
+				claims[key] = value
+			}
+
+			start := time.Now()
+			_, _, errors[i] = signAndEncryptClaims(context.Background(), claims, target)
+			latencies[i] = time.Since(start)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errors {
+		if err != nil {
+			return nil, fmt.Sprintf("BenchmarkTokens failed err: %v", err)
+		}
+	}
+	return latencies, ""
+}
@@ -0,0 +1,61 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/runnertoken"
+)
+
+// useSigningWorkers sets TOKEN_SIGNING_WORKERS, making the worker pool again at that size
+func useSigningWorkers(tb testing.TB, workers int) {
+	tb.Helper()
+	useSetting(tb, "TOKEN_SIGNING_WORKERS", strconv.Itoa(workers))
+	signingWorkersOnce = sync.Once{}
+	tb.Cleanup(func() { signingWorkersOnce = sync.Once{} })
+}
+
+// BenchmarkGenerateToken signs and encrypts tokens in parallel, creating the signer and encrypter for every
+// token and then reusing them, with one worker and with several
+func BenchmarkGenerateToken(b *testing.B) {
+	useTestKeys(b)
+	logging.SetLevel(logging.WarnLevel)
+	b.Cleanup(func() { logging.SetLevel(logging.InfoLevel) })
+
+	for _, reuse := range []bool{false, true} {
+		for _, workers := range []int{1, 4} {
+			reuse, workers := reuse, workers
+			b.Run(fmt.Sprintf("reuse=%t/workers=%d", reuse, workers), func(b *testing.B) {
+				runnertoken.SetReuse(reuse)
+				b.Cleanup(func() { runnertoken.SetReuse(true) })
+				useSigningWorkers(b, workers)
+
+				target := defaultTokenTarget()
+				if _, _, err := signAndEncryptClaims(context.Background(), benchmarkClaims(), target); err != nil {
+					b.Fatal(err)
+				}
+
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						if _, _, err := signAndEncryptClaims(context.Background(), benchmarkClaims(), target); err != nil {
+							b.Error(err)
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+func benchmarkClaims() map[string]interface{} {
+	claims := GenerateJwtClaims()
+	for key, value := range GetDefaultValues() {
+		claims[key] = value
+	}
+	return claims
+}
@@ -0,0 +1,89 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// callbackToken is a token for one of the runner's callbacks about a launch which has already been made, carrying
+// the callback's role and only the claims listed in its setting
+type callbackToken struct {
+	Role          string
+	ClaimsSetting string
+}
+
+// callbackTokens are the callback tokens which can be generated, by name
+var callbackTokens = map[string]callbackToken{
+	"feedback": {Role: "feedback", ClaimsSetting: "FEEDBACK_TOKEN_CLAIMS"},
+	"receipt":  {Role: "receipt", ClaimsSetting: "RECEIPT_TOKEN_CLAIMS"},
+}
+
+// callbackTokenClaims are kept in every callback token, along with those of its setting
+var callbackTokenClaims = []string{"tx_id", "jti", "iat", "exp", "iss", "aud", "roles", "version"}
+
+// callbackIdentifierClaims are kept only when the launch values give them, as the ones generated for a new launch
+// would identify no existing case or response
+var callbackIdentifierClaims = map[string]bool{"case_id": true, "response_id": true}
+
+// CallbackTokenNames lists the callback tokens which can be generated
+func CallbackTokenNames() []string {
+	names := make([]string, 0, len(callbackTokens))
+	for name := range callbackTokens {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GenerateCallbackTokenFromPost converts a set of POST values for an existing launch, identified by its tx_id and
+// any case_id and response_id, into the named callback token, returning the claims it contains
+func GenerateCallbackTokenFromPost(ctx context.Context, name string, postValues url.Values) (string, map[string]interface{}, string) {
+	callback, ok := callbackTokens[name]
+	if !ok {
+		return "", nil, fmt.Sprintf("Unknown callback token %s, expected one of %s", name, strings.Join(CallbackTokenNames(), ", "))
+	}
+
+	txID := postValues.Get("tx_id")
+	if txID == "" {
+		return "", nil, fmt.Sprintf("GenerateCallbackTokenFromPost failed err: tx_id of the launch is required for a %s token", name)
+	}
+
+	values := copyValues(postValues)
+	values.Set("roles", callback.Role)
+
+	target, tokenError := signingTargetFromPost(values)
+	if tokenError != nil {
+		return "", nil, fmt.Sprintf("GenerateCallbackTokenFromPost failed err: %v", tokenError)
+	}
+
+	launchClaims, error := claimsFromPost(ctx, values)
+	if error != "" {
+		return "", nil, error
+	}
+	// the callback is about the launch with this tx_id, which must not be changed by TX_ID_EMBED_METADATA
+	launchClaims["tx_id"] = txID
+
+	claims := make(map[string]interface{})
+	for _, claim := range append(callbackTokenClaims, strings.Split(settings.Get(callback.ClaimsSetting), ",")...) {
+		claim = strings.TrimSpace(claim)
+		if callbackIdentifierClaims[claim] && postValues.Get(claim) == "" {
+			continue
+		}
+		if value, ok := launchClaims[claim]; ok {
+			claims[claim] = value
+		}
+	}
+
+	token, _, tokenError := generateTokenFromClaimsForTarget(ctx, claims, target)
+	if tokenError != nil {
+		return token, nil, fmt.Sprintf("GenerateCallbackTokenFromPost failed err: %v", tokenError)
+	}
+	recordIssuedToken(claims)
+
+	return token, claims, ""
+}
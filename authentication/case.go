@@ -0,0 +1,20 @@
+package authentication
+
+import (
+	"github.com/gofrs/uuid"
+)
+
+// validateCaseID checks the case_id claim of a social survey launch is a UUID when supplied
+func validateCaseID(claims map[string]interface{}) *TokenError {
+	caseID, ok := claims["case_id"].(string)
+	if !ok || caseID == "" {
+		return nil
+	}
+
+	if _, err := uuid.FromString(caseID); err != nil {
+		return &TokenError{Desc: "case_id must be a UUID: " + caseID, From: err,
+			Fields: []FieldError{{Field: "case_id", Error: "must be a UUID"}}}
+	}
+
+	return nil
+}
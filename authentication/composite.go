@@ -0,0 +1,47 @@
+package authentication
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+var compositeReferencePlaceholder = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// addCompositeReference adds the COMPOSITE_REFERENCE_CLAIM built from COMPOSITE_REFERENCE_TEMPLATE.
+// The claim is omitted when any referenced claim is empty, as a partial reference can't be receipted.
+func addCompositeReference(claims map[string]interface{}) {
+	claimName := settings.Get("COMPOSITE_REFERENCE_CLAIM")
+	template := settings.Get("COMPOSITE_REFERENCE_TEMPLATE")
+	if claimName == "" || template == "" {
+		return
+	}
+
+	reference, missing := composeReference(template, claims)
+	if len(missing) > 0 {
+		logging.Info("Omitting composite reference claim, referenced claims are empty", "claim", claimName, "missing", missing)
+		return
+	}
+
+	claims[claimName] = reference
+}
+
+// composeReference substitutes each {claim} placeholder in the template, returning any claims that were empty
+func composeReference(template string, claims map[string]interface{}) (string, []string) {
+	var missing []string
+
+	reference := compositeReferencePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := compositeReferencePlaceholder.FindStringSubmatch(placeholder)[1]
+
+		value, ok := claims[name]
+		if !ok || value == nil || value == "" {
+			missing = append(missing, name)
+			return ""
+		}
+		return fmt.Sprint(value)
+	})
+
+	return reference, missing
+}
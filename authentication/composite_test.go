@@ -0,0 +1,60 @@
+package authentication
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddCompositeReference(t *testing.T) {
+	// An empty template leaves COMPOSITE_REFERENCE_TEMPLATE at its default
+	tests := []struct {
+		name     string
+		template string
+		claims   map[string]interface{}
+		want     interface{}
+	}{
+		{"default template", "", map[string]interface{}{"ru_ref": "12346789012A", "period_id": "201605"}, "12346789012A201605"},
+		{"missing placeholder value", "", map[string]interface{}{"ru_ref": "12346789012A"}, nil},
+		{"empty placeholder value", "", map[string]interface{}{"ru_ref": "12346789012A", "period_id": ""}, nil},
+		{"literal text", "REF-{ru_ref}/{period_id}", map[string]interface{}{"ru_ref": "12346789012A", "period_id": "201605"}, "REF-12346789012A/201605"},
+		{"literal text only", "census", map[string]interface{}{}, "census"},
+		{"non-string value", "{ru_ref}-{form_number}", map[string]interface{}{"ru_ref": "12346789012A", "form_number": 7}, "12346789012A-7"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useSetting(t, "COMPOSITE_REFERENCE_CLAIM", "composite_reference")
+			if test.template != "" {
+				useSetting(t, "COMPOSITE_REFERENCE_TEMPLATE", test.template)
+			}
+
+			addCompositeReference(test.claims)
+
+			if got := test.claims["composite_reference"]; got != test.want {
+				t.Errorf("composite_reference = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAddCompositeReferenceUnconfigured(t *testing.T) {
+	useSetting(t, "COMPOSITE_REFERENCE_CLAIM", "")
+
+	claims := map[string]interface{}{"ru_ref": "12346789012A", "period_id": "201605"}
+	addCompositeReference(claims)
+
+	if len(claims) != 2 {
+		t.Errorf("claims = %v, want no composite reference without COMPOSITE_REFERENCE_CLAIM", claims)
+	}
+}
+
+func TestComposeReferenceMissing(t *testing.T) {
+	reference, missing := composeReference("{ru_ref}{period_id}{case_id}", map[string]interface{}{"period_id": "201605", "case_id": nil})
+
+	if reference != "201605" {
+		t.Errorf("reference = %q, want 201605", reference)
+	}
+	if !reflect.DeepEqual(missing, []string{"ru_ref", "case_id"}) {
+		t.Errorf("missing = %v, want [ru_ref case_id]", missing)
+	}
+}
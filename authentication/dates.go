@@ -0,0 +1,194 @@
+package authentication
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// dateClaims are the ISO 8601 date claims which the runner expects as a bare YYYY-MM-DD
+var dateClaims = []string{"employment_date", "ref_p_start_date", "ref_p_end_date", "return_by"}
+
+const isoDateLayout = "2006-01-02"
+
+// normalizeDateClaims resolves relative date expressions and strips any time or timezone component from the
+// date claims, naming every invalid date
+func normalizeDateClaims(claims map[string]interface{}) *TokenError {
+	var invalid []string
+	var fields []FieldError
+	var firstErr error
+
+	for _, name := range dateClaims {
+		value, ok := claims[name].(string)
+		if !ok || value == "" {
+			continue
+		}
+
+		date := value
+		if resolved, ok := resolveRelativeDate(value, time.Now()); ok {
+			date = resolved
+		} else if i := strings.IndexAny(date, "T "); i != -1 {
+			date = date[:i]
+		}
+
+		if _, err := time.Parse(isoDateLayout, date); err != nil {
+			invalid = append(invalid, "Invalid date for "+name+": "+value)
+			fields = append(fields, FieldError{Field: name, Error: "must be an ISO 8601 date (YYYY-MM-DD) or a relative date such as today+30d"})
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		claims[name] = date
+	}
+
+	if len(invalid) > 0 {
+		return &TokenError{Desc: strings.Join(invalid, "; "), From: firstErr, Fields: fields}
+	}
+
+	return nil
+}
+
+var relativeDateRegex = regexp.MustCompile(`^(today|start_of_month|end_of_month|start_of_year|end_of_year)((?:[+-]\d+[dwmy])*)$`)
+
+var relativeDateOffsetRegex = regexp.MustCompile(`([+-]\d+)([dwmy])`)
+
+// resolveRelativeDate resolves an expression such as today+30d or start_of_month-1y to the date it names
+// relative to now, so that saved launches don't go stale. Offsets of days, weeks, months and years are
+// applied in order.
+func resolveRelativeDate(expression string, now time.Time) (string, bool) {
+	match := relativeDateRegex.FindStringSubmatch(strings.ToLower(strings.TrimSpace(expression)))
+	if match == nil {
+		return "", false
+	}
+
+	date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch match[1] {
+	case "start_of_month":
+		date = date.AddDate(0, 0, 1-date.Day())
+	case "end_of_month":
+		date = date.AddDate(0, 1, -date.Day())
+	case "start_of_year":
+		date = time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	case "end_of_year":
+		date = time.Date(date.Year(), time.December, 31, 0, 0, 0, 0, time.UTC)
+	}
+
+	for _, offset := range relativeDateOffsetRegex.FindAllStringSubmatch(match[2], -1) {
+		amount, err := strconv.Atoi(offset[1])
+		if err != nil {
+			return "", false
+		}
+		switch offset[2] {
+		case "d":
+			date = date.AddDate(0, 0, amount)
+		case "w":
+			date = date.AddDate(0, 0, 7*amount)
+		case "m":
+			date = addMonths(date, amount)
+		case "y":
+			date = addMonths(date, 12*amount)
+		}
+	}
+
+	return date.Format(isoDateLayout), true
+}
+
+// addMonths moves the date by whole months, keeping to the last day of the month when the day does not
+// exist in the new month or the date was the last day of its month
+func addMonths(date time.Time, months int) time.Time {
+	firstOfMonth := date.AddDate(0, 0, 1-date.Day()).AddDate(0, months, 0)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+
+	day := date.Day()
+	if day > lastDay || date.AddDate(0, 0, 1).Day() == 1 {
+		day = lastDay
+	}
+
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
+
+var relativeTimestampRegex = regexp.MustCompile(`^now((?:[+-]\d+[hdwmy])*)$`)
+
+var relativeTimestampOffsetRegex = regexp.MustCompile(`([+-]\d+)([hdwmy])`)
+
+// resolveRelativeTimestamp resolves an expression such as now+2h or now+7d to the RFC3339 timestamp it names
+// relative to now, offsets of hours, days, weeks, months and years being applied in order. A relative date such
+// as end_of_month resolves to the start of that day.
+func resolveRelativeTimestamp(expression string, now time.Time) (string, bool) {
+	if date, ok := resolveRelativeDate(expression, now); ok {
+		return date + "T00:00:00Z", true
+	}
+
+	match := relativeTimestampRegex.FindStringSubmatch(strings.ToLower(strings.TrimSpace(expression)))
+	if match == nil {
+		return "", false
+	}
+
+	timestamp := now.UTC().Truncate(time.Second)
+	for _, offset := range relativeTimestampOffsetRegex.FindAllStringSubmatch(match[1], -1) {
+		amount, err := strconv.Atoi(offset[1])
+		if err != nil {
+			return "", false
+		}
+		switch offset[2] {
+		case "h":
+			timestamp = timestamp.Add(time.Duration(amount) * time.Hour)
+		case "d":
+			timestamp = timestamp.AddDate(0, 0, amount)
+		case "w":
+			timestamp = timestamp.AddDate(0, 0, 7*amount)
+		case "m":
+			timestamp = addMonths(timestamp, amount)
+		case "y":
+			timestamp = addMonths(timestamp, 12*amount)
+		}
+	}
+
+	return timestamp.Format(time.RFC3339), true
+}
+
+// applyResponseExpiresAt resolves a supplied relative response_expires_at and checks it is RFC3339, otherwise
+// setting it to RESPONSE_EXPIRY_OFFSET, or RESPONSE_EXPIRY_DAYS, after the token was issued
+func applyResponseExpiresAt(claims map[string]interface{}) *TokenError {
+	issued := time.Now()
+	if iat, ok := claims["iat"].(jwt.NumericDate); ok {
+		issued = iat.Time()
+	}
+
+	if value, ok := claims["response_expires_at"].(string); ok && value != "" {
+		if resolved, ok := resolveRelativeTimestamp(value, issued); ok {
+			claims["response_expires_at"] = resolved
+			return nil
+		}
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return &TokenError{Desc: "Invalid RFC3339 timestamp for response_expires_at: " + value, From: err,
+				Fields: []FieldError{{Field: "response_expires_at", Error: "must be an RFC3339 timestamp or a relative time such as now+7d"}}}
+		}
+		return nil
+	}
+
+	if offset := settings.Get("RESPONSE_EXPIRY_OFFSET"); offset != "" {
+		resolved, ok := resolveRelativeTimestamp(offset, issued)
+		if !ok {
+			return &TokenError{Desc: fmt.Sprintf("RESPONSE_EXPIRY_OFFSET must be a relative time such as now+7d, got %q", offset)}
+		}
+		claims["response_expires_at"] = resolved
+		return nil
+	}
+
+	days, err := strconv.Atoi(settings.Get("RESPONSE_EXPIRY_DAYS"))
+	if err != nil || days <= 0 {
+		return &TokenError{Desc: fmt.Sprintf("RESPONSE_EXPIRY_DAYS must be a positive number of days, got %q", settings.Get("RESPONSE_EXPIRY_DAYS"))}
+	}
+
+	claims["response_expires_at"] = issued.UTC().AddDate(0, 0, days).Format(time.RFC3339)
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package authentication
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeDateClaims(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"bare date", "2016-05-01", "2016-05-01"},
+		{"UTC time", "2016-05-01T00:00:00Z", "2016-05-01"},
+		{"time with offset", "2016-05-01T23:30:00+01:00", "2016-05-01"},
+		{"fractional seconds", "2016-05-01T12:00:00.123Z", "2016-05-01"},
+		{"space separated time", "2016-05-01 12:00:00", "2016-05-01"},
+		{"time without zone", "2016-05-01T12:00", "2016-05-01"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := map[string]interface{}{}
+			for _, name := range dateClaims {
+				claims[name] = test.value
+			}
+
+			if err := normalizeDateClaims(claims); err != nil {
+				t.Fatalf("normalizeDateClaims failed: %v", err)
+			}
+			for _, name := range dateClaims {
+				if claims[name] != test.want {
+					t.Errorf("%s = %v, want %s", name, claims[name], test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeDateClaimsInvalid(t *testing.T) {
+	claims := map[string]interface{}{
+		"ref_p_start_date": "2016-13-01T00:00:00Z",
+		"ref_p_end_date":   "01/05/2016",
+		"employment_date":  "2016-05-01T00:00:00Z",
+	}
+
+	err := normalizeDateClaims(claims)
+	if err == nil {
+		t.Fatal("normalizeDateClaims succeeded with invalid dates")
+	}
+
+	want := "Invalid date for ref_p_start_date: 2016-13-01T00:00:00Z; Invalid date for ref_p_end_date: 01/05/2016"
+	if err.Desc != want {
+		t.Errorf("error = %q, want %q", err.Desc, want)
+	}
+	var fields []string
+	for _, field := range err.Fields {
+		fields = append(fields, field.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"ref_p_start_date", "ref_p_end_date"}) {
+		t.Errorf("fields = %v, want ref_p_start_date and ref_p_end_date", fields)
+	}
+	if claims["ref_p_end_date"] != "01/05/2016" {
+		t.Errorf("invalid ref_p_end_date changed to %v", claims["ref_p_end_date"])
+	}
+}
+
+func TestNormalizeDateClaimsIgnoresOtherClaims(t *testing.T) {
+	claims := map[string]interface{}{"response_expires_at": "2016-05-01T12:00:00Z", "ref_p_start_date": ""}
+
+	if err := normalizeDateClaims(claims); err != nil {
+		t.Fatalf("normalizeDateClaims failed: %v", err)
+	}
+	if claims["response_expires_at"] != "2016-05-01T12:00:00Z" || claims["ref_p_start_date"] != "" {
+		t.Errorf("claims = %v, want them unchanged", claims)
+	}
+}
@@ -0,0 +1,122 @@
+package authentication
+
+import (
+	"crypto"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// DecodeToken decrypts a token with the configured decryption key, verifies its signature and returns its claims
+func DecodeToken(token string) (map[string]interface{}, *TokenError) {
+	claims, _, err := DecodeTokenWithKeys(token)
+	return claims, err
+}
+
+// DecodeTokenWithKeys decodes the token as DecodeToken does, also reporting the kids in its headers
+// and which configured decryption key was able to decrypt it
+func DecodeTokenWithKeys(token string) (map[string]interface{}, TokenKeys, *TokenError) {
+	token, unwrapErr := UnwrapToken(token)
+	if unwrapErr != nil {
+		return nil, TokenKeys{}, unwrapErr
+	}
+
+	encrypted, err := jose.ParseEncrypted(token)
+	if err != nil {
+		return nil, TokenKeys{}, &TokenError{Desc: "Error parsing JWE", From: err}
+	}
+
+	keys := TokenKeys{}
+	if encrypted.Header.KeyID != "" {
+		keys.EncryptionKids = append(keys.EncryptionKids, encrypted.Header.KeyID)
+	}
+
+	decryptionKeyPaths := settings.Get("JWT_DECRYPTION_KEY_PATH")
+	if decryptionKeyPaths == "" {
+		return nil, keys, &TokenError{Desc: "No JWT_DECRYPTION_KEY_PATH configured"}
+	}
+
+	var signed *jwt.JSONWebToken
+	var decryptErr error
+	for _, decryptionKeyPath := range strings.Split(decryptionKeyPaths, ",") {
+		decryptionKeyPath = strings.TrimSpace(decryptionKeyPath)
+
+		decryptionKey, keyErr := loadDecryptionKeyFromFile(decryptionKeyPath)
+		if keyErr != nil {
+			return nil, keys, &TokenError{Desc: "Error loading decryption key", From: keyErr}
+		}
+
+		// DecryptMulti also decrypts tokens encrypted for several recipients, with any one of their keys
+		_, recipientHeader, payload, err := encrypted.DecryptMulti(decryptionKey)
+		if decryptErr = err; err != nil {
+			continue
+		}
+		if signed, decryptErr = jwt.ParseSigned(string(payload)); decryptErr == nil {
+			keys.DecryptionKey = decryptionKeyPath
+			if recipientHeader.KeyID != "" && len(keys.EncryptionKids) == 0 {
+				keys.EncryptionKids = append(keys.EncryptionKids, recipientHeader.KeyID)
+			}
+			break
+		}
+	}
+	if signed == nil {
+		return nil, keys, &TokenError{Desc: "No configured decryption key could decrypt the token", From: decryptErr}
+	}
+
+	for _, header := range signed.Headers {
+		keys.SigningKid = header.KeyID
+	}
+
+	verificationKey, keyErr := loadVerificationKey()
+	if keyErr != nil {
+		return nil, keys, &TokenError{Desc: "Error loading verification key", From: keyErr}
+	}
+
+	var claims map[string]interface{}
+	var registeredClaims jwt.Claims
+	if err := signed.Claims(verificationKey, &claims, &registeredClaims); err != nil {
+		return nil, keys, &TokenError{Desc: "Invalid token signature", From: err}
+	}
+
+	if err := registeredClaims.Validate(jwt.Expected{Time: time.Now()}); err != nil {
+		return claims, keys, &TokenError{Desc: "Token is not currently valid", From: err}
+	}
+
+	return claims, keys, nil
+}
+
+func loadDecryptionKeyFromFile(decryptionKeyPath string) (crypto.Signer, *KeyLoadError) {
+	keyData, err := ioutil.ReadFile(decryptionKeyPath)
+	if err != nil {
+		return nil, &KeyLoadError{Op: "read", Err: "Failed to read decryption key from file: " + decryptionKeyPath}
+	}
+
+	decryptionKey, keyErr := parseSigningKey(keyData, "parse")
+	if keyErr != nil {
+		return nil, keyErr
+	}
+
+	return decryptionKey.key, nil
+}
+
+// loadVerificationKey loads JWT_VERIFICATION_KEY_PATH, or the public half of the signing key when that is unset
+func loadVerificationKey() (crypto.PublicKey, *KeyLoadError) {
+	verificationKeyPath := settings.Get("JWT_VERIFICATION_KEY_PATH")
+	if verificationKeyPath == "" {
+		signingKey, keyErr := loadSigningKey()
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		return signingKey.key.Public(), nil
+	}
+
+	verificationKey, keyErr := loadEncryptionKeyFromFile(verificationKeyPath)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	return verificationKey.key, nil
+}
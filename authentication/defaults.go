@@ -0,0 +1,86 @@
+package authentication
+
+import (
+	"net/url"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// claimDefaults parses CLAIM_DEFAULTS, a JSON object of claim name to a value, or a list of values for
+// claims such as roles which take several
+func claimDefaults() (url.Values, *TokenError) {
+	return parseClaimDefaults(settings.Get("CLAIM_DEFAULTS"))
+}
+
+// CheckClaimDefaults reports why a value for CLAIM_DEFAULTS is invalid, or an empty string when it is valid
+func CheckClaimDefaults(claimDefaultsJSON string) string {
+	if _, tokenErr := parseClaimDefaults(claimDefaultsJSON); tokenErr != nil {
+		return tokenErr.Error()
+	}
+	return ""
+}
+
+func parseClaimDefaults(claimDefaultsJSON string) (url.Values, *TokenError) {
+	defaults := url.Values{}
+
+	if claimDefaultsJSON == "" {
+		return defaults, nil
+	}
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(claimDefaultsJSON), &parsed); err != nil {
+		return nil, &TokenError{Desc: "CLAIM_DEFAULTS must be a JSON object of claim name to value", From: err}
+	}
+
+	for name, value := range parsed {
+		switch value := value.(type) {
+		case string:
+			defaults.Set(name, value)
+		case []interface{}:
+			for _, item := range value {
+				item, ok := item.(string)
+				if !ok {
+					return nil, &TokenError{Desc: "CLAIM_DEFAULTS lists may only hold strings, for " + name}
+				}
+				defaults.Add(name, item)
+			}
+		default:
+			return nil, &TokenError{Desc: "CLAIM_DEFAULTS values must be strings or lists of strings, for " + name}
+		}
+	}
+
+	return defaults, nil
+}
+
+// ClaimDefaults returns the configured claim defaults for the launch form to start from, or none when
+// CLAIM_DEFAULTS is invalid
+func ClaimDefaults() url.Values {
+	defaults, tokenErr := claimDefaults()
+	if tokenErr != nil {
+		logging.Error("Failed to read claim defaults", "err", tokenErr)
+		return url.Values{}
+	}
+	return defaults
+}
+
+// withClaimDefaults fills any claims configured by CLAIM_DEFAULTS which the launch leaves out or empty
+func withClaimDefaults(postValues url.Values) (url.Values, *TokenError) {
+	defaults, tokenErr := claimDefaults()
+	if tokenErr != nil || len(defaults) == 0 {
+		return postValues, tokenErr
+	}
+
+	filled := url.Values{}
+	for key, values := range postValues {
+		filled[key] = values
+	}
+	for key, values := range defaults {
+		if filled.Get(key) == "" {
+			filled[key] = values
+		}
+	}
+
+	return filled, nil
+}
@@ -0,0 +1,138 @@
+package authentication
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/reload"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// Environment is a runner deployment which the launcher can launch into, with the key its runner decrypts with
+type Environment struct {
+	RunnerURL         string `json:"runner_url"`
+	EncryptionKeyPath string `json:"encryption_key_path"`
+	SigningKeyPath    string `json:"signing_key_path"`
+	SigningKid        string `json:"signing_kid"`
+}
+
+// environmentField is the launch value selecting the environment. Like kid it is not a claim.
+const environmentField = "environment"
+
+var (
+	environments      = map[string]Environment{}
+	environmentsMutex sync.RWMutex
+)
+
+func init() {
+	if err := loadEnvironments(); err != nil {
+		logging.Error("Failed to load environments", "err", err)
+	}
+	reload.Register("environments", loadEnvironments, settings.Get("ENVIRONMENTS_PATH"))
+}
+
+// loadEnvironments reads the environments from ENVIRONMENTS_PATH, keeping the current environments on failure
+func loadEnvironments() error {
+	environmentsPath := settings.Get("ENVIRONMENTS_PATH")
+	if environmentsPath == "" {
+		return nil
+	}
+
+	environmentsJSON, err := ioutil.ReadFile(environmentsPath)
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]Environment)
+	if err := json.Unmarshal(environmentsJSON, &loaded); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", environmentsPath, err)
+	}
+
+	for name, environment := range loaded {
+		if environment.RunnerURL == "" {
+			return fmt.Errorf("environment %s in %s has no runner_url", name, environmentsPath)
+		}
+	}
+
+	environmentsMutex.Lock()
+	defer environmentsMutex.Unlock()
+
+	environments = loaded
+
+	return nil
+}
+
+// EnvironmentNames returns the sorted names of the configured environments
+func EnvironmentNames() []string {
+	environmentsMutex.RLock()
+	defer environmentsMutex.RUnlock()
+
+	names := []string{}
+	for name := range environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// RunnerURLFromPost returns the runner URL of the environment selected by the launch values,
+// or SURVEY_RUNNER_URL when none is selected
+func RunnerURLFromPost(postValues url.Values) (string, string) {
+	environment, tokenErr := environmentFromPost(postValues)
+	if tokenErr != nil {
+		return "", tokenErr.Error()
+	}
+	if environment == nil {
+		return settings.Get("SURVEY_RUNNER_URL"), ""
+	}
+	return environment.RunnerURL, ""
+}
+
+// environmentFromPost returns the environment selected by the launch values, or nil when none is selected
+func environmentFromPost(postValues url.Values) (*Environment, *TokenError) {
+	return configuredEnvironment(postValues.Get(environmentField))
+}
+
+// configuredEnvironment returns the named environment, or nil when no name is given
+func configuredEnvironment(name string) (*Environment, *TokenError) {
+	if name == "" {
+		return nil, nil
+	}
+
+	environmentsMutex.RLock()
+	environment, ok := environments[name]
+	environmentsMutex.RUnlock()
+
+	if !ok {
+		return nil, &TokenError{Desc: "Unknown environment: " + name,
+			Fields: []FieldError{{Field: environmentField, Error: "must be a configured environment"}}}
+	}
+
+	return &environment, nil
+}
+
+// withEnvironment sets the keys of the environment on the target. A signing key without a
+// signing_kid has its kid derived from the key.
+func (t TokenTarget) withEnvironment(environment *Environment) TokenTarget {
+	if environment == nil {
+		return t
+	}
+
+	if environment.EncryptionKeyPath != "" {
+		t.encryptionKeyPath = environment.EncryptionKeyPath
+	}
+	if environment.SigningKeyPath != "" {
+		t.signingKeyPath = environment.SigningKeyPath
+		t.SigningKid = environment.SigningKid
+	} else if environment.SigningKid != "" {
+		t.SigningKid = environment.SigningKid
+	}
+
+	return t
+}
@@ -0,0 +1,158 @@
+package authentication
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// Faults which can be deliberately injected into a token when FAULT_INJECTION is enabled
+const (
+	FaultWrongKid     = "wrong_kid"
+	FaultExpired      = "expired"
+	FaultBadSignature = "bad_signature"
+
+	// FaultWrongSigningKey signs with a throwaway key while keeping the configured key's kid
+	FaultWrongSigningKey = "wrong_signing_key"
+
+	// FaultMissingClaim drops the claim named by fault_claim, or the first of REQUIRED_CLAIMS
+	FaultMissingClaim = "missing_claim"
+)
+
+// faultClaimField is the launch value naming the claim dropped by FaultMissingClaim. It is not a claim.
+const faultClaimField = "fault_claim"
+
+const (
+	faultInjectionKid  = "fault-injection-unknown-kid"
+	faultExpiredOffset = time.Hour
+)
+
+var supportedFaults = map[string]bool{
+	FaultWrongKid:        true,
+	FaultExpired:         true,
+	FaultBadSignature:    true,
+	FaultWrongSigningKey: true,
+	FaultMissingClaim:    true,
+}
+
+// FaultInjectionEnabled reports whether faulty tokens may be generated.
+// Only the exact value "true" enables it so that it cannot be switched on by accident.
+func FaultInjectionEnabled() bool {
+	return settings.Get("FAULT_INJECTION") == "true"
+}
+
+// GenerateFaultyTokenFromPost converts a set of POST values into a deliberately malformed JWT
+func GenerateFaultyTokenFromPost(postValues url.Values, fault string) (string, string) {
+	if !FaultInjectionEnabled() {
+		return "", "Fault injection is disabled"
+	}
+
+	if !supportedFaults[fault] {
+		return "", "Unsupported fault: " + fault
+	}
+
+	target, tokenError := signingTargetFromPost(postValues)
+	if tokenError != nil {
+		return "", "GenerateFaultyTokenFromPost failed err: " + tokenError.Error()
+	}
+	target.fault = fault
+
+	claimValues := url.Values{}
+	for key, values := range postValues {
+		if key != faultClaimField {
+			claimValues[key] = values
+		}
+	}
+
+	claims, error := claimsFromPost(context.Background(), claimValues)
+	if error != "" {
+		return "", error
+	}
+
+	if fault == FaultMissingClaim {
+		claim := missingClaimFault(postValues)
+		if claim == "" {
+			return "", "GenerateFaultyTokenFromPost failed err: " + faultClaimField + " is required when REQUIRED_CLAIMS is empty"
+		}
+		delete(claims, claim)
+		logging.Warn("Dropping claim for injected fault", "claim", claim)
+	}
+
+	logging.Warn("Generating token with injected fault", "fault", fault)
+
+	token, _, tokenError := generateTokenFromClaimsForTarget(context.Background(), claims, target)
+	if tokenError != nil {
+		return token, "GenerateFaultyTokenFromPost failed err: " + tokenError.Error()
+	}
+	recordIssuedToken(claims)
+
+	return token, ""
+}
+
+// missingClaimFault is the claim to drop from a token, fault_claim when given and otherwise the first of REQUIRED_CLAIMS
+func missingClaimFault(postValues url.Values) string {
+	if claim := postValues.Get(faultClaimField); claim != "" {
+		return claim
+	}
+	for _, claim := range strings.Split(settings.Get("REQUIRED_CLAIMS"), ",") {
+		if claim = strings.TrimSpace(claim); claim != "" {
+			return claim
+		}
+	}
+	return ""
+}
+
+// faultSigningKey generates a throwaway key of the same type and size as the signing key, so that a token
+// signed with it looks genuine but fails verification
+func faultSigningKey(key crypto.Signer) (crypto.Signer, error) {
+	switch publicKey := key.Public().(type) {
+	case *rsa.PublicKey:
+		return rsa.GenerateKey(rand.Reader, publicKey.N.BitLen())
+	case *ecdsa.PublicKey:
+		return ecdsa.GenerateKey(publicKey.Curve, rand.Reader)
+	}
+	return nil, errors.New("unsupported signing key type")
+}
+
+// expireClaims moves the issue and expiry times of the claims into the past
+func expireClaims(cl map[string]interface{}) map[string]interface{} {
+	expired := make(map[string]interface{}, len(cl))
+	for key, value := range cl {
+		expired[key] = value
+	}
+
+	issued := time.Now().Add(-2 * faultExpiredOffset)
+	expired["iat"] = jwt.NewNumericDate(issued)
+	expired["exp"] = jwt.NewNumericDate(issued.Add(faultExpiredOffset))
+
+	return expired
+}
+
+// corruptSignature flips the bits of the first byte of a compact JWS signature
+func corruptSignature(compactJWS string) (string, error) {
+	parts := strings.Split(compactJWS, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed compact JWS")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) == 0 {
+		return "", errors.New("malformed JWS signature")
+	}
+
+	signature[0] ^= 0xff
+	parts[2] = base64.RawURLEncoding.EncodeToString(signature)
+
+	return strings.Join(parts, "."), nil
+}
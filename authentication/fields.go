@@ -0,0 +1,60 @@
+package authentication
+
+import (
+	"net/url"
+)
+
+// FieldError names a launch value which failed validation and why
+type FieldError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+// ValidateLaunchValues checks the format of the dates, identifiers, URLs, language, region and additional claims in the
+// launch values, that the language suits the region, that any environment and region preset are configured and that
+// any reissue_jti was issued, returning every offending field. Unlike token generation it does not stop at the first
+// failing check.
+// Missing claims are not reported, as the schema and validation profile may yet supply them.
+func ValidateLaunchValues(postValues url.Values) []FieldError {
+	var fields []FieldError
+	if presetValues, err := withRegionPreset(postValues); err != nil {
+		fields = append(fields, err.Fields...)
+	} else {
+		postValues = presetValues
+	}
+
+	claims := make(map[string]interface{})
+	for key, values := range postValues {
+		if len(values) > 0 && values[0] != "" {
+			claims[key] = values[0]
+		}
+	}
+
+	checks := []func(map[string]interface{}) *TokenError{
+		normalizeDateClaims,
+		applyResponseExpiresAt,
+		validateAccountID,
+		applyAccountServiceURLs,
+		validateCaseID,
+		validateSDSDatasetID,
+		validateLanguageCode,
+		validateRegionCode,
+		validateRegionLanguage,
+		applyReissuedJTI,
+		applyClockSkew,
+	}
+
+	for _, check := range checks {
+		if err := check(claims); err != nil {
+			fields = append(fields, err.Fields...)
+		}
+	}
+	if err := mergeAdditionalClaims(make(map[string]interface{}), postValues); err != nil {
+		fields = append(fields, err.Fields...)
+	}
+	if _, err := environmentFromPost(postValues); err != nil {
+		fields = append(fields, err.Fields...)
+	}
+
+	return fields
+}
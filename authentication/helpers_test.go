@@ -0,0 +1,65 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// useSetting overrides a setting until the test ends
+func useSetting(tb testing.TB, name string, value string) {
+	tb.Helper()
+	if err := settings.Override(name, value); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { settings.ClearOverride(name) })
+}
+
+// useTestKeys signs and encrypts with the keys in jwt-test-keys, whose default paths are relative to the
+// repository root rather than this package
+func useTestKeys(tb testing.TB) {
+	tb.Helper()
+	useSetting(tb, "JWT_SIGNING_KEY_PATH", "../jwt-test-keys/sdc-user-authentication-signing-launcher-private-key.pem")
+	useSetting(tb, "JWT_ENCRYPTION_KEY_PATH", "../jwt-test-keys/sdc-user-authentication-encryption-sr-public-key.pem")
+}
+
+// useGeneratedEncryptionKey encrypts with the public half of a new RSA key, and decodes with its private half
+func useGeneratedEncryptionKey(tb testing.TB) {
+	tb.Helper()
+	encryptionKeyPath, decryptionKeyPath := generateEncryptionKey(tb)
+	useSetting(tb, "JWT_ENCRYPTION_KEY_PATH", encryptionKeyPath)
+	useSetting(tb, "JWT_DECRYPTION_KEY_PATH", decryptionKeyPath)
+}
+
+// generateEncryptionKey writes a new RSA key, returning the paths of its public and private halves
+func generateEncryptionKey(tb testing.TB) (string, string) {
+	tb.Helper()
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	dir := tb.TempDir()
+	encryptionKeyPath := filepath.Join(dir, "encryption-key.pem")
+	decryptionKeyPath := filepath.Join(dir, "decryption-key.pem")
+	writePEM(tb, encryptionKeyPath, "PUBLIC KEY", publicKey)
+	writePEM(tb, decryptionKeyPath, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(privateKey))
+	return encryptionKeyPath, decryptionKeyPath
+}
+
+func writePEM(tb testing.TB, path string, blockType string, der []byte) {
+	tb.Helper()
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		tb.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+package authentication
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// IssuedToken is a token the launcher has issued since it started. Reissued tokens repeat the jti of an earlier
+// token, and duplicate tx_ids repeat its tx_id, as a launch which sets tx_id may.
+type IssuedToken struct {
+	JTI           string    `json:"jti"`
+	TxID          string    `json:"tx_id"`
+	IssuedAt      time.Time `json:"issued_at"`
+	Reissued      bool      `json:"reissued"`
+	DuplicateTxID bool      `json:"duplicate_tx_id"`
+}
+
+// reissueJTIField is the launch value naming a previously issued jti to issue again, or last for the most recent
+const reissueJTIField = "reissue_jti"
+
+var (
+	issuedTokens      []IssuedToken
+	issuedJTIs        = make(map[string]bool)
+	issuedTxIDs       = make(map[string]bool)
+	issuedTokensMutex sync.Mutex
+)
+
+// JTIReissueEnabled reports whether a launch may reissue a previous jti with reissue_jti, to test the runner's
+// replay protection. Only the exact value "true" enables it so that it cannot be switched on by accident.
+func JTIReissueEnabled() bool {
+	return settings.Get("JTI_REISSUE_ENABLED") == "true"
+}
+
+// IssuedTokens returns the tokens issued since the launcher started, newest first
+func IssuedTokens() []IssuedToken {
+	issuedTokensMutex.Lock()
+	defer issuedTokensMutex.Unlock()
+
+	list := make([]IssuedToken, 0, len(issuedTokens))
+	for i := len(issuedTokens) - 1; i >= 0; i-- {
+		list = append(list, issuedTokens[i])
+	}
+	return list
+}
+
+// recordIssuedToken adds the jti and tx_id of a token to the issued tokens, warning when either was issued before
+func recordIssuedToken(claims map[string]interface{}) {
+	jti, _ := claims["jti"].(string)
+	txID, _ := claims["tx_id"].(string)
+
+	issuedTokensMutex.Lock()
+	defer issuedTokensMutex.Unlock()
+
+	issued := IssuedToken{JTI: jti, TxID: txID, IssuedAt: time.Now().UTC(), Reissued: issuedJTIs[jti], DuplicateTxID: issuedTxIDs[txID]}
+	if issued.Reissued {
+		logging.Warn("Reissued an earlier jti to test replay protection", "jti", jti, "tx_id", txID)
+	}
+	if issued.DuplicateTxID {
+		logging.Warn("Issued a token repeating an earlier tx_id", "tx_id", txID, "jti", jti)
+	}
+
+	issuedTokens = append(issuedTokens, issued)
+	issuedJTIs[jti] = true
+	issuedTxIDs[txID] = true
+}
+
+// applyReissuedJTI replaces the generated jti with the previously issued jti named by reissue_jti, which is not a
+// claim itself
+func applyReissuedJTI(claims map[string]interface{}) *TokenError {
+	requested, ok := claims[reissueJTIField].(string)
+	delete(claims, reissueJTIField)
+	if !ok {
+		return nil
+	}
+
+	if !JTIReissueEnabled() {
+		return &TokenError{Desc: "reissue_jti is disabled, JTI_REISSUE_ENABLED is not true",
+			Fields: []FieldError{{Field: reissueJTIField, Error: "is disabled, JTI_REISSUE_ENABLED is not true"}}}
+	}
+
+	issuedTokensMutex.Lock()
+	defer issuedTokensMutex.Unlock()
+
+	if requested == "last" {
+		if len(issuedTokens) == 0 {
+			return &TokenError{Desc: "reissue_jti is last but no token has been issued",
+				Fields: []FieldError{{Field: reissueJTIField, Error: "no token has been issued"}}}
+		}
+		requested = issuedTokens[len(issuedTokens)-1].JTI
+	}
+
+	if !issuedJTIs[requested] {
+		return &TokenError{Desc: "reissue_jti was not issued by this launcher: " + requested,
+			Fields: []FieldError{{Field: reissueJTIField, Error: "was not issued by this launcher"}}}
+	}
+
+	claims["jti"] = requested
+	return nil
+}
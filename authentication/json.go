@@ -0,0 +1,166 @@
+package authentication
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/metrics"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// jsonObjectPrefixes are the JSON objects whose members become prefixed launch values, so that
+// {"variant_flags": {"x": true}} is read as variant_flags_x=true
+var jsonObjectPrefixes = map[string]string{
+	"variant_flags":   variantFlagsPrefix,
+	"survey_metadata": surveyMetadataPrefix,
+}
+
+// launcherFields are the launch values which the launcher reads itself, whatever the schema
+var launcherFields = []string{
+	"tx_id", "user_id", "case_id", "response_id", "collection_exercise_sid", "ru_ref", "roles", "eq_id", "form_type",
+	"schema_name", "schema_url", "cir_instrument_id", "language_code", "region_code", "channel", "version", "kid",
+	"exp", "expires_in", "response_expires_at", "account_id", "account_service_url", "account_service_log_out_url",
+	"sds_dataset_id", "validation_profile", "token_format", "verify_launch", "link_expires_in",
+	additionalClaimsField, environmentField, faultClaimField, reissueJTIField, randomiseField, regionPresetField,
+	encryptionKidField,
+}
+
+// ValuesFromJSON converts a decoded JSON object into the url.Values used by the launch form.
+// Arrays become repeated values, variant_flags and survey_metadata objects become prefixed values and
+// an additional_claims object is kept as JSON, while nulls and other nested objects are dropped.
+func ValuesFromJSON(body map[string]interface{}) url.Values {
+	values := url.Values{}
+	for key, value := range body {
+		switch v := value.(type) {
+		case nil:
+		case map[string]interface{}:
+			if key == additionalClaimsField {
+				additionalJSON, _ := json.Marshal(v)
+				values.Set(key, string(additionalJSON))
+				continue
+			}
+			prefix, ok := jsonObjectPrefixes[key]
+			if !ok {
+				continue
+			}
+			for name, member := range v {
+				if member != nil {
+					values.Set(prefix+name, fmt.Sprint(member))
+				}
+			}
+		case []interface{}:
+			for _, item := range v {
+				values.Add(key, fmt.Sprint(item))
+			}
+		default:
+			values.Set(key, fmt.Sprint(v))
+		}
+	}
+	return values
+}
+
+// valuesFromJSONBody decodes a JSON object of claim values into launch form values.
+// Numbers are kept as written so that long references such as ru_ref are not rounded.
+func valuesFromJSONBody(body []byte) (url.Values, *TokenError) {
+	var decoded map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, &TokenError{Desc: "Request body must be a JSON object of claim values", From: err, stage: metrics.StageValidation}
+	}
+	if decoded == nil {
+		return nil, &TokenError{Desc: "Request body must be a JSON object of claim values", stage: metrics.StageValidation}
+	}
+
+	return ValuesFromJSON(decoded), nil
+}
+
+// withoutUnknownValues drops the launch values which are neither read by the launcher, nor claims it has defaults
+// for or is configured to require, nor metadata of the launch's schema, so that a mistyped or unrelated JSON key is
+// ignored rather than made a claim. Values whose schema can't be found are kept, as the launch will fail anyway.
+func withoutUnknownValues(ctx context.Context, postValues url.Values) url.Values {
+	schemaValues, defaultsErr := withClaimDefaults(postValues)
+	if defaultsErr != nil {
+		return postValues
+	}
+	launcherSchema, schemaErr := launcherSchemaFromPost(schemaValues)
+	if schemaErr != nil {
+		return postValues
+	}
+	requiredMetadata, metadataErr := GetRequiredMetadataWithContext(ctx, launcherSchema)
+	if metadataErr != "" {
+		return postValues
+	}
+
+	known := make(map[string]bool)
+	for _, group := range [][]string{launcherFields, algorithmOverrideFields, socialMetadataFields, legacyVariantFlags,
+		strings.Split(settings.Get("REQUIRED_CLAIMS"), ","), validationProfileClaims()} {
+		for _, name := range group {
+			known[strings.TrimSpace(name)] = true
+		}
+	}
+	for _, field := range clockSkewFields {
+		known[field.field] = true
+	}
+	for name := range GetDefaultValues() {
+		known[name] = true
+	}
+	for _, metadata := range requiredMetadata {
+		known[metadata.Name] = true
+	}
+
+	kept := url.Values{}
+	var ignored []string
+	for key, values := range postValues {
+		if _, isFlag := variantFlagName(key); known[key] || isFlag || strings.HasPrefix(key, surveyMetadataPrefix) {
+			kept[key] = values
+		} else {
+			ignored = append(ignored, key)
+		}
+	}
+	if len(ignored) > 0 {
+		sort.Strings(ignored)
+		logging.Info("Ignoring unknown launch values", "keys", strings.Join(ignored, ","))
+	}
+	return kept
+}
+
+// ValuesFromJSONBody decodes a JSON object of claim values into launch form values, ignoring any keys which the
+// launcher and the launch's schema do not use
+func ValuesFromJSONBody(body []byte) (url.Values, string) {
+	return ValuesFromJSONBodyWithContext(context.Background(), body)
+}
+
+// ValuesFromJSONBodyWithContext decodes a JSON object of claim values like ValuesFromJSONBody, abandoning the
+// schema fetch when ctx is done
+func ValuesFromJSONBodyWithContext(ctx context.Context, body []byte) (url.Values, string) {
+	postValues, tokenError := valuesFromJSONBody(body)
+	if tokenError != nil {
+		return nil, fmt.Sprintf("ValuesFromJSONBody failed err: %v", tokenError)
+	}
+	return withoutUnknownValues(ctx, postValues), ""
+}
+
+// GenerateTokenFromJSON converts a JSON object of claim values into a JWT.
+// Keys which are not used by the launcher or the launch's schema are ignored.
+func GenerateTokenFromJSON(body []byte) (string, string) {
+	token, _, error := GenerateTokenAndClaimsFromJSON(body)
+	return token, error
+}
+
+// GenerateTokenAndClaimsFromJSON converts a JSON object of claim values into a JWT, also returning the claims it contains
+func GenerateTokenAndClaimsFromJSON(body []byte) (string, map[string]interface{}, string) {
+	postValues, tokenError := valuesFromJSONBody(body)
+	if tokenError != nil {
+		metrics.TokenFailed(tokenError.stage)
+		return "", nil, fmt.Sprintf("GenerateTokenFromJSON failed err: %v", tokenError)
+	}
+
+	return GenerateTokenAndClaimsFromPost(withoutUnknownValues(context.Background(), postValues))
+}
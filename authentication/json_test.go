@@ -0,0 +1,41 @@
+package authentication
+
+import (
+	"context"
+	"net/url"
+	"testing"
+)
+
+func TestGenerateTokenAndClaimsFromJSONIgnoresUnknownKeys(t *testing.T) {
+	useTestKeys(t)
+
+	body := `{"schema_url": "` + quickLaunchSchemaURL(t) + `", "collection_exercise_sid": "789", "ru_ref": "49900000001A",
+		"case_ref": "4f2a2b8e-3c1d-4e5f-8a9b-0c1d2e3f4a5b",
+		"ru_reff": "49900000001A", "unrelated": {"nested": true}, "flag": true, "variant_flags": {"sexual_identity": true}}`
+	_, claims, err := GenerateTokenAndClaimsFromJSON([]byte(body))
+	if err != "" {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"ru_reff", "unrelated"} {
+		if _, ok := claims[key]; ok {
+			t.Errorf("%s is a claim, want unknown keys ignored", key)
+		}
+	}
+	if claims["ru_ref"] != "49900000001A" {
+		t.Errorf("ru_ref = %v, want 49900000001A", claims["ru_ref"])
+	}
+	if claims["flag"] != true {
+		t.Errorf("flag = %v, want the schema's boolean metadata kept", claims["flag"])
+	}
+	if flags, _ := claims["variant_flags"].(map[string]interface{}); flags["sexual_identity"] != true {
+		t.Errorf("variant_flags = %v, want sexual_identity kept", claims["variant_flags"])
+	}
+}
+
+func TestWithoutUnknownValuesKeepsValuesOfUnknownSchema(t *testing.T) {
+	values := url.Values{"schema_url": {"not a url"}, "ru_reff": {"49900000001A"}}
+	if kept := withoutUnknownValues(context.Background(), values); kept.Get("ru_reff") == "" {
+		t.Error("ru_reff was dropped, want every value kept when the schema can't be found")
+	}
+}
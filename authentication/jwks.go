@@ -0,0 +1,90 @@
+package authentication
+
+import (
+	"sort"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// BuildJWKS builds a JSON Web Key Set of the public halves of the configured keys.
+//
+// The encryption key is always included. The signing keys are only included when
+// JWKS_INCLUDE_SIGNING_KEY is true, being the configured signing key, the JWT_SIGNING_KEYS
+// rotation keys under their kids and the signing keys of the environments, each kid once.
+func BuildJWKS() (jose.JSONWebKeySet, *KeyLoadError) {
+	target := defaultTokenTarget()
+
+	encryptionKey, keyErr := target.encryptionKey()
+	if keyErr != nil {
+		return jose.JSONWebKeySet{}, keyErr
+	}
+
+	encryptionJWK := jose.JSONWebKey{Key: encryptionKey.key, KeyID: encryptionKey.kid, Use: "enc"}
+	if checkEncryptionKeyAlgorithm(encryptionKey.key, jose.KeyAlgorithm(target.KeyAlgorithm)) == nil {
+		encryptionJWK.Algorithm = target.KeyAlgorithm
+	}
+
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{encryptionJWK}}
+
+	if settings.Get("JWKS_INCLUDE_SIGNING_KEY") != "true" {
+		return jwks, nil
+	}
+
+	signingTargets := []TokenTarget{target}
+
+	rotationKeys, tokenErr := rotationSigningKeys()
+	if tokenErr != nil {
+		return jose.JSONWebKeySet{}, &KeyLoadError{Op: "parse", Err: tokenErr.Error()}
+	}
+	kids := make([]string, 0, len(rotationKeys))
+	for kid := range rotationKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	for _, kid := range kids {
+		signingTargets = append(signingTargets, TokenTarget{SigningAlgorithm: target.SigningAlgorithm, signingKeyPath: rotationKeys[kid], SigningKid: kid})
+	}
+
+	for _, name := range EnvironmentNames() {
+		environmentsMutex.RLock()
+		environment := environments[name]
+		environmentsMutex.RUnlock()
+		if environment.SigningKeyPath != "" {
+			signingTargets = append(signingTargets, target.withEnvironment(&environment))
+		}
+	}
+
+	published := make(map[string]bool)
+	for _, signingTarget := range signingTargets {
+		signingJWK, keyErr := signingJWK(signingTarget)
+		if keyErr != nil {
+			return jose.JSONWebKeySet{}, keyErr
+		}
+		if !published[signingJWK.KeyID] {
+			published[signingJWK.KeyID] = true
+			jwks.Keys = append(jwks.Keys, signingJWK)
+		}
+	}
+
+	return jwks, nil
+}
+
+// signingJWK is the public half of the target's signing key, under the target's kid when it has one
+func signingJWK(target TokenTarget) (jose.JSONWebKey, *KeyLoadError) {
+	signingKey, keyErr := target.signingKey()
+	if keyErr != nil {
+		return jose.JSONWebKey{}, keyErr
+	}
+
+	signingJWK := jose.JSONWebKey{Key: signingKey.key.Public(), KeyID: signingKey.kid, Use: "sig"}
+	if target.SigningKid != "" {
+		signingJWK.KeyID = target.SigningKid
+	}
+	target = target.withKeySigningAlgorithm(signingKey.key)
+	if checkSigningKeyAlgorithm(signingKey.key, jose.SignatureAlgorithm(target.SigningAlgorithm)) == nil {
+		signingJWK.Algorithm = target.SigningAlgorithm
+	}
+
+	return signingJWK, nil
+}
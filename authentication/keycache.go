@@ -0,0 +1,153 @@
+package authentication
+
+import (
+	"sync"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/reload"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// Keys are read and parsed once, then cached by their provider's source until reloaded
+var (
+	signingKeyCache    = make(map[string]*PrivateKeyResult)
+	encryptionKeyCache = make(map[string]*PublicKeyResult)
+	keyCacheMutex      sync.RWMutex
+)
+
+func init() {
+	keyFiles := append([]string{settings.Get("JWT_SIGNING_KEY_PATH"), settings.Get("JWT_ENCRYPTION_KEY_PATH")}, rotationSigningKeyPaths()...)
+	keyFiles = append(keyFiles, rotationEncryptionKeyPaths()...)
+	reload.Register("keys", ReloadKeys, keyFiles...)
+}
+
+// signingKeySource names the configured signing key in the cache
+func signingKeySource() string {
+	if kmsKey := settings.Get("JWT_SIGNING_KMS_KEY"); kmsKey != "" {
+		return "kms:" + kmsKey
+	}
+	return signingKeyProvider().source()
+}
+
+func cachedSigningKey(source string, load func() (*PrivateKeyResult, *KeyLoadError)) (*PrivateKeyResult, *KeyLoadError) {
+	keyCacheMutex.RLock()
+	key, ok := signingKeyCache[source]
+	keyCacheMutex.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	key, keyErr := load()
+	if keyErr != nil {
+		return nil, keyErr
+	}
+
+	keyCacheMutex.Lock()
+	defer keyCacheMutex.Unlock()
+	signingKeyCache[source] = key
+
+	return key, nil
+}
+
+func cachedEncryptionKey(source string, load func() (*PublicKeyResult, *KeyLoadError)) (*PublicKeyResult, *KeyLoadError) {
+	keyCacheMutex.RLock()
+	key, ok := encryptionKeyCache[source]
+	keyCacheMutex.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	key, keyErr := load()
+	if keyErr != nil {
+		return nil, keyErr
+	}
+
+	keyCacheMutex.Lock()
+	defer keyCacheMutex.Unlock()
+	encryptionKeyCache[source] = key
+
+	return key, nil
+}
+
+// ReloadKeys discards every cached key and re-reads the configured signing and encryption keys and any
+// JWT_SIGNING_KEYS and JWT_ENCRYPTION_KEYS keys. If any of them fails to load the current cache is kept.
+func ReloadKeys() error {
+	signingKey, keyErr := readSigningKey()
+	if keyErr != nil {
+		return keyErr
+	}
+
+	encryptionKey, keyErr := readEncryptionKey()
+	if keyErr != nil {
+		return keyErr
+	}
+
+	signingKeys := map[string]*PrivateKeyResult{signingKeySource(): signingKey}
+	for _, path := range rotationSigningKeyPaths() {
+		rotationKey, keyErr := loadSigningKeyFromFile(path)
+		if keyErr != nil {
+			return keyErr
+		}
+		signingKeys[path] = rotationKey
+	}
+
+	encryptionKeys := map[string]*PublicKeyResult{encryptionKeyProvider().source(): encryptionKey}
+	for _, path := range rotationEncryptionKeyPaths() {
+		rotationKey, keyErr := loadEncryptionKeyFromFile(path)
+		if keyErr != nil {
+			return keyErr
+		}
+		encryptionKeys[path] = rotationKey
+	}
+
+	keyCacheMutex.Lock()
+	defer keyCacheMutex.Unlock()
+
+	signingKeyCache = signingKeys
+	encryptionKeyCache = encryptionKeys
+
+	return nil
+}
+
+// CheckKeys loads the configured signing and encryption keys, through the cache, reporting which one failed to load
+func CheckKeys() (string, *KeyLoadError) {
+	if _, keyErr := loadSigningKey(); keyErr != nil {
+		return "signing", keyErr
+	}
+
+	if _, keyErr := loadEncryptionKey(); keyErr != nil {
+		return "encryption", keyErr
+	}
+
+	return "", nil
+}
+
+// PreloadKeys fills the cache with the configured signing and encryption keys and any JWT_SIGNING_KEYS and
+// JWT_ENCRYPTION_KEYS keys, so that token generation does not read from disk. It reports the source of the
+// first key that failed to load.
+func PreloadKeys() (string, *KeyLoadError) {
+	if failedKey, keyErr := CheckKeys(); keyErr != nil {
+		return failedKey, keyErr
+	}
+
+	for _, path := range rotationSigningKeyPaths() {
+		keyPath := path
+		_, keyErr := cachedSigningKey(keyPath, func() (*PrivateKeyResult, *KeyLoadError) {
+			return loadSigningKeyFromFile(keyPath)
+		})
+		if keyErr != nil {
+			return keyPath, keyErr
+		}
+	}
+
+	for _, path := range rotationEncryptionKeyPaths() {
+		keyPath := path
+		_, keyErr := cachedEncryptionKey(keyPath, func() (*PublicKeyResult, *KeyLoadError) {
+			return loadEncryptionKeyFromFile(keyPath)
+		})
+		if keyErr != nil {
+			return keyPath, keyErr
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,92 @@
+package authentication
+
+import (
+	"context"
+	"io/ioutil"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/secrets"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// keyProvider supplies the PEM data of a key from where it is kept
+type keyProvider interface {
+	// source names the key in the cache
+	source() string
+	// read fetches the PEM data, returning the op to report if it fails to parse
+	read() ([]byte, string, *KeyLoadError)
+}
+
+// fileKeyProvider reads a key file
+type fileKeyProvider struct {
+	path string
+	name string
+}
+
+func (p fileKeyProvider) source() string {
+	return p.path
+}
+
+func (p fileKeyProvider) read() ([]byte, string, *KeyLoadError) {
+	keyData, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, "", &KeyLoadError{Op: "read", Err: "Failed to read " + p.name + " key from file: " + p.path}
+	}
+	return keyData, "parse", nil
+}
+
+// inlineKeyProvider reads a key from a setting, as escaped or base64 encoded PEM text
+type inlineKeyProvider struct {
+	setting string
+}
+
+func (p inlineKeyProvider) source() string {
+	return "inline:" + p.setting
+}
+
+func (p inlineKeyProvider) read() ([]byte, string, *KeyLoadError) {
+	return inlinePEM(settings.Get(p.setting)), "parse-inline", nil
+}
+
+// secretKeyProvider fetches a key from AWS Secrets Manager or GCP Secret Manager
+type secretKeyProvider struct {
+	uri  string
+	name string
+}
+
+func (p secretKeyProvider) source() string {
+	return "secret:" + p.uri
+}
+
+func (p secretKeyProvider) read() ([]byte, string, *KeyLoadError) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	secret, err := secrets.Fetch(ctx, p.uri)
+	if err != nil {
+		return nil, "", &KeyLoadError{Op: "secret", Err: "Failed to fetch " + p.name + " key secret: " + err.Error()}
+	}
+	return inlinePEM(string(secret)), "parse-secret", nil
+}
+
+// signingKeyProvider is chosen by configuration: the secret JWT_SIGNING_KEY_SECRET, the inline JWT_SIGNING_KEY,
+// otherwise the file JWT_SIGNING_KEY_PATH
+func signingKeyProvider() keyProvider {
+	return configuredKeyProvider("signing", "JWT_SIGNING_KEY_SECRET", "JWT_SIGNING_KEY", "JWT_SIGNING_KEY_PATH")
+}
+
+// encryptionKeyProvider is chosen by configuration: the secret JWT_ENCRYPTION_KEY_SECRET, the inline
+// JWT_ENCRYPTION_KEY, otherwise the file JWT_ENCRYPTION_KEY_PATH
+func encryptionKeyProvider() keyProvider {
+	return configuredKeyProvider("encryption", "JWT_ENCRYPTION_KEY_SECRET", "JWT_ENCRYPTION_KEY", "JWT_ENCRYPTION_KEY_PATH")
+}
+
+func configuredKeyProvider(name string, secretSetting string, inlineSetting string, pathSetting string) keyProvider {
+	if uri := settings.Get(secretSetting); uri != "" {
+		return secretKeyProvider{uri: uri, name: name}
+	}
+	if settings.Get(inlineSetting) != "" {
+		return inlineKeyProvider{setting: inlineSetting}
+	}
+	return fileKeyProvider{path: settings.Get(pathSetting), name: name}
+}
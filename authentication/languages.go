@@ -0,0 +1,92 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/ONSdigital/eq-questionnaire-launcher/surveys"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// languageRegionCodes are the region_code each language is launched with, where respondents answer in it
+var languageRegionCodes = map[string]string{
+	"en": defaultRegionCode,
+	"cy": "GB-WLS",
+	"ga": "GB-NIR",
+	"eo": "GB-NIR",
+}
+
+// SchemaLanguage is a language a schema is available in, with the language_code and region_code to launch it with
+type SchemaLanguage struct {
+	LanguageCode string `json:"language_code"`
+	RegionCode   string `json:"region_code"`
+	Title        string `json:"title,omitempty"`
+}
+
+// schemaLanguageFields are the fields of a schema which describe its language
+type schemaLanguageFields struct {
+	Language string `json:"language"`
+	Title    string `json:"title"`
+}
+
+// GetSchemaLanguages finds the SUPPORTED_LANGUAGE_CODES which the schema is available in. A schema loaded by name
+// is asked of the runner in each language, which falls back to English for a language it does not hold, so only
+// the languages whose schema declares that language are offered. A schema loaded from a URL is in its own language.
+func GetSchemaLanguages(ctx context.Context, launcherSchema surveys.LauncherSchema) ([]SchemaLanguage, string) {
+	if launcherSchema.URL != "" {
+		language, schemaErr := fetchSchemaLanguage(ctx, launcherSchema.URL)
+		if schemaErr != "" {
+			return nil, schemaErr
+		}
+		return []SchemaLanguage{language}, ""
+	}
+
+	languages := []SchemaLanguage{}
+	for _, languageCode := range strings.Split(settings.Get("SUPPORTED_LANGUAGE_CODES"), ",") {
+		languageCode = strings.TrimSpace(languageCode)
+		if languageCode == "" {
+			continue
+		}
+
+		schemaURL := fmt.Sprintf("%s/schemas/%s?%s", settings.Get("SURVEY_RUNNER_SCHEMA_URL"), launcherSchema.Name,
+			url.Values{"language": {languageCode}}.Encode())
+		language, schemaErr := fetchSchemaLanguage(ctx, schemaURL)
+		if schemaErr != "" {
+			return nil, schemaErr
+		}
+		if language.LanguageCode == languageCode {
+			languages = append(languages, language)
+		}
+	}
+
+	return languages, ""
+}
+
+// fetchSchemaLanguage reads the language of the schema at url, which is English when the schema does not
+// declare one
+func fetchSchemaLanguage(ctx context.Context, url string) (SchemaLanguage, string) {
+	responseBody, schemaErr := fetchSchema(ctx, url)
+	if schemaErr != "" {
+		return SchemaLanguage{}, schemaErr
+	}
+
+	var fields schemaLanguageFields
+	if err := json.Unmarshal(responseBody, &fields); err != nil {
+		return SchemaLanguage{}, fmt.Sprintf("Failed to unmarshal Schema from %s", url)
+	}
+
+	languageCode := fields.Language
+	if languageCode == "" {
+		languageCode = defaultLanguageCode
+	}
+
+	regionCode, ok := languageRegionCodes[languageCode]
+	if !ok {
+		regionCode = defaultRegionCode
+	}
+
+	return SchemaLanguage{LanguageCode: languageCode, RegionCode: regionCode, Title: fields.Title}, ""
+}
@@ -0,0 +1,66 @@
+package authentication
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+const (
+	defaultLanguageCode = "en"
+	defaultRegionCode   = "GB-ENG"
+)
+
+var regionCodeRegex = regexp.MustCompile(`^[A-Z]{2}-[A-Z0-9]{1,3}$`)
+
+// validateLanguageCode checks the language_code claim is one of SUPPORTED_LANGUAGE_CODES, defaulting it to en when empty
+func validateLanguageCode(claims map[string]interface{}) *TokenError {
+	languageCode, _ := claims["language_code"].(string)
+	if languageCode == "" {
+		claims["language_code"] = defaultLanguageCode
+		return nil
+	}
+
+	for _, supported := range strings.Split(settings.Get("SUPPORTED_LANGUAGE_CODES"), ",") {
+		if strings.TrimSpace(supported) == languageCode {
+			return nil
+		}
+	}
+
+	return &TokenError{Desc: "Unsupported language_code: " + languageCode + ", expected one of " + settings.Get("SUPPORTED_LANGUAGE_CODES"),
+		Fields: []FieldError{{Field: "language_code", Error: "must be one of " + settings.Get("SUPPORTED_LANGUAGE_CODES")}}}
+}
+
+// validateRegionCode checks the region_code claim is an ISO 3166-2 code, or one of SUPPORTED_REGION_CODES
+// when that is set, defaulting it when empty to the region of the language, or GB-ENG
+func validateRegionCode(claims map[string]interface{}) *TokenError {
+	regionCode, _ := claims["region_code"].(string)
+	if regionCode == "" {
+		languageCode, _ := claims["language_code"].(string)
+		if languageRegionCode, ok := languageRegionCodes[languageCode]; ok {
+			claims["region_code"] = languageRegionCode
+		} else {
+			claims["region_code"] = defaultRegionCode
+		}
+		return nil
+	}
+
+	supportedRegionCodes := settings.Get("SUPPORTED_REGION_CODES")
+	if supportedRegionCodes == "" {
+		if !regionCodeRegex.MatchString(regionCode) {
+			return &TokenError{Desc: "Invalid region_code: " + regionCode + ", expected an ISO 3166-2 code such as " + defaultRegionCode,
+				Fields: []FieldError{{Field: "region_code", Error: "must be an ISO 3166-2 code such as " + defaultRegionCode}}}
+		}
+		return nil
+	}
+
+	for _, supported := range strings.Split(supportedRegionCodes, ",") {
+		if strings.TrimSpace(supported) == regionCode {
+			return nil
+		}
+	}
+
+	return &TokenError{Desc: "Unsupported region_code: " + regionCode + ", expected one of " + supportedRegionCodes,
+		Fields: []FieldError{{Field: "region_code", Error: "must be one of " + supportedRegionCodes}}}
+}
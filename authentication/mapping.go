@@ -0,0 +1,130 @@
+package authentication
+
+import (
+	"net/url"
+	"sort"
+
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// ClaimMapping describes how a single form field became a claim in the generated token
+type ClaimMapping struct {
+	Field          string
+	Claim          string
+	Transformation string
+	Value          string
+}
+
+var generatedClaims = map[string]bool{
+	"tx_id": true,
+	"jti":   true,
+	"iat":   true,
+	"exp":   true,
+	"iss":   true,
+	"aud":   true,
+}
+
+// ClaimMappings describes how the submitted values were mapped onto the claims of the token made from them, with
+// the final value of each claim as it is in the token
+func ClaimMappings(values url.Values, claims map[string]interface{}) []ClaimMapping {
+	var mappings []ClaimMapping
+
+	// nested holds the claim path and value of each submitted field which became a nested claim
+	nested := map[string]ClaimMapping{}
+	switch surveyMetadata := claims["survey_metadata"].(type) {
+	case map[string]interface{}:
+		if data, ok := surveyMetadata["data"].(map[string]interface{}); ok {
+			for key, value := range data {
+				nestedClaim := ClaimMapping{Claim: "survey_metadata.data." + key, Value: claimValueString(value)}
+				nested[key] = nestedClaim
+				nested[surveyMetadataPrefix+key] = nestedClaim
+			}
+		}
+	case map[string]string:
+		for key, value := range surveyMetadata {
+			nested[surveyMetadataPrefix+key] = ClaimMapping{Claim: "survey_metadata." + key, Value: claimValueString(value)}
+		}
+	}
+
+	if variantFlags, ok := claims["variant_flags"].(map[string]interface{}); ok {
+		for key, value := range variantFlags {
+			nestedClaim := ClaimMapping{Claim: "variant_flags." + key, Value: claimValueString(value)}
+			nested[variantFlagsPrefix+key] = nestedClaim
+			nested[flagPrefix+key] = nestedClaim
+			if containsString(legacyVariantFlags, key) {
+				nested[key] = nestedClaim
+			}
+		}
+	}
+
+	for _, field := range sortedKeys(values) {
+		submitted := values.Get(field)
+		mapping := ClaimMapping{Field: field, Claim: field}
+
+		if value, ok := claims[field]; ok {
+			mapping.Value = claimValueString(value)
+			if generatedClaims[field] && mapping.Value != claimValueString(submitted) {
+				mapping.Transformation = "replaced by generated value"
+			} else if len(values[field]) > 1 || mapping.Value != claimValueString(submitted) {
+				mapping.Transformation = "transformed"
+			} else {
+				mapping.Transformation = "copied"
+			}
+		} else if nestedClaim, ok := nested[field]; ok {
+			mapping.Claim = nestedClaim.Claim
+			mapping.Transformation = "nested"
+			mapping.Value = nestedClaim.Value
+		} else if submitted == "" {
+			mapping.Claim = ""
+			mapping.Transformation = "dropped (empty)"
+		} else {
+			mapping.Claim = ""
+			mapping.Transformation = "dropped"
+		}
+
+		mappings = append(mappings, mapping)
+	}
+
+	for _, claim := range sortedKeys(claims) {
+		if _, submitted := values[claim]; submitted {
+			continue
+		}
+
+		transformation := "default"
+		if generatedClaims[claim] {
+			transformation = "generated"
+		}
+
+		mappings = append(mappings, ClaimMapping{
+			Claim:          claim,
+			Transformation: transformation,
+			Value:          claimValueString(claims[claim]),
+		})
+	}
+
+	return mappings
+}
+
+func claimValueString(value interface{}) string {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(valueJSON)
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch v := m.(type) {
+	case url.Values:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	case map[string]interface{}:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
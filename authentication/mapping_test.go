@@ -0,0 +1,32 @@
+package authentication
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestClaimMappings(t *testing.T) {
+	values := url.Values{"ru_ref": {"49900000001A"}, "roles": {"dumper flusher"}, "unused": {""}}
+	claims := map[string]interface{}{"ru_ref": "49900000001A", "roles": []string{"dumper", "flusher"}, "tx_id": "abc"}
+
+	want := map[string]ClaimMapping{
+		"ru_ref": {Field: "ru_ref", Claim: "ru_ref", Transformation: "copied", Value: `"49900000001A"`},
+		"roles":  {Field: "roles", Claim: "roles", Transformation: "transformed", Value: `["dumper","flusher"]`},
+		"unused": {Field: "unused", Transformation: "dropped (empty)"},
+		"tx_id":  {Claim: "tx_id", Transformation: "generated", Value: `"abc"`},
+	}
+
+	mappings := ClaimMappings(values, claims)
+	if len(mappings) != len(want) {
+		t.Fatalf("got %d mappings, want %d: %+v", len(mappings), len(want), mappings)
+	}
+	for _, mapping := range mappings {
+		name := mapping.Field
+		if name == "" {
+			name = mapping.Claim
+		}
+		if mapping != want[name] {
+			t.Errorf("mapping %s = %+v, want %+v", name, mapping, want[name])
+		}
+	}
+}
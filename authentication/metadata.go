@@ -0,0 +1,66 @@
+package authentication
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/gofrs/uuid"
+)
+
+// validateSchemaMetadata checks the claims carry each metadata value the schema declares, in the declared type,
+// naming every missing or invalid value. Boolean metadata is always set so is not checked.
+// It can be switched off with VALIDATE_SCHEMA_METADATA for runners which fill in metadata themselves.
+func validateSchemaMetadata(claims map[string]interface{}, requiredMetadata []Metadata) *TokenError {
+	if settings.Get("VALIDATE_SCHEMA_METADATA") != "true" {
+		return nil
+	}
+
+	var missing, invalid []string
+
+	for _, metadata := range requiredMetadata {
+		if metadata.Validator == "boolean" {
+			continue
+		}
+
+		if !hasClaim(claims, metadata.Name) {
+			missing = append(missing, metadata.Name)
+			continue
+		}
+
+		value, _ := claims[metadata.Name].(string)
+		if !validMetadataValue(metadata.Validator, value) {
+			invalid = append(invalid, metadata.Name+" ("+metadata.Validator+")")
+		}
+	}
+
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "missing "+strings.Join(missing, ", "))
+	}
+	if len(invalid) > 0 {
+		problems = append(problems, "invalid "+strings.Join(invalid, ", "))
+	}
+	if len(problems) > 0 {
+		return &TokenError{Desc: "Schema metadata " + strings.Join(problems, "; ")}
+	}
+
+	return nil
+}
+
+// validMetadataValue reports whether a value suits the schema metadata type. Dates may carry a time,
+// which is stripped later. Other types are not checked.
+func validMetadataValue(validator string, value string) bool {
+	switch validator {
+	case "date":
+		if i := strings.IndexAny(value, "T "); i != -1 {
+			value = value[:i]
+		}
+		_, err := time.Parse(isoDateLayout, value)
+		return err == nil
+	case "uuid":
+		_, err := uuid.FromString(value)
+		return err == nil
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package authentication
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/metrics"
+	"github.com/ONSdigital/eq-questionnaire-launcher/reload"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/ONSdigital/eq-questionnaire-launcher/storage"
+	"gopkg.in/square/go-jose.v2/json"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// PooledToken is a token generated from TOKEN_POOL_TEMPLATE ahead of a request for it
+type PooledToken struct {
+	Token     string
+	TxID      string
+	ExpiresAt time.Time
+	// usableUntil is half way through the token's lifetime, after which it is discarded rather than handed out
+	usableUntil time.Time
+}
+
+// storedPooledToken is a PooledToken as it is kept in the state backend
+type storedPooledToken struct {
+	Token       string    `json:"token"`
+	TxID        string    `json:"tx_id"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	UsableUntil time.Time `json:"usable_until"`
+}
+
+// tokenPoolList is the state backend list of pooled tokens, which replicas sharing a backend share
+const tokenPoolList = "token_pool"
+
+var (
+	tokenPoolValues  url.Values
+	tokenPoolOnce    sync.Once
+	tokenPoolStarted bool
+)
+
+func init() {
+	reload.Register("token_pool", DrainTokenPool)
+}
+
+func tokenPoolSize() int {
+	size, err := strconv.Atoi(settings.Get("TOKEN_POOL_SIZE"))
+	if err != nil || size < 0 {
+		return 0
+	}
+	return size
+}
+
+// TokenPoolEnabled reports whether TOKEN_POOL_SIZE asks for a pool of tokens
+func TokenPoolEnabled() bool {
+	return tokenPoolSize() > 0
+}
+
+// TokenPoolSchemaName is the schema_name of TOKEN_POOL_TEMPLATE, from which every pooled token is made
+func TokenPoolSchemaName() string {
+	return tokenPoolValues.Get("schema_name")
+}
+
+// StartTokenPool checks the launch values of TOKEN_POOL_TEMPLATE, then keeps a pool of TOKEN_POOL_SIZE tokens made
+// from it, generating at most TOKEN_POOL_REFILL_PER_SECOND tokens a second. Each token has its own tx_id and the
+// other claims generated per launch, and the template may set randomise for each to be a different respondent.
+func StartTokenPool() error {
+	if !TokenPoolEnabled() {
+		return nil
+	}
+
+	values, tokenErr := valuesFromJSONBody([]byte(settings.Get("TOKEN_POOL_TEMPLATE")))
+	if tokenErr != nil {
+		return fmt.Errorf("invalid TOKEN_POOL_TEMPLATE: %v", tokenErr)
+	}
+	if fields := ValidateLaunchValues(copyValues(values)); len(fields) > 0 {
+		return fmt.Errorf("invalid TOKEN_POOL_TEMPLATE: %s %s", fields[0].Field, fields[0].Error)
+	}
+
+	tokenPoolOnce.Do(func() {
+		tokenPoolValues = values
+		tokenPoolStarted = true
+		go refillTokenPool()
+	})
+	return nil
+}
+
+func refillPerSecond() int {
+	rate, err := strconv.Atoi(settings.Get("TOKEN_POOL_REFILL_PER_SECOND"))
+	if err != nil || rate <= 0 {
+		return 50
+	}
+	return rate
+}
+
+// refillTokenPool tops up the pool, backing off for a second after a failure so that a broken runner or key
+// does not flood the logs
+func refillTokenPool() {
+	ticker := time.NewTicker(time.Second / time.Duration(refillPerSecond()))
+	defer ticker.Stop()
+
+	backend := storage.Default()
+	for range ticker.C {
+		pooledCount, storageErr := backend.Len(tokenPoolList)
+		if storageErr != nil {
+			logging.Error("Failed to read token pool", "err", storageErr)
+			time.Sleep(time.Second)
+			continue
+		}
+		metrics.SetTokenPoolSize(pooledCount)
+		if pooledCount >= tokenPoolSize() {
+			continue
+		}
+
+		pooled, err := generatePooledToken()
+		if err != "" {
+			logging.Error("Failed to refill token pool", "err", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		// replicas refilling the same pool may overfill it, when the oldest tokens are dropped
+		data, _ := json.Marshal(storedPooledToken{Token: pooled.Token, TxID: pooled.TxID, ExpiresAt: pooled.ExpiresAt, UsableUntil: pooled.usableUntil})
+		if storageErr := backend.Append(tokenPoolList, data, tokenPoolSize()); storageErr != nil {
+			logging.Error("Failed to refill token pool", "err", storageErr)
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// generatePooledToken generates a token from the template, reporting a panic as an error so that the refill
+// goroutine cannot take the launcher down with it
+func generatePooledToken() (pooled PooledToken, error string) {
+	defer func() {
+		if r := recover(); r != nil {
+			pooled, error = PooledToken{}, fmt.Sprint(r)
+		}
+	}()
+
+	generated := time.Now()
+	token, claims, err := GenerateTokenAndClaimsFromPost(copyValues(tokenPoolValues))
+	if err != "" {
+		return PooledToken{}, err
+	}
+
+	pooled = PooledToken{Token: token, TxID: fmt.Sprint(claims["tx_id"])}
+	if exp, ok := claims["exp"].(jwt.NumericDate); ok {
+		pooled.ExpiresAt = exp.Time()
+		pooled.usableUntil = generated.Add(pooled.ExpiresAt.Sub(generated) / 2)
+	}
+	return pooled, ""
+}
+
+// TakePooledToken hands out a token from the pool, generating one when the pool is empty. The flag reports
+// whether the token came from the pool.
+func TakePooledToken() (PooledToken, bool, string) {
+	for tokenPoolStarted {
+		data, ok, err := storage.Default().Pop(tokenPoolList)
+		if err != nil {
+			logging.Error("Failed to take a pooled token", "err", err)
+			break
+		}
+		if !ok {
+			break
+		}
+
+		var stored storedPooledToken
+		if err := json.Unmarshal(data, &stored); err != nil || (!stored.UsableUntil.IsZero() && time.Now().After(stored.UsableUntil)) {
+			metrics.TokenPoolDiscarded()
+			continue
+		}
+		metrics.TokenPoolTaken(true)
+		return PooledToken{Token: stored.Token, TxID: stored.TxID, ExpiresAt: stored.ExpiresAt, usableUntil: stored.UsableUntil}, true, ""
+	}
+
+	metrics.TokenPoolTaken(false)
+	pooled, err := generatePooledToken()
+	return pooled, false, err
+}
+
+// DrainTokenPool discards the pooled tokens, so that tokens made with keys or settings which have been
+// reloaded are not handed out
+func DrainTokenPool() error {
+	if !tokenPoolStarted {
+		return nil
+	}
+
+	backend := storage.Default()
+	discarded, err := backend.Len(tokenPoolList)
+	if err != nil {
+		return err
+	}
+	if _, err := backend.Delete(tokenPoolList); err != nil {
+		return err
+	}
+	for i := 0; i < discarded; i++ {
+		metrics.TokenPoolDiscarded()
+	}
+	metrics.SetTokenPoolSize(0)
+	return nil
+}
+
+func copyValues(values url.Values) url.Values {
+	copied := url.Values{}
+	for key, value := range values {
+		copied[key] = append([]string(nil), value...)
+	}
+	return copied
+}
@@ -0,0 +1,98 @@
+package authentication
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// randomiseField is the launch value asking for generated respondent values. It is not a claim.
+const randomiseField = "randomise"
+
+var (
+	businessNameWords    = []string{"Essential", "Northern", "Riverside", "Crown", "Summit", "Heritage", "Meadow", "Harbour", "Granite", "Beacon"}
+	businessNameTrades   = []string{"Engineering", "Foods", "Logistics", "Textiles", "Builders", "Pharmacy", "Electrical", "Printing", "Motors", "Supplies"}
+	businessNameSuffixes = []string{"LTD.", "PLC", "& SONS", "GROUP LTD."}
+)
+
+// ruRefCheckLetters are the check letters of a reporting unit reference, indexed by the weighted sum of its digits
+const ruRefCheckLetters = "ABCDEFGHJKLMNPQRSTVWXYZ"
+
+// ruRefCheckWeights weight the 11 digits of a reporting unit reference for its check letter
+var ruRefCheckWeights = []int{8, 7, 6, 5, 4, 3, 2, 9, 8, 7, 6}
+
+// RandomLaunchValues generates a respondent for a launch: new identifiers, a reporting unit with a
+// check lettered reference and a business name, and a recent monthly period with its dates
+func RandomLaunchValues() url.Values {
+	userID, _ := newUUID()
+	collectionExerciseSid, _ := newUUID()
+	caseID, _ := newUUID()
+
+	name := fmt.Sprintf("%s %s %s",
+		strings.ToUpper(randomChoice(businessNameWords)),
+		strings.ToUpper(randomChoice(businessNameTrades)),
+		randomChoice(businessNameSuffixes))
+
+	// a whole month between one and twelve months ago, due back twelve days after it ends
+	now := time.Now().UTC()
+	periodStart := time.Date(now.Year(), now.Month()-time.Month(1+rand.Intn(12)), 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := periodStart.AddDate(0, 1, -1)
+	employmentDate := periodStart.AddDate(0, 0, rand.Intn(periodEnd.Day()))
+
+	values := url.Values{}
+	values.Set("user_id", userID.String())
+	values.Set("collection_exercise_sid", collectionExerciseSid.String())
+	values.Set("case_id", caseID.String())
+	values.Set("ru_ref", randomRuRef())
+	values.Set("ru_name", name)
+	values.Set("trad_as", name)
+	values.Set("period_id", periodStart.Format("200601"))
+	values.Set("period_str", periodStart.Format("January 2006"))
+	values.Set("ref_p_start_date", periodStart.Format(isoDateLayout))
+	values.Set("ref_p_end_date", periodEnd.Format(isoDateLayout))
+	values.Set("employment_date", employmentDate.Format(isoDateLayout))
+	values.Set("return_by", periodEnd.AddDate(0, 0, 12).Format(isoDateLayout))
+
+	return values
+}
+
+// withRandomValues fills any of the generated values which the launch leaves out, when it sets randomise
+func withRandomValues(postValues url.Values) url.Values {
+	if randomise, _ := strconv.ParseBool(postValues.Get(randomiseField)); !randomise {
+		return postValues
+	}
+
+	filled := url.Values{}
+	for key, values := range postValues {
+		filled[key] = values
+	}
+	for key, values := range RandomLaunchValues() {
+		if filled.Get(key) == "" {
+			filled[key] = values
+		}
+	}
+
+	return filled
+}
+
+// randomRuRef generates a reporting unit reference of 11 digits, not starting with zero, and a check letter
+func randomRuRef() string {
+	digits := strconv.FormatInt(rand.Int63n(90000000000)+10000000000, 10)
+	return digits + ruRefCheckLetter(digits)
+}
+
+// ruRefCheckLetter is the check letter for the 11 digits of a reporting unit reference
+func ruRefCheckLetter(digits string) string {
+	sum := 0
+	for i, digit := range digits {
+		sum += int(digit-'0') * ruRefCheckWeights[i%len(ruRefCheckWeights)]
+	}
+	return string(ruRefCheckLetters[sum%len(ruRefCheckLetters)])
+}
+
+func randomChoice(choices []string) string {
+	return choices[rand.Intn(len(choices))]
+}
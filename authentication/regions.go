@@ -0,0 +1,159 @@
+package authentication
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/reload"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// RegionPreset is a region to launch for, named by its region_code, with the languages respondents there may
+// answer in, the first of which is its default, and any claims which go with the region
+type RegionPreset struct {
+	RegionCode    string            `json:"region_code"`
+	Title         string            `json:"title"`
+	LanguageCodes []string          `json:"language_codes"`
+	Claims        map[string]string `json:"claims,omitempty"`
+}
+
+// regionPresetField is the launch value selecting a region preset. Like the environment it is not a claim.
+const regionPresetField = "region_preset"
+
+// defaultRegionPresets are the UK regions the runner supports, used unless REGION_PRESETS_PATH replaces them
+var defaultRegionPresets = map[string]RegionPreset{
+	"GB-ENG": {RegionCode: "GB-ENG", Title: "England", LanguageCodes: []string{"en"}},
+	"GB-WLS": {RegionCode: "GB-WLS", Title: "Wales", LanguageCodes: []string{"cy", "en"}},
+	"GB-NIR": {RegionCode: "GB-NIR", Title: "Northern Ireland", LanguageCodes: []string{"en", "ga", "eo"}},
+}
+
+var (
+	regionPresets      = defaultRegionPresets
+	regionPresetsMutex sync.RWMutex
+)
+
+func init() {
+	if err := loadRegionPresets(); err != nil {
+		logging.Error("Failed to load region presets", "err", err)
+	}
+	reload.Register("region_presets", loadRegionPresets, settings.Get("REGION_PRESETS_PATH"))
+}
+
+// loadRegionPresets reads the presets from REGION_PRESETS_PATH, a JSON object of region_code to preset, keeping
+// the current presets on failure
+func loadRegionPresets() error {
+	presetsPath := settings.Get("REGION_PRESETS_PATH")
+	if presetsPath == "" {
+		return nil
+	}
+
+	presetsJSON, err := ioutil.ReadFile(presetsPath)
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]RegionPreset)
+	if err := json.Unmarshal(presetsJSON, &loaded); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", presetsPath, err)
+	}
+
+	for regionCode, preset := range loaded {
+		if len(preset.LanguageCodes) == 0 {
+			return fmt.Errorf("region preset %s in %s has no language_codes", regionCode, presetsPath)
+		}
+		preset.RegionCode = regionCode
+		loaded[regionCode] = preset
+	}
+
+	regionPresetsMutex.Lock()
+	defer regionPresetsMutex.Unlock()
+
+	regionPresets = loaded
+
+	return nil
+}
+
+// RegionPresets returns the configured region presets, sorted by region_code
+func RegionPresets() []RegionPreset {
+	regionPresetsMutex.RLock()
+	defer regionPresetsMutex.RUnlock()
+
+	presets := []RegionPreset{}
+	for _, preset := range regionPresets {
+		presets = append(presets, preset)
+	}
+	sort.Slice(presets, func(i, j int) bool { return presets[i].RegionCode < presets[j].RegionCode })
+
+	return presets
+}
+
+func regionPreset(regionCode string) (RegionPreset, bool) {
+	regionPresetsMutex.RLock()
+	defer regionPresetsMutex.RUnlock()
+
+	preset, ok := regionPresets[regionCode]
+	return preset, ok
+}
+
+// withRegionPreset sets the region_code of the preset selected by region_preset, and fills its default language
+// and claims where the launch leaves them out or empty. A region_code other than the preset's is refused.
+func withRegionPreset(postValues url.Values) (url.Values, *TokenError) {
+	name := postValues.Get(regionPresetField)
+	if name == "" {
+		return postValues, nil
+	}
+
+	preset, ok := regionPreset(name)
+	if !ok {
+		return nil, &TokenError{Desc: "Unknown region_preset: " + name,
+			Fields: []FieldError{{Field: regionPresetField, Error: "must be a configured region preset"}}}
+	}
+	if regionCode := postValues.Get("region_code"); regionCode != "" && regionCode != preset.RegionCode {
+		return nil, &TokenError{Desc: "region_code " + regionCode + " does not match region_preset " + name,
+			Fields: []FieldError{{Field: "region_code", Error: "must be " + preset.RegionCode + " for region_preset " + name}}}
+	}
+
+	filled := url.Values{}
+	for key, values := range postValues {
+		filled[key] = values
+	}
+	filled.Set("region_code", preset.RegionCode)
+	if filled.Get("language_code") == "" {
+		filled.Set("language_code", preset.LanguageCodes[0])
+	}
+	for key, value := range preset.Claims {
+		if filled.Get(key) == "" {
+			filled.Set(key, value)
+		}
+	}
+
+	return filled, nil
+}
+
+// validateRegionLanguage checks the language_code claim is one respondents in the region of the region_code
+// claim may answer in, for regions which have a preset
+func validateRegionLanguage(claims map[string]interface{}) *TokenError {
+	regionCode, _ := claims["region_code"].(string)
+	languageCode, _ := claims["language_code"].(string)
+
+	preset, ok := regionPreset(regionCode)
+	if !ok || languageCode == "" {
+		return nil
+	}
+
+	for _, presetLanguage := range preset.LanguageCodes {
+		if presetLanguage == languageCode {
+			return nil
+		}
+	}
+
+	languages := strings.Join(preset.LanguageCodes, ", ")
+	return &TokenError{Desc: fmt.Sprintf("language_code %s is not used in %s, expected one of %s", languageCode, regionCode, languages),
+		Fields: []FieldError{{Field: "language_code", Error: "must be one of " + languages + " for region_code " + regionCode}}}
+}
@@ -0,0 +1,47 @@
+package authentication
+
+import (
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// validateClaims checks a launch carries every claim the runner needs, naming all of the missing ones.
+//
+// The required claims come from REQUIRED_CLAIMS. A launch must also identify its schema, with
+// schema_url, schema_name or both eq_id and form_type.
+func validateClaims(claims map[string]interface{}) *TokenError {
+	var missing []string
+
+	for _, claim := range strings.Split(settings.Get("REQUIRED_CLAIMS"), ",") {
+		claim = strings.TrimSpace(claim)
+		if claim != "" && !hasClaim(claims, claim) {
+			missing = append(missing, claim)
+		}
+	}
+
+	if !hasClaim(claims, "schema_name") && !hasClaim(claims, "schema_url") {
+		for _, claim := range []string{"eq_id", "form_type"} {
+			if !hasClaim(claims, claim) && !containsString(missing, claim) {
+				missing = append(missing, claim)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return &TokenError{Desc: "Missing required claims: " + strings.Join(missing, ", ")}
+	}
+
+	return nil
+}
+
+func hasClaim(claims map[string]interface{}, claim string) bool {
+	switch value := claims[claim].(type) {
+	case nil:
+		return false
+	case string:
+		return value != ""
+	default:
+		return true
+	}
+}
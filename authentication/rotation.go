@@ -0,0 +1,133 @@
+package authentication
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// rotationSigningKeys parses JWT_SIGNING_KEYS, a JSON object of kid to signing key path, which lists
+// the keys that may be requested by kid while signing keys are being rotated
+func rotationSigningKeys() (map[string]string, *TokenError) {
+	keys := make(map[string]string)
+
+	signingKeys := settings.Get("JWT_SIGNING_KEYS")
+	if signingKeys == "" {
+		return keys, nil
+	}
+
+	if err := json.Unmarshal([]byte(signingKeys), &keys); err != nil {
+		return nil, &TokenError{Desc: "JWT_SIGNING_KEYS must be a JSON object of kid to key path", From: err}
+	}
+
+	return keys, nil
+}
+
+// rotationEncryptionKeys parses JWT_ENCRYPTION_KEYS, a JSON object of kid to encryption key path, which lists
+// the keys of runners that a launch may encrypt for by kid, such as both colours of a blue/green deployment
+func rotationEncryptionKeys() (map[string]string, *TokenError) {
+	keys := make(map[string]string)
+
+	encryptionKeys := settings.Get("JWT_ENCRYPTION_KEYS")
+	if encryptionKeys == "" {
+		return keys, nil
+	}
+
+	if err := json.Unmarshal([]byte(encryptionKeys), &keys); err != nil {
+		return nil, &TokenError{Desc: "JWT_ENCRYPTION_KEYS must be a JSON object of kid to key path", From: err}
+	}
+
+	return keys, nil
+}
+
+// encryptionKidField is the launch value naming the JWT_ENCRYPTION_KEYS keys to encrypt for, separated by commas
+const encryptionKidField = "encryption_kid"
+
+// encryptionKidsFromPost splits the encryption_kid launch values into kids
+func encryptionKidsFromPost(postValues url.Values) []string {
+	var kids []string
+	for _, value := range postValues[encryptionKidField] {
+		for _, kid := range strings.Split(value, ",") {
+			if kid = strings.TrimSpace(kid); kid != "" {
+				kids = append(kids, kid)
+			}
+		}
+	}
+	return kids
+}
+
+// signingTargetFromPost returns the token target for the environment, kids and algorithms requested by the launch.
+// Without a kid the environment's or configured signing key is used, and an unknown kid is an error rather than a fallback.
+func signingTargetFromPost(postValues url.Values) (TokenTarget, *TokenError) {
+	environment, tokenErr := environmentFromPost(postValues)
+	if tokenErr != nil {
+		return TokenTarget{}, tokenErr
+	}
+
+	target := defaultTokenTarget().withAlgorithmOverrides(postValues).withEnvironment(environment)
+	target.EncryptionKids = encryptionKidsFromPost(postValues)
+
+	kid := postValues.Get("kid")
+	if kid == "" {
+		return target, nil
+	}
+
+	path, tokenErr := rotationSigningKeyPath(kid)
+	if tokenErr != nil {
+		return target, tokenErr
+	}
+
+	target.signingKeyPath = path
+	target.SigningKid = kid
+
+	return target, nil
+}
+
+// rotationSigningKeyPath is the path of the JWT_SIGNING_KEYS key with the kid
+func rotationSigningKeyPath(kid string) (string, *TokenError) {
+	keys, tokenErr := rotationSigningKeys()
+	if tokenErr != nil {
+		return "", tokenErr
+	}
+
+	path, ok := keys[kid]
+	if !ok {
+		return "", &TokenError{Desc: "Unknown signing kid requested: " + kid}
+	}
+	return path, nil
+}
+
+// rotationSigningKeyPaths returns the paths of the JWT_SIGNING_KEYS keys, for reloading
+func rotationSigningKeyPaths() []string {
+	keys, tokenErr := rotationSigningKeys()
+	if tokenErr != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, path := range keys {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+// rotationEncryptionKeyPaths returns the paths of the JWT_ENCRYPTION_KEYS keys, for reloading
+func rotationEncryptionKeyPaths() []string {
+	keys, tokenErr := rotationEncryptionKeys()
+	if tokenErr != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, path := range keys {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
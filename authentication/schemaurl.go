@@ -0,0 +1,83 @@
+package authentication
+
+import (
+	"net/url"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/surveys"
+)
+
+// launcherSchemaFromPost finds the schema of a launch, which is either hosted externally at schema_url
+// or is one of the available schemas named by schema_name or eq_id and form_type
+func launcherSchemaFromPost(postValues url.Values) (surveys.LauncherSchema, *TokenError) {
+	schemaURL := postValues.Get("schema_url")
+	if schemaURL == "" {
+		launcherSchema, err := surveys.FindSurveyByName(TransformSchemaParamsToName(postValues))
+		if err != nil {
+			return launcherSchema, &TokenError{Desc: "Unknown schema", From: err,
+				Fields: []FieldError{{Field: "schema_name", Error: "is not one of the available schemas"}}}
+		}
+		return launcherSchema, nil
+	}
+
+	return externalLauncherSchema(postValues.Get("schema_name"), schemaURL)
+}
+
+// ValidateLaunchSchema checks that the launch names an available schema or gives an absolute schema_url, returning
+// the offending field. Finding a named schema may fetch the schema list from the runner.
+func ValidateLaunchSchema(postValues url.Values) []FieldError {
+	if _, err := launcherSchemaFromPost(postValues); err != nil {
+		if len(err.Fields) > 0 {
+			return err.Fields
+		}
+		return []FieldError{{Field: "schema_url", Error: err.Desc}}
+	}
+	return nil
+}
+
+func externalLauncherSchema(name string, schemaURL string) (surveys.LauncherSchema, *TokenError) {
+	parsedURL, err := url.Parse(schemaURL)
+	if err != nil || !parsedURL.IsAbs() || parsedURL.Host == "" {
+		return surveys.LauncherSchema{}, &TokenError{Desc: "schema_url must be an absolute URL: " + schemaURL, From: err}
+	}
+
+	return surveys.LauncherSchema{Name: name, URL: schemaURL}, nil
+}
+
+// ExternalLauncherSchema returns the schema hosted externally at schemaURL, which must be an absolute URL
+func ExternalLauncherSchema(name string, schemaURL string) (surveys.LauncherSchema, string) {
+	launcherSchema, tokenErr := externalLauncherSchema(name, schemaURL)
+	if tokenErr != nil {
+		return launcherSchema, tokenErr.Error()
+	}
+	return launcherSchema, ""
+}
+
+// completeEqIDFormType fills in whichever of eq_id and form_type is missing from the schema_name, so a
+// launch never carries only one of them. A schema_name which can't be split is an error.
+func completeEqIDFormType(claims map[string]interface{}) *TokenError {
+	eqID, _ := claims["eq_id"].(string)
+	formType, _ := claims["form_type"].(string)
+	if (eqID == "") == (formType == "") {
+		return nil
+	}
+
+	schemaName, _ := claims["schema_name"].(string)
+	if schemaName == "" {
+		return &TokenError{Desc: "eq_id and form_type must be supplied together"}
+	}
+
+	schemaEqID, schemaFormType, err := surveys.ExtractEqIDFormType(schemaName)
+	if err != nil {
+		return &TokenError{Desc: "Cannot derive eq_id and form_type from schema_name", From: err}
+	}
+
+	if eqID == "" {
+		claims["eq_id"] = schemaEqID
+	}
+	if formType == "" {
+		claims["form_type"] = schemaFormType
+	}
+	delete(claims, "schema_name")
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/gofrs/uuid"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// SupplementaryDataset describes a dataset of supplementary data held by the Supplementary Data Service (SDS)
+// for a survey and period, which a launch selects with sds_dataset_id
+type SupplementaryDataset struct {
+	DatasetID           string `json:"dataset_id"`
+	SurveyID            string `json:"survey_id"`
+	PeriodID            string `json:"period_id"`
+	Title               string `json:"title"`
+	SDSSchemaVersion    int    `json:"sds_schema_version"`
+	SchemaVersion       string `json:"schema_version"`
+	SDSPublishedAt      string `json:"sds_published_at"`
+	TotalReportingUnits int    `json:"total_reporting_units"`
+}
+
+// GetSupplementaryDatasets lists the datasets which SDS_API_URL holds for the survey and period
+func GetSupplementaryDatasets(ctx context.Context, surveyID string, periodID string) ([]SupplementaryDataset, string) {
+	sdsURL := settings.Get("SDS_API_URL")
+	if sdsURL == "" {
+		return nil, "SDS_API_URL is not set"
+	}
+	if surveyID == "" || periodID == "" {
+		return nil, "survey_id and period_id are required to find supplementary datasets"
+	}
+
+	query := url.Values{"survey_id": {surveyID}, "period_id": {periodID}}
+	datasetsURL := strings.TrimSuffix(sdsURL, "/") + "/v1/dataset_metadata?" + query.Encode()
+
+	resp, err := clients.GetWithContext(ctx, datasetsURL)
+	if err != nil {
+		logging.Error("Failed to load supplementary datasets", "url", datasetsURL, "err", err)
+		return nil, fmt.Sprintf("Failed to load supplementary datasets from %s", datasetsURL)
+	}
+	defer resp.Body.Close()
+
+	// SDS responds 404 when it holds no datasets for the survey and period
+	if resp.StatusCode == 404 {
+		return []SupplementaryDataset{}, ""
+	}
+	if resp.StatusCode != 200 {
+		logging.Error("Invalid response code for supplementary datasets", "url", datasetsURL, "status", resp.StatusCode)
+		return nil, fmt.Sprintf("Failed to load supplementary datasets from %s", datasetsURL)
+	}
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Sprintf("Failed to load supplementary datasets from %s", datasetsURL)
+	}
+
+	var datasets []SupplementaryDataset
+	if err := json.Unmarshal(responseBody, &datasets); err != nil {
+		logging.Error("Failed to unmarshal supplementary datasets", "url", datasetsURL, "err", err)
+		return nil, fmt.Sprintf("Failed to unmarshal supplementary datasets from %s", datasetsURL)
+	}
+
+	return datasets, ""
+}
+
+// validateSDSDatasetID checks the sds_dataset_id claim, which selects the supplementary data the runner loads
+// for the respondent, is a UUID as SDS dataset IDs are. Like ru_ref it is nested under survey_metadata.data in v2.
+func validateSDSDatasetID(claims map[string]interface{}) *TokenError {
+	datasetID, ok := claims["sds_dataset_id"].(string)
+	if !ok || datasetID == "" {
+		return nil
+	}
+
+	if _, err := uuid.FromString(datasetID); err != nil {
+		return &TokenError{Desc: "sds_dataset_id must be a UUID: " + datasetID, From: err,
+			Fields: []FieldError{{Field: "sds_dataset_id", Error: "must be a UUID"}}}
+	}
+
+	return nil
+}
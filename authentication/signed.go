@@ -0,0 +1,86 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/runnertoken"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// SignedOnlyTokensEnabled reports whether signed but unencrypted tokens may be generated, which only DEVELOPER_MODE
+// allows. JWT_ENCRYPTION_DISABLED is its deprecated alias. Only the exact value "true" enables either, so that it
+// cannot be switched on by accident.
+func SignedOnlyTokensEnabled() bool {
+	return settings.Get("DEVELOPER_MODE") == "true" || settings.Get("JWT_ENCRYPTION_DISABLED") == "true"
+}
+
+// GenerateSignedTokenFromPost converts a set of POST values into a signed but unencrypted JWT
+func GenerateSignedTokenFromPost(postValues url.Values) (string, string) {
+	token, _, error := GenerateSignedTokenAndClaimsFromPost(postValues)
+	return token, error
+}
+
+// GenerateSignedTokenAndClaimsFromPost converts a set of POST values into a signed but unencrypted JWT,
+// also returning the claims it contains
+func GenerateSignedTokenAndClaimsFromPost(postValues url.Values) (string, map[string]interface{}, string) {
+	if !SignedOnlyTokensEnabled() {
+		return "", nil, "Signed only tokens are disabled, DEVELOPER_MODE is not true"
+	}
+
+	target, tokenError := signingTargetFromPost(postValues)
+	if tokenError != nil {
+		return "", nil, fmt.Sprintf("GenerateSignedTokenFromPost failed err: %v", tokenError)
+	}
+
+	claims, error := claimsFromPost(context.Background(), postValues)
+	if error != "" {
+		return "", nil, error
+	}
+
+	token, tokenError := generateSignedTokenFromClaims(claims, target)
+	if tokenError != nil {
+		return token, nil, fmt.Sprintf("GenerateSignedTokenFromPost failed err: %v", tokenError)
+	}
+	recordIssuedToken(claims)
+
+	return token, claims, ""
+}
+
+// generateSignedTokenFromClaims signs the claims with the target's signing key, returning the compact JWS
+func generateSignedTokenFromClaims(cl map[string]interface{}, target TokenTarget) (string, *TokenError) {
+	privateKeyResult, keyErr := target.signingKey()
+	if keyErr != nil {
+		return "", &TokenError{Desc: "Error loading signing key", From: keyErr}
+	}
+
+	signingAlgorithm, _, _, algorithmErr := target.withKeySigningAlgorithm(privateKeyResult.key).algorithms()
+	if algorithmErr != nil {
+		return "", algorithmErr
+	}
+
+	if err := checkSigningKeyAlgorithm(privateKeyResult.key, signingAlgorithm); err != nil {
+		return "", err
+	}
+
+	kid := privateKeyResult.kid
+	if target.SigningKid != "" {
+		kid = target.SigningKid
+	}
+
+	payload, tokenErr := marshalClaims(cl)
+	if tokenErr != nil {
+		return "", tokenErr
+	}
+
+	token, err := runnertoken.Sign(payload, privateKeyResult.key, signingAlgorithm, kid)
+	if err != nil {
+		return "", &TokenError{Desc: "Error signing JWT", From: err}
+	}
+
+	logging.Warn("Created signed but unencrypted JWT", "tx_id", cl["tx_id"], "token", logging.RedactToken(token), "signing_kid", kid)
+
+	return token, nil
+}
@@ -0,0 +1,51 @@
+package authentication
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// clockSkewFields are the launch values which offset iat and exp, defaulting to the settings named with them
+var clockSkewFields = []struct {
+	field   string
+	claim   string
+	setting string
+}{
+	{"iat_offset", "iat", "JWT_IAT_OFFSET_SECONDS"},
+	{"exp_offset", "exp", "JWT_EXP_OFFSET_SECONDS"},
+}
+
+// applyClockSkew moves iat and exp by iat_offset and exp_offset seconds, which may be negative, as if the launcher's
+// clock were skewed, so that the runner's leeway can be tested with a token issued in the future or already expired.
+// The offsets are not claims.
+func applyClockSkew(claims map[string]interface{}) *TokenError {
+	for _, skew := range clockSkewFields {
+		value, isValue := claims[skew.field].(string)
+		delete(claims, skew.field)
+		if !isValue {
+			value = settings.Get(skew.setting)
+		}
+		if value == "" {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			if !isValue {
+				return &TokenError{Desc: fmt.Sprintf("%s must be a whole number of seconds, got %s", skew.setting, value), From: err}
+			}
+			return &TokenError{Desc: fmt.Sprintf("%s must be a whole number of seconds, got %s", skew.field, value), From: err,
+				Fields: []FieldError{{Field: skew.field, Error: "must be a whole number of seconds"}}}
+		}
+
+		if date, ok := claims[skew.claim].(jwt.NumericDate); ok {
+			claims[skew.claim] = jwt.NewNumericDate(date.Time().Add(time.Duration(seconds) * time.Second))
+		}
+	}
+
+	return nil
+}
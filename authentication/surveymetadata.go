@@ -0,0 +1,89 @@
+package authentication
+
+import (
+	"strconv"
+	"strings"
+)
+
+// surveyMetadataPrefix marks a form field as custom survey metadata rather than a claim of its own
+const surveyMetadataPrefix = "survey_metadata_"
+
+// variantFlagsPrefix marks a form field as a runner variant flag
+const variantFlagsPrefix = "variant_flags_"
+
+// flagPrefix is a shorter alternative to variantFlagsPrefix
+const flagPrefix = "flag_"
+
+// legacyVariantFlags are variant flags which may also be given without the prefix
+var legacyVariantFlags = []string{"sexual_identity"}
+
+// collectSurveyMetadata moves every survey_metadata_ prefixed claim into the survey_metadata object,
+// without the prefix. The object is omitted when there are no non-empty custom values.
+func collectSurveyMetadata(claims map[string]interface{}) {
+	surveyMetadata := make(map[string]string)
+
+	for key, value := range claims {
+		if !strings.HasPrefix(key, surveyMetadataPrefix) {
+			continue
+		}
+		delete(claims, key)
+
+		name := strings.TrimPrefix(key, surveyMetadataPrefix)
+		if stringValue, ok := value.(string); ok && name != "" && stringValue != "" {
+			surveyMetadata[name] = stringValue
+		}
+	}
+
+	if len(surveyMetadata) > 0 {
+		claims["survey_metadata"] = surveyMetadata
+	}
+}
+
+// collectVariantFlags moves every variant_flags_ or flag_ prefixed claim, and the legacy unprefixed flags, into
+// the variant_flags object without the prefix, so new runner flags need no launcher change. Boolean values,
+// and a checked checkbox ("on"), become booleans; any other value is kept as a string. The object is omitted
+// when no flags are given.
+func collectVariantFlags(claims map[string]interface{}) *TokenError {
+	variantFlags := make(map[string]interface{})
+
+	for key, value := range claims {
+		name, ok := variantFlagName(key)
+		if !ok {
+			continue
+		}
+		delete(claims, key)
+
+		stringValue, _ := value.(string)
+		if name == "" || stringValue == "" {
+			continue
+		}
+
+		var flag interface{} = stringValue
+		if boolValue, err := strconv.ParseBool(stringValue); err == nil {
+			flag = boolValue
+		} else if stringValue == "on" {
+			flag = true
+		}
+
+		if existing, exists := variantFlags[name]; exists && existing != flag {
+			return &TokenError{Desc: "Variant flag " + name + " is given conflicting values"}
+		}
+		variantFlags[name] = flag
+	}
+
+	if len(variantFlags) > 0 {
+		claims["variant_flags"] = variantFlags
+	}
+
+	return nil
+}
+
+// variantFlagName is the flag named by a launch value's key, and whether the key names a flag at all
+func variantFlagName(key string) (string, bool) {
+	for _, prefix := range []string{variantFlagsPrefix, flagPrefix} {
+		if strings.HasPrefix(key, prefix) {
+			return strings.TrimPrefix(key, prefix), true
+		}
+	}
+	return key, containsString(legacyVariantFlags, key)
+}
@@ -0,0 +1,286 @@
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"net/url"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/metrics"
+	"github.com/ONSdigital/eq-questionnaire-launcher/runnertoken"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// TokenTarget describes the keys and algorithms used to mint a token for a runner variant. A target given in a
+// request can only choose among the keys the operator has configured, never name a key file itself.
+type TokenTarget struct {
+	Name             string `json:"name"`
+	SigningAlgorithm string `json:"signing_algorithm"`
+	KeyAlgorithm     string `json:"key_algorithm"`
+	ContentAlgorithm string `json:"content_algorithm"`
+
+	// Environment selects the keys of an ENVIRONMENTS_PATH environment in place of the configured keys
+	Environment string `json:"environment"`
+
+	// Kid selects a JWT_SIGNING_KEYS key to sign with in place of the signing key
+	Kid string `json:"kid"`
+
+	// EncryptionKids selects JWT_ENCRYPTION_KEYS keys to encrypt for in place of the encryption key, each of
+	// which can decrypt the token
+	EncryptionKids []string `json:"encryption_kids"`
+
+	// SigningKid overrides the kid derived from the signing key
+	SigningKid string `json:"signing_kid"`
+
+	// signingKeyPath and encryptionKeyPath are set from the configured keys the target selects, and never
+	// from a request body
+	signingKeyPath    string
+	encryptionKeyPath string
+
+	// fault is set only by fault injection and never from a request body
+	fault string
+}
+
+// TokenKeys identifies the key material used to create a token, without exposing the keys themselves
+type TokenKeys struct {
+	SigningKid     string   `json:"signing_kid"`
+	EncryptionKids []string `json:"encryption_kids"`
+
+	// DecryptionKey is the path of the configured key which decrypted a decoded token
+	DecryptionKey string `json:"decryption_key,omitempty"`
+}
+
+var signatureAlgorithms = map[string]jose.SignatureAlgorithm{
+	string(jose.RS256): jose.RS256,
+	string(jose.RS384): jose.RS384,
+	string(jose.RS512): jose.RS512,
+	string(jose.PS256): jose.PS256,
+	string(jose.PS384): jose.PS384,
+	string(jose.PS512): jose.PS512,
+	string(jose.ES256): jose.ES256,
+	string(jose.ES384): jose.ES384,
+	string(jose.ES512): jose.ES512,
+}
+
+var keyAlgorithms = map[string]jose.KeyAlgorithm{
+	string(jose.RSA1_5):         jose.RSA1_5,
+	string(jose.RSA_OAEP):       jose.RSA_OAEP,
+	string(jose.RSA_OAEP_256):   jose.RSA_OAEP_256,
+	string(jose.ECDH_ES):        jose.ECDH_ES,
+	string(jose.ECDH_ES_A128KW): jose.ECDH_ES_A128KW,
+	string(jose.ECDH_ES_A192KW): jose.ECDH_ES_A192KW,
+	string(jose.ECDH_ES_A256KW): jose.ECDH_ES_A256KW,
+}
+
+var contentAlgorithms = map[string]jose.ContentEncryption{
+	string(jose.A128CBC_HS256): jose.A128CBC_HS256,
+	string(jose.A192CBC_HS384): jose.A192CBC_HS384,
+	string(jose.A256CBC_HS512): jose.A256CBC_HS512,
+	string(jose.A128GCM):       jose.A128GCM,
+	string(jose.A192GCM):       jose.A192GCM,
+	string(jose.A256GCM):       jose.A256GCM,
+}
+
+// defaultTokenTarget is the target for the configured keys and algorithms
+func defaultTokenTarget() TokenTarget {
+	return TokenTarget{
+		Name:             "default",
+		SigningAlgorithm: settings.Get("JWT_SIGNING_ALGORITHM"),
+		KeyAlgorithm:     settings.Get("JWT_KEY_ALGORITHM"),
+		ContentAlgorithm: settings.Get("JWT_CONTENT_ALGORITHM"),
+		SigningKid:       settings.Get("JWT_KID"),
+	}
+}
+
+// algorithmOverrideFields are the launch values which override the algorithms of the default target.
+// Like kid they choose how the token is made and are not claims.
+var algorithmOverrideFields = []string{"signing_algorithm", "key_algorithm", "content_algorithm"}
+
+// withAlgorithmOverrides sets any algorithms given by the launch values on the target
+func (t TokenTarget) withAlgorithmOverrides(postValues url.Values) TokenTarget {
+	if algorithm := postValues.Get("signing_algorithm"); algorithm != "" {
+		t.SigningAlgorithm = algorithm
+	}
+	if algorithm := postValues.Get("key_algorithm"); algorithm != "" {
+		t.KeyAlgorithm = algorithm
+	}
+	if algorithm := postValues.Get("content_algorithm"); algorithm != "" {
+		t.ContentAlgorithm = algorithm
+	}
+	return t
+}
+
+// signingKey loads the target's signing key, which is the configured signing key when no path is set
+func (t TokenTarget) signingKey() (*PrivateKeyResult, *KeyLoadError) {
+	if t.signingKeyPath == "" {
+		return loadSigningKey()
+	}
+	return cachedSigningKey(t.signingKeyPath, func() (*PrivateKeyResult, *KeyLoadError) {
+		return loadSigningKeyFromFile(t.signingKeyPath)
+	})
+}
+
+// encryptionKey loads the target's encryption key, which is the configured encryption key when no path is set
+func (t TokenTarget) encryptionKey() (*PublicKeyResult, *KeyLoadError) {
+	if t.encryptionKeyPath == "" {
+		return loadEncryptionKey()
+	}
+	return cachedEncryptionKey(t.encryptionKeyPath, func() (*PublicKeyResult, *KeyLoadError) {
+		return loadEncryptionKeyFromFile(t.encryptionKeyPath)
+	})
+}
+
+// encryptionRecipients loads the keys the token is encrypted for, the JWT_ENCRYPTION_KEYS keys named by
+// EncryptionKids under their kids, or the target's encryption key when none are named
+func (t TokenTarget) encryptionRecipients() ([]*PublicKeyResult, *TokenError) {
+	if len(t.EncryptionKids) == 0 {
+		publicKeyResult, keyErr := t.encryptionKey()
+		if keyErr != nil {
+			return nil, &TokenError{Desc: "Error loading encryption key", From: keyErr, stage: metrics.StageKeyLoad}
+		}
+		return []*PublicKeyResult{publicKeyResult}, nil
+	}
+
+	keys, tokenErr := rotationEncryptionKeys()
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+
+	var recipients []*PublicKeyResult
+	for _, kid := range t.EncryptionKids {
+		path, ok := keys[kid]
+		if !ok {
+			return nil, &TokenError{Desc: "Unknown encryption kid requested: " + kid, stage: metrics.StageValidation}
+		}
+
+		publicKeyResult, keyErr := cachedEncryptionKey(path, func() (*PublicKeyResult, *KeyLoadError) {
+			return loadEncryptionKeyFromFile(path)
+		})
+		if keyErr != nil {
+			return nil, &TokenError{Desc: "Error loading encryption key " + kid, From: keyErr, stage: metrics.StageKeyLoad}
+		}
+		recipients = append(recipients, &PublicKeyResult{publicKeyResult.key, kid})
+	}
+
+	return recipients, nil
+}
+
+// withConfiguredKeys sets the keys of the environment and signing kid the target selects, either of which
+// must be configured
+func (t TokenTarget) withConfiguredKeys() (TokenTarget, *TokenError) {
+	environment, tokenErr := configuredEnvironment(t.Environment)
+	if tokenErr != nil {
+		return t, tokenErr
+	}
+	t = t.withEnvironment(environment)
+
+	if t.Kid == "" {
+		return t, nil
+	}
+	path, tokenErr := rotationSigningKeyPath(t.Kid)
+	if tokenErr != nil {
+		return t, tokenErr
+	}
+	t.signingKeyPath = path
+	if t.SigningKid == "" {
+		t.SigningKid = t.Kid
+	}
+	return t, nil
+}
+
+// withDefaults fills any unset algorithms of the target from the default target
+func (t TokenTarget) withDefaults() TokenTarget {
+	defaults := defaultTokenTarget()
+
+	if t.SigningAlgorithm == "" {
+		t.SigningAlgorithm = defaults.SigningAlgorithm
+	}
+	if t.KeyAlgorithm == "" {
+		t.KeyAlgorithm = defaults.KeyAlgorithm
+	}
+	if t.ContentAlgorithm == "" {
+		t.ContentAlgorithm = defaults.ContentAlgorithm
+	}
+	if t.SigningKid == "" && t.signingKeyPath == "" {
+		t.SigningKid = defaults.SigningKid
+	}
+
+	return t
+}
+
+func (t TokenTarget) algorithms() (jose.SignatureAlgorithm, jose.KeyAlgorithm, jose.ContentEncryption, *TokenError) {
+	signingAlgorithm, ok := signatureAlgorithms[t.SigningAlgorithm]
+	if !ok {
+		return "", "", "", &TokenError{Desc: "Unsupported signing algorithm: " + t.SigningAlgorithm, stage: metrics.StageValidation}
+	}
+
+	keyAlgorithm, ok := keyAlgorithms[t.KeyAlgorithm]
+	if !ok {
+		return "", "", "", &TokenError{Desc: "Unsupported key management algorithm: " + t.KeyAlgorithm, stage: metrics.StageValidation}
+	}
+
+	contentAlgorithm, ok := contentAlgorithms[t.ContentAlgorithm]
+	if !ok {
+		return "", "", "", &TokenError{Desc: "Unsupported content encryption algorithm: " + t.ContentAlgorithm, stage: metrics.StageValidation}
+	}
+
+	return signingAlgorithm, keyAlgorithm, contentAlgorithm, nil
+}
+
+// autoSigningAlgorithm chooses the signing algorithm from the type of the signing key
+const autoSigningAlgorithm = "auto"
+
+// withKeySigningAlgorithm resolves an auto signing algorithm to the one suiting the key, RS256 for
+// RSA keys and the ES algorithm matching the curve of EC keys
+func (t TokenTarget) withKeySigningAlgorithm(key crypto.Signer) TokenTarget {
+	if t.SigningAlgorithm == autoSigningAlgorithm {
+		t.SigningAlgorithm = keySigningAlgorithm(key)
+	}
+	return t
+}
+
+// keySigningAlgorithm is the usual signing algorithm for the key, or an empty string for an unknown key type
+func keySigningAlgorithm(key crypto.Signer) string {
+	return string(runnertoken.SigningAlgorithmForKey(key))
+}
+
+func checkSigningKeyAlgorithm(key crypto.Signer, algorithm jose.SignatureAlgorithm) *TokenError {
+	name := string(algorithm)
+
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(name, "RS") || strings.HasPrefix(name, "PS") {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		// each ES algorithm is defined for a single curve
+		if name == keySigningAlgorithm(key) {
+			return nil
+		}
+	}
+
+	desc := "Signing key type is not compatible with algorithm: " + name
+	if suggested := keySigningAlgorithm(key); suggested != "" {
+		desc += ", use " + suggested + " or auto for this key"
+	}
+	return &TokenError{Desc: desc, stage: metrics.StageValidation}
+}
+
+func checkEncryptionKeyAlgorithm(key crypto.PublicKey, algorithm jose.KeyAlgorithm) *TokenError {
+	name := string(algorithm)
+
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(name, "RSA") {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if strings.HasPrefix(name, "ECDH-ES") {
+			return nil
+		}
+	}
+
+	return &TokenError{Desc: "Encryption key type is not compatible with algorithm: " + name, stage: metrics.StageValidation}
+}
@@ -0,0 +1,119 @@
+package authentication
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+func TestGenerateTokenFromClaimsForTargetKids(t *testing.T) {
+	useTestKeys(t)
+	useGeneratedEncryptionKey(t)
+
+	signingKey, keyErr := loadSigningKey()
+	if keyErr != nil {
+		t.Fatal(keyErr)
+	}
+	encryptionKey, keyErr := loadEncryptionKey()
+	if keyErr != nil {
+		t.Fatal(keyErr)
+	}
+
+	tests := []struct {
+		name       string
+		signingKid string
+		fault      string
+		want       TokenKeys
+	}{
+		{"configured keys", "", "", TokenKeys{SigningKid: signingKey.kid, EncryptionKids: []string{encryptionKey.kid}}},
+		{"JWT_KID", "launcher-2024", "", TokenKeys{SigningKid: "launcher-2024", EncryptionKids: []string{encryptionKey.kid}}},
+		{"wrong kid fault", "", FaultWrongKid, TokenKeys{SigningKid: faultInjectionKid, EncryptionKids: []string{encryptionKey.kid}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := defaultTokenTarget()
+			target.SigningKid = test.signingKid
+			target.fault = test.fault
+
+			token, keys, tokenErr := generateTokenFromClaimsForTarget(context.Background(), benchmarkClaims(), target)
+			if tokenErr != nil {
+				t.Fatal(tokenErr)
+			}
+			if !reflect.DeepEqual(keys, test.want) {
+				t.Errorf("keys = %+v, want %+v", keys, test.want)
+			}
+
+			// The kids reported are those in the token headers
+			_, decodedKeys, decodeErr := DecodeTokenWithKeys(token)
+			if decodeErr != nil {
+				t.Fatal(decodeErr)
+			}
+			if decodedKeys.SigningKid != test.want.SigningKid || !reflect.DeepEqual(decodedKeys.EncryptionKids, test.want.EncryptionKids) {
+				t.Errorf("token header kids = %+v, want %+v", decodedKeys, test.want)
+			}
+		})
+	}
+}
+
+func TestGenerateTokenFromClaimsForTargetEncryptionKids(t *testing.T) {
+	useTestKeys(t)
+	runnerAPath, _ := generateEncryptionKey(t)
+	runnerBPath, _ := generateEncryptionKey(t)
+	useSetting(t, "JWT_ENCRYPTION_KEYS", `{"runner-a": "`+runnerAPath+`", "runner-b": "`+runnerBPath+`"}`)
+
+	signingKey, keyErr := loadSigningKey()
+	if keyErr != nil {
+		t.Fatal(keyErr)
+	}
+
+	target := defaultTokenTarget()
+	target.EncryptionKids = []string{"runner-a", "runner-b"}
+
+	token, keys, tokenErr := generateTokenFromClaimsForTarget(context.Background(), benchmarkClaims(), target)
+	if tokenErr != nil {
+		t.Fatal(tokenErr)
+	}
+	want := TokenKeys{SigningKid: signingKey.kid, EncryptionKids: []string{"runner-a", "runner-b"}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %+v, want %+v", keys, want)
+	}
+
+	// A token for several recipients is in the general JSON serialization, with a kid for each
+	if !strings.HasPrefix(token, "{") {
+		t.Fatalf("token = %.40s..., want the JSON serialization", token)
+	}
+	var serialized struct {
+		Recipients []struct {
+			Header struct {
+				Kid string `json:"kid"`
+			} `json:"header"`
+		} `json:"recipients"`
+	}
+	if err := json.Unmarshal([]byte(token), &serialized); err != nil {
+		t.Fatal(err)
+	}
+	var recipientKids []string
+	for _, recipient := range serialized.Recipients {
+		recipientKids = append(recipientKids, recipient.Header.Kid)
+	}
+	if !reflect.DeepEqual(recipientKids, want.EncryptionKids) {
+		t.Errorf("recipient kids = %v, want %v", recipientKids, want.EncryptionKids)
+	}
+}
+
+func TestGenerateTokenFromClaimsForTargetUnknownEncryptionKid(t *testing.T) {
+	useTestKeys(t)
+	useSetting(t, "JWT_ENCRYPTION_KEYS", `{}`)
+
+	target := defaultTokenTarget()
+	target.EncryptionKids = []string{"runner-c"}
+
+	_, _, tokenErr := generateTokenFromClaimsForTarget(context.Background(), benchmarkClaims(), target)
+	if tokenErr == nil || tokenErr.Desc != "Unknown encryption kid requested: runner-c" {
+		t.Errorf("error = %v, want Unknown encryption kid requested: runner-c", tokenErr)
+	}
+}
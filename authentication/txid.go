@@ -0,0 +1,55 @@
+package authentication
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+var txIDUnsafeCharacters = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+// embedTxIDMetadata prefixes the tx_id with the survey and period when TX_ID_EMBED_METADATA is enabled
+func embedTxIDMetadata(claims map[string]interface{}) {
+	if settings.Get("TX_ID_EMBED_METADATA") != "true" {
+		return
+	}
+
+	txID, ok := claims["tx_id"].(string)
+	if !ok || txID == "" {
+		return
+	}
+
+	surveyID, _ := claims["survey_id"].(string)
+	if surveyID == "" {
+		surveyID, _ = claims["eq_id"].(string)
+	}
+	periodID, _ := claims["period_id"].(string)
+
+	claims["tx_id"] = composeTxID(surveyID, periodID, txID)
+}
+
+// composeTxID joins the non-empty parts ahead of the UUID, trimming the prefix to fit TX_ID_MAX_LENGTH
+func composeTxID(surveyID string, periodID string, txID string) string {
+	separator := settings.Get("TX_ID_SEPARATOR")
+
+	prefix := ""
+	for _, part := range []string{surveyID, periodID} {
+		if part = txIDUnsafeCharacters.ReplaceAllString(part, ""); part != "" {
+			prefix += part + separator
+		}
+	}
+
+	if maxLength, err := strconv.Atoi(settings.Get("TX_ID_MAX_LENGTH")); err == nil && maxLength > 0 {
+		available := maxLength - len(txID)
+		if available <= len(separator) {
+			return txID
+		}
+		if len(prefix) > available {
+			prefix = strings.TrimSuffix(prefix[:available-len(separator)], separator) + separator
+		}
+	}
+
+	return prefix + txID
+}
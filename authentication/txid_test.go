@@ -0,0 +1,64 @@
+package authentication
+
+import "testing"
+
+const testTxID = "4ecb8c4e-0e7a-4d4c-9a65-a2f3c0e1b2d3"
+
+func TestComposeTxID(t *testing.T) {
+	tests := []struct {
+		name      string
+		separator string
+		maxLength string
+		surveyID  string
+		periodID  string
+		want      string
+	}{
+		{"survey and period", "-", "64", "009", "2024", "009-2024-" + testTxID},
+		{"separator", "_", "64", "009", "2024", "009_2024_" + testTxID},
+		{"empty survey", "-", "64", "", "2024", "2024-" + testTxID},
+		{"empty period", "-", "64", "009", "", "009-" + testTxID},
+		{"empty survey and period", "-", "64", "", "", testTxID},
+		{"unsafe characters stripped", "-", "64", "0-0/9", "2024 Q1!", "009-2024Q1-" + testTxID},
+		{"only unsafe characters", "-", "64", "--", "2024", "2024-" + testTxID},
+		{"prefix truncated", "-", "42", "009", "2024", "009-2-" + testTxID},
+		{"trailing separator not doubled", "-", "41", "009", "2024", "009-" + testTxID},
+		{"no room beyond the separator", "-", "37", "009", "2024", testTxID},
+		{"maximum shorter than the UUID", "-", "30", "009", "2024", testTxID},
+		{"no maximum", "-", "0", "0123456789012345678901234567890123456789", "2024", "0123456789012345678901234567890123456789-2024-" + testTxID},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useSetting(t, "TX_ID_SEPARATOR", test.separator)
+			useSetting(t, "TX_ID_MAX_LENGTH", test.maxLength)
+
+			if got := composeTxID(test.surveyID, test.periodID, testTxID); got != test.want {
+				t.Errorf("composeTxID(%q, %q) = %q, want %q", test.surveyID, test.periodID, got, test.want)
+			}
+		})
+	}
+}
+
+func TestEmbedTxIDMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled string
+		claims  map[string]interface{}
+		want    string
+	}{
+		{"disabled", "false", map[string]interface{}{"tx_id": testTxID, "survey_id": "009", "period_id": "2024"}, testTxID},
+		{"survey_id", "true", map[string]interface{}{"tx_id": testTxID, "survey_id": "009", "period_id": "2024"}, "009-2024-" + testTxID},
+		{"eq_id without survey_id", "true", map[string]interface{}{"tx_id": testTxID, "eq_id": "census", "period_id": "2024"}, "census-2024-" + testTxID},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useSetting(t, "TX_ID_EMBED_METADATA", test.enabled)
+
+			embedTxIDMetadata(test.claims)
+			if got := test.claims["tx_id"]; got != test.want {
+				t.Errorf("tx_id = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
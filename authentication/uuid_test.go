@@ -0,0 +1,87 @@
+package authentication
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/surveys"
+	"github.com/gofrs/uuid"
+)
+
+// useDeterministicUUIDs replaces newUUID with a counter, so the nth UUID generated is testUUID(n)
+func useDeterministicUUIDs(tb testing.TB) {
+	tb.Helper()
+	original := newUUID
+	n := 0
+	newUUID = func() (uuid.UUID, error) {
+		n++
+		return uuid.FromString(testUUID(n))
+	}
+	tb.Cleanup(func() { newUUID = original })
+}
+
+func testUUID(n int) string {
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", n)
+}
+
+func TestGenerateClaimsTxID(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string][]string
+		want   string
+	}{
+		{"omitted", map[string][]string{}, testUUID(1)},
+		{"empty", map[string][]string{"tx_id": {""}}, testUUID(1)},
+		{"supplied", map[string][]string{"tx_id": {"c2b3a7e2-7f6a-4a3e-9d3b-1c2d3e4f5a6b"}}, "c2b3a7e2-7f6a-4a3e-9d3b-1c2d3e4f5a6b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useDeterministicUUIDs(t)
+
+			claims := generateClaims(test.values, surveys.LauncherSchema{})
+			if claims["tx_id"] != test.want {
+				t.Errorf("tx_id = %v, want %v", claims["tx_id"], test.want)
+			}
+		})
+	}
+}
+
+func TestGenerateClaimsAutoIDs(t *testing.T) {
+	useDeterministicUUIDs(t)
+	useSetting(t, "AUTO_USER_ID", "true")
+	useSetting(t, "AUTO_CASE_ID", "true")
+
+	claims := generateClaims(map[string][]string{}, surveys.LauncherSchema{})
+
+	want := map[string]string{"tx_id": testUUID(1), "user_id": testUUID(2), "case_id": testUUID(3)}
+	for claim, value := range want {
+		if claims[claim] != value {
+			t.Errorf("%s = %v, want %v", claim, claims[claim], value)
+		}
+	}
+}
+
+func TestGenerateJwtClaimsJti(t *testing.T) {
+	useDeterministicUUIDs(t)
+
+	if jti := GenerateJwtClaims()["jti"]; jti != testUUID(1) {
+		t.Errorf("jti = %v, want %v", jti, testUUID(1))
+	}
+	if jti := GenerateJwtClaims()["jti"]; jti != testUUID(2) {
+		t.Errorf("second jti = %v, want %v", jti, testUUID(2))
+	}
+}
+
+func TestRandomLaunchValuesIDs(t *testing.T) {
+	useDeterministicUUIDs(t)
+
+	values := RandomLaunchValues()
+
+	want := map[string]string{"user_id": testUUID(1), "collection_exercise_sid": testUUID(2), "case_id": testUUID(3)}
+	for field, value := range want {
+		if got := values.Get(field); got != value {
+			t.Errorf("%s = %q, want %q", field, got, value)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package authentication
+
+import (
+	"fmt"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// socialMetadataFields are the claims grouped under survey_metadata for a v2 social launch
+var socialMetadataFields = []string{"case_type", "display_address", "qid", "case_ref", "account_id"}
+
+// caseMetadataFields are the social metadata fields which only make sense alongside a case_type
+var caseMetadataFields = []string{"case_type", "display_address", "qid", "case_ref"}
+
+// individualCaseType is the case_type of an individual response, which must always carry a qid
+const individualCaseType = "HI"
+
+// v2TopLevelClaims are the claims which stay at the top level of a v2 payload, every other claim is survey specific
+var v2TopLevelClaims = map[string]bool{
+	"jti":                         true,
+	"iat":                         true,
+	"exp":                         true,
+	"tx_id":                       true,
+	"version":                     true,
+	"schema_name":                 true,
+	"schema_url":                  true,
+	"survey_url":                  true,
+	"cir_instrument_id":           true,
+	"response_id":                 true,
+	"case_id":                     true,
+	"collection_exercise_sid":     true,
+	"language_code":               true,
+	"region_code":                 true,
+	"roles":                       true,
+	"channel":                     true,
+	"account_service_url":         true,
+	"account_service_log_out_url": true,
+	"response_expires_at":         true,
+}
+
+// v2DroppedClaims are v1 claims which have no place in a v2 payload
+var v2DroppedClaims = []string{"eq_id", "action_launch", "action_flush", "action_preview"}
+
+// applyClaimsVersion rearranges the claims into the v2 structure when a v2 launch is requested. A launch
+// without a version uses DEFAULT_CLAIMS_VERSION, and v1 claims carry no version claim.
+func applyClaimsVersion(claims map[string]interface{}) string {
+	version, _ := claims["version"].(string)
+	if version == "" {
+		version = settings.Get("DEFAULT_CLAIMS_VERSION")
+	}
+
+	switch version {
+	case "", "v1":
+		delete(claims, "version")
+		return ""
+	case "v2":
+		claims["version"] = version
+		return generateClaimsV2(claims)
+	}
+
+	return fmt.Sprintf("Unsupported claims version: %s, expected v1 or v2", version)
+}
+
+// generateClaimsV2 keeps the runner claims at the top level and nests the survey specific claims under survey_metadata.data
+func generateClaimsV2(claims map[string]interface{}) string {
+	if err := validateSocialSurveyMetadata(claims); err != "" {
+		return err
+	}
+
+	schemaName, _ := claims["schema_name"].(string)
+	schemaURL, _ := claims["schema_url"].(string)
+	if schemaName == "" && schemaURL == "" {
+		eqID, _ := claims["eq_id"].(string)
+		formType, _ := claims["form_type"].(string)
+		if eqID == "" || formType == "" {
+			return "schema_url, schema_name, or eq_id and form_type, is required for a v2 launch"
+		}
+		claims["schema_name"] = eqID + "_" + formType
+	}
+
+	if responseID, _ := claims["response_id"].(string); responseID == "" {
+		responseID, _ := newUUID()
+		claims["response_id"] = responseID.String()
+	}
+
+	for _, claim := range v2DroppedClaims {
+		delete(claims, claim)
+	}
+
+	data := make(map[string]interface{})
+	if customMetadata, ok := claims["survey_metadata"].(map[string]string); ok {
+		for key, value := range customMetadata {
+			data[key] = value
+		}
+		delete(claims, "survey_metadata")
+	}
+
+	for key, value := range claims {
+		if !v2TopLevelClaims[key] {
+			data[key] = value
+			delete(claims, key)
+		}
+	}
+
+	if len(data) > 0 {
+		claims["survey_metadata"] = map[string]interface{}{"data": data}
+	}
+
+	return ""
+}
+
+// validateSocialSurveyMetadata checks that the social survey_metadata fields of the claims are internally consistent
+func validateSocialSurveyMetadata(claims map[string]interface{}) string {
+	surveyMetadata := make(map[string]string)
+	for _, field := range socialMetadataFields {
+		if value, ok := claims[field].(string); ok && value != "" {
+			surveyMetadata[field] = value
+		}
+	}
+
+	hasCaseMetadata := false
+	for _, field := range caseMetadataFields {
+		if _, ok := surveyMetadata[field]; ok {
+			hasCaseMetadata = true
+		}
+	}
+
+	if !hasCaseMetadata {
+		return ""
+	}
+
+	caseType, hasCaseType := surveyMetadata["case_type"]
+	if !hasCaseType {
+		return "case_type is required when social survey_metadata is supplied"
+	}
+
+	if _, hasQid := surveyMetadata["qid"]; caseType == individualCaseType && !hasQid {
+		return fmt.Sprintf("qid is required when case_type is %s", individualCaseType)
+	}
+
+	return ""
+}
@@ -0,0 +1,96 @@
+package authentication
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// runnerSocialExample is a v2 social payload carrying the runner's example survey_metadata, as documented in the README
+const runnerSocialExample = `{
+  "version": "v2",
+  "schema_name": "social_demo",
+  "tx_id": "5f9e4c4a-5f3b-4a0a-9d7c-6a7c0e6f0b1d",
+  "response_id": "1000000000000001",
+  "case_id": "a3a2ad3d-e5b6-4ac5-a5b5-a4aa0a4a5e5a",
+  "collection_exercise_sid": "789",
+  "language_code": "en",
+  "survey_metadata": {
+    "data": {"case_type": "HI", "qid": "0130000000000300", "display_address": "68 Abingdon Road, Goathill", "case_ref": "1000000000000001"}
+  }
+}`
+
+func TestApplyClaimsVersionSocialExample(t *testing.T) {
+	claims := map[string]interface{}{
+		"version":                 "v2",
+		"schema_name":             "social_demo",
+		"eq_id":                   "social",
+		"tx_id":                   "5f9e4c4a-5f3b-4a0a-9d7c-6a7c0e6f0b1d",
+		"response_id":             "1000000000000001",
+		"case_id":                 "a3a2ad3d-e5b6-4ac5-a5b5-a4aa0a4a5e5a",
+		"collection_exercise_sid": "789",
+		"language_code":           "en",
+		"case_type":               "HI",
+		"qid":                     "0130000000000300",
+		"display_address":         "68 Abingdon Road, Goathill",
+		"case_ref":                "1000000000000001",
+	}
+
+	if err := applyClaimsVersion(claims); err != "" {
+		t.Fatalf("applyClaimsVersion failed: %s", err)
+	}
+
+	var got, want interface{}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(payload, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(runnerSocialExample), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("v2 claims = %s, want the runner example %s", payload, runnerSocialExample)
+	}
+}
+
+func TestValidateSocialSurveyMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  map[string]interface{}
+		wantErr string
+	}{
+		{"no social fields", map[string]interface{}{"ru_ref": "12346789012A"}, ""},
+		{"individual with qid", map[string]interface{}{"case_type": "HI", "qid": "0130000000000300"}, ""},
+		{"individual without qid", map[string]interface{}{"case_type": "HI", "case_ref": "1000000000000001"}, "qid is required when case_type is HI"},
+		{"household without qid", map[string]interface{}{"case_type": "HH", "display_address": "68 Abingdon Road"}, ""},
+		{"case fields without case_type", map[string]interface{}{"qid": "0130000000000300"}, "case_type is required when social survey_metadata is supplied"},
+		{"account_id alone", map[string]interface{}{"account_id": "a1"}, ""},
+		{"empty values ignored", map[string]interface{}{"case_type": "", "qid": ""}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateSocialSurveyMetadata(test.claims); err != test.wantErr {
+				t.Errorf("validateSocialSurveyMetadata error = %q, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyClaimsVersionV1(t *testing.T) {
+	claims := map[string]interface{}{"version": "v1", "eq_id": "social", "case_type": "HI"}
+
+	if err := applyClaimsVersion(claims); err != "" {
+		t.Fatalf("applyClaimsVersion failed: %s", err)
+	}
+	if _, ok := claims["version"]; ok {
+		t.Error("v1 claims carry a version claim")
+	}
+	if claims["case_type"] != "HI" || claims["eq_id"] != "social" {
+		t.Errorf("v1 claims = %v, want them unchanged", claims)
+	}
+}
@@ -0,0 +1,148 @@
+package authentication
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/reload"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// ValidationProfile declares the claim rules of a survey programme
+type ValidationProfile struct {
+	Required      []string            `json:"required"`
+	AllowedValues map[string][]string `json:"allowed_values"`
+	Defaults      map[string]string   `json:"defaults"`
+}
+
+const defaultValidationProfile = "default"
+
+var (
+	validationProfiles      = map[string]ValidationProfile{defaultValidationProfile: {}}
+	validationProfilesMutex sync.RWMutex
+)
+
+func init() {
+	if err := loadValidationProfiles(); err != nil {
+		logging.Error("Failed to load validation profiles", "err", err)
+	}
+	reload.Register("validation_profiles", loadValidationProfiles, settings.Get("VALIDATION_PROFILES_PATH"))
+}
+
+// loadValidationProfiles reads the profiles from VALIDATION_PROFILES_PATH, keeping the current profiles on failure
+func loadValidationProfiles() error {
+	profilesPath := settings.Get("VALIDATION_PROFILES_PATH")
+	if profilesPath == "" {
+		return nil
+	}
+
+	profilesJSON, err := ioutil.ReadFile(profilesPath)
+	if err != nil {
+		return err
+	}
+
+	profiles := make(map[string]ValidationProfile)
+	if err := json.Unmarshal(profilesJSON, &profiles); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", profilesPath, err)
+	}
+
+	if _, ok := profiles[defaultValidationProfile]; !ok {
+		profiles[defaultValidationProfile] = ValidationProfile{}
+	}
+
+	validationProfilesMutex.Lock()
+	defer validationProfilesMutex.Unlock()
+
+	validationProfiles = profiles
+
+	return nil
+}
+
+// applyValidationProfile fills the defaults of the selected profile then checks the claims against its rules
+func applyValidationProfile(claims map[string]interface{}) *TokenError {
+	name, _ := claims["validation_profile"].(string)
+	delete(claims, "validation_profile")
+	if name == "" {
+		name = defaultValidationProfile
+	}
+
+	validationProfilesMutex.RLock()
+	profile, ok := validationProfiles[name]
+	validationProfilesMutex.RUnlock()
+
+	if !ok {
+		return &TokenError{Desc: "Unknown validation profile: " + name}
+	}
+
+	for claim, value := range profile.Defaults {
+		if existing, ok := claims[claim]; !ok || existing == "" {
+			claims[claim] = value
+		}
+	}
+
+	var missing []string
+	for _, claim := range profile.Required {
+		if value, ok := claims[claim]; !ok || value == "" {
+			missing = append(missing, claim)
+		}
+	}
+
+	var invalid []string
+	for claim, allowed := range profile.AllowedValues {
+		value, ok := claims[claim].(string)
+		if !ok || value == "" {
+			continue
+		}
+		if !containsString(allowed, value) {
+			invalid = append(invalid, fmt.Sprintf("%s=%s", claim, value))
+		}
+	}
+
+	if len(missing) == 0 && len(invalid) == 0 {
+		return nil
+	}
+
+	sort.Strings(invalid)
+
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "missing required claims: "+strings.Join(missing, ", "))
+	}
+	if len(invalid) > 0 {
+		problems = append(problems, "claims with values not allowed: "+strings.Join(invalid, ", "))
+	}
+
+	return &TokenError{Desc: fmt.Sprintf("Validation profile %s failed: %s", name, strings.Join(problems, "; "))}
+}
+
+// validationProfileClaims names every claim which a validation profile requires, restricts or defaults
+func validationProfileClaims() []string {
+	validationProfilesMutex.RLock()
+	defer validationProfilesMutex.RUnlock()
+
+	var claims []string
+	for _, profile := range validationProfiles {
+		claims = append(claims, profile.Required...)
+		for claim := range profile.AllowedValues {
+			claims = append(claims, claim)
+		}
+		for claim := range profile.Defaults {
+			claims = append(claims, claim)
+		}
+	}
+	return claims
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package authentication
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testValidationProfiles = `{
+  "business": {
+    "required": ["ru_ref", "period_id"],
+    "allowed_values": {"region_code": ["GB-ENG", "GB-WLS"]},
+    "defaults": {"language_code": "en"}
+  },
+  "social": {
+    "required": ["case_id"],
+    "allowed_values": {"region_code": ["GB-NIR"]},
+    "defaults": {"language_code": "cy"}
+  }
+}`
+
+// useValidationProfiles loads the profiles from a file, restoring the current profiles when the test ends
+func useValidationProfiles(t *testing.T, profilesJSON string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "validation-profiles.json")
+	if err := ioutil.WriteFile(path, []byte(profilesJSON), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	validationProfilesMutex.RLock()
+	current := validationProfiles
+	validationProfilesMutex.RUnlock()
+	t.Cleanup(func() {
+		validationProfilesMutex.Lock()
+		validationProfiles = current
+		validationProfilesMutex.Unlock()
+	})
+
+	useSetting(t, "VALIDATION_PROFILES_PATH", path)
+	if err := loadValidationProfiles(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyValidationProfile(t *testing.T) {
+	useValidationProfiles(t, testValidationProfiles)
+
+	tests := []struct {
+		name         string
+		claims       map[string]interface{}
+		wantErr      string
+		wantLanguage interface{}
+	}{
+		{"business with its required claims", map[string]interface{}{"validation_profile": "business", "ru_ref": "12346789012A", "period_id": "201605", "region_code": "GB-WLS"}, "", "en"},
+		{"business without ru_ref", map[string]interface{}{"validation_profile": "business", "period_id": "201605"}, "missing required claims: ru_ref", "en"},
+		{"business with a social region", map[string]interface{}{"validation_profile": "business", "ru_ref": "12346789012A", "period_id": "201605", "region_code": "GB-NIR"}, "claims with values not allowed: region_code=GB-NIR", "en"},
+		{"social without business claims", map[string]interface{}{"validation_profile": "social", "case_id": "c1", "region_code": "GB-NIR"}, "", "cy"},
+		{"social without case_id", map[string]interface{}{"validation_profile": "social", "ru_ref": "12346789012A", "period_id": "201605"}, "missing required claims: case_id", "cy"},
+		{"social with a business region", map[string]interface{}{"validation_profile": "social", "case_id": "c1", "region_code": "GB-ENG"}, "claims with values not allowed: region_code=GB-ENG", "cy"},
+		{"selected language kept", map[string]interface{}{"validation_profile": "social", "case_id": "c1", "language_code": "en"}, "", "en"},
+		{"no profile applies no rules", map[string]interface{}{"region_code": "GB-SCT"}, "", nil},
+		{"unknown profile", map[string]interface{}{"validation_profile": "census"}, "Unknown validation profile: census", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := applyValidationProfile(test.claims)
+
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Fatalf("applyValidationProfile failed: %v", err)
+			case test.wantErr != "" && (err == nil || !strings.Contains(err.Desc, test.wantErr)):
+				t.Fatalf("applyValidationProfile error = %v, want %s", err, test.wantErr)
+			}
+			if _, ok := test.claims["validation_profile"]; ok {
+				t.Error("validation_profile was left in the claims")
+			}
+			if test.wantErr == "" && test.claims["language_code"] != test.wantLanguage {
+				t.Errorf("language_code = %v, want %v", test.claims["language_code"], test.wantLanguage)
+			}
+		})
+	}
+}
+
+func TestLoadValidationProfilesKeepsCurrentOnFailure(t *testing.T) {
+	useValidationProfiles(t, testValidationProfiles)
+
+	path := filepath.Join(t.TempDir(), "invalid.json")
+	if err := ioutil.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	useSetting(t, "VALIDATION_PROFILES_PATH", path)
+
+	if err := loadValidationProfiles(); err == nil {
+		t.Fatal("loadValidationProfiles succeeded with invalid JSON")
+	}
+	if err := applyValidationProfile(map[string]interface{}{"validation_profile": "social"}); err == nil || !strings.Contains(err.Desc, "case_id") {
+		t.Errorf("social profile after failed reload = %v, want it kept", err)
+	}
+}
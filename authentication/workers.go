@@ -0,0 +1,53 @@
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+var (
+	signingWorkers     chan struct{}
+	signingWorkersOnce sync.Once
+)
+
+// signingWorkerCount is TOKEN_SIGNING_WORKERS, the number of tokens signed and encrypted at once, defaulting to
+// the number of CPUs when unset or 0
+func signingWorkerCount() int {
+	workers, err := strconv.Atoi(settings.Get("TOKEN_SIGNING_WORKERS"))
+	if err != nil || workers < 0 {
+		logging.Error("Invalid TOKEN_SIGNING_WORKERS, using the number of CPUs", "value", settings.Get("TOKEN_SIGNING_WORKERS"))
+		workers = 0
+	}
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	return workers
+}
+
+// acquireSigningWorker waits for one of TOKEN_SIGNING_WORKERS, returning the function which releases it. Signing
+// and encryption are CPU bound, so under load tokens queue for a worker rather than slow each other down.
+func acquireSigningWorker() func() {
+	signingWorkersOnce.Do(func() {
+		signingWorkers = make(chan struct{}, signingWorkerCount())
+	})
+
+	signingWorkers <- struct{}{}
+	return func() { <-signingWorkers }
+}
+
+// signsLocally reports whether a signing key is held by the launcher, so that signing with it is CPU bound. A KMS
+// key is a crypto.Signer of another type, whose signatures are made remotely.
+func signsLocally(key crypto.Signer) bool {
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+		return true
+	}
+	return false
+}
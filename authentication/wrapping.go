@@ -0,0 +1,31 @@
+package authentication
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// WrapToken base64url encodes a compact token for systems that mangle the dots in the compact form
+func WrapToken(token string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(token))
+}
+
+// UnwrapToken returns the compact form of a token which may have been wrapped by WrapToken. A JSON serialized
+// token for several recipients is returned as it is.
+func UnwrapToken(token string) (string, *TokenError) {
+	token = strings.TrimSpace(token)
+	if strings.Contains(token, ".") || strings.HasPrefix(token, "{") {
+		return token, nil
+	}
+
+	unwrapped, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(token, "="))
+	if err != nil {
+		return "", &TokenError{Desc: "Token is neither a compact JWT nor base64url wrapped", From: err}
+	}
+
+	if strings.Count(string(unwrapped), ".") != 4 {
+		return "", &TokenError{Desc: "Wrapped token does not contain a compact JWE"}
+	}
+
+	return string(unwrapped), nil
+}
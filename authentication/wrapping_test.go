@@ -0,0 +1,81 @@
+package authentication
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWrapTokenRoundTrip(t *testing.T) {
+	token := "eyJhbGciOiJSU0EtT0FFUCJ9.a2V5.aXY.Y2lwaGVydGV4dA.dGFn"
+
+	wrapped := WrapToken(token)
+	if strings.ContainsAny(wrapped, ".=+/") {
+		t.Errorf("WrapToken(%q) = %q, want unpadded base64url", token, wrapped)
+	}
+
+	for _, input := range []string{wrapped, wrapped + "==", " " + wrapped + "\n"} {
+		unwrapped, err := UnwrapToken(input)
+		if err != nil {
+			t.Fatalf("UnwrapToken(%q) failed: %v", input, err)
+		}
+		if unwrapped != token {
+			t.Errorf("UnwrapToken(%q) = %q, want %q", input, unwrapped, token)
+		}
+	}
+}
+
+func TestUnwrapTokenUnwrapped(t *testing.T) {
+	for _, token := range []string{"eyJhbGciOiJSU0EtT0FFUCJ9.a2V5.aXY.Y2lwaGVydGV4dA.dGFn", `{"protected": "...", "recipients": []}`} {
+		unwrapped, err := UnwrapToken(token)
+		if err != nil {
+			t.Fatalf("UnwrapToken(%q) failed: %v", token, err)
+		}
+		if unwrapped != token {
+			t.Errorf("UnwrapToken(%q) = %q, want it unchanged", token, unwrapped)
+		}
+	}
+}
+
+func TestUnwrapTokenInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"not base64url", "not*base64", "Token is neither a compact JWT nor base64url wrapped"},
+		{"wrapped JWS", WrapToken("eyJhbGciOiJSUzI1NiJ9.e30.c2ln"), "Wrapped token does not contain a compact JWE"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := UnwrapToken(test.token)
+			if err == nil || err.Desc != test.want {
+				t.Errorf("UnwrapToken(%q) error = %v, want %s", test.token, err, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeWrappedToken(t *testing.T) {
+	useTestKeys(t)
+	useGeneratedEncryptionKey(t)
+
+	claims := benchmarkClaims()
+	token, _, tokenErr := signAndEncryptClaims(context.Background(), claims, defaultTokenTarget())
+	if tokenErr != nil {
+		t.Fatal(tokenErr)
+	}
+
+	for name, input := range map[string]string{"raw": token, "wrapped": WrapToken(token)} {
+		t.Run(name, func(t *testing.T) {
+			decoded, err := DecodeToken(input)
+			if err != nil {
+				t.Fatalf("DecodeToken failed: %v", err)
+			}
+			if decoded["jti"] != claims["jti"] {
+				t.Errorf("decoded jti = %v, want %v", decoded["jti"], claims["jti"])
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/authentication"
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+func getBatchCSVHandler(w http.ResponseWriter, r *http.Request) {
+	serveTemplate("batch.html", nil, w, r)
+}
+
+// readBatchCSV reads the launch values of each row of a CSV with a header row of launch value names.
+// Each row starts from the base values and its non-empty cells replace them.
+func readBatchCSV(reader io.Reader, base url.Values) ([]url.Values, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the CSV has no header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	var sets []url.Values
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		values := url.Values{}
+		for key, baseValues := range base {
+			values[key] = append([]string{}, baseValues...)
+		}
+		for i, cell := range row {
+			if header[i] != "" && cell != "" {
+				values.Set(header[i], cell)
+			}
+		}
+		sets = append(sets, values)
+	}
+
+	return sets, nil
+}
+
+// postBatchCSVHandler mints a token for each row of an uploaded CSV and responds with a CSV of the launch URLs and tokens.
+// The CSV is either the file field of a multipart form, whose other fields are launch values shared by every row,
+// or the whole body when sent as text/csv. With launch set to true each session is also opened on the runner.
+func postBatchCSVHandler(w http.ResponseWriter, r *http.Request) {
+	var sets []url.Values
+	var err error
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		sets, err = readBatchCSV(r.Body, url.Values{})
+	} else {
+		if err = r.ParseMultipartForm(1 << 20); err != nil {
+			if isRequestTooLarge(err) {
+				http.Error(w, http.StatusText(413), 413)
+				return
+			}
+			http.Error(w, fmt.Sprintf("POST. r.ParseMultipartForm() err: %v", err), 400)
+			return
+		}
+
+		file, _, fileErr := r.FormFile("file")
+		if fileErr != nil {
+			http.Error(w, "A CSV file is required", 400)
+			return
+		}
+		defer file.Close()
+
+		base := url.Values{}
+		for key, values := range r.MultipartForm.Value {
+			if key != "launch" {
+				base[key] = values
+			}
+		}
+		sets, err = readBatchCSV(file, base)
+	}
+	if isRequestTooLarge(err) {
+		http.Error(w, http.StatusText(413), 413)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid CSV: %v", err), 400)
+		return
+	}
+
+	if maxSize, err := strconv.Atoi(settings.Get("MAX_BATCH_SIZE")); err == nil && maxSize > 0 && len(sets) > maxSize {
+		http.Error(w, fmt.Sprintf("A batch may have at most %d launches, got %d", maxSize, len(sets)), 400)
+		return
+	}
+
+	tokens, txIDs, failures, tokenErr := authentication.GenerateTokensFromPosts(sets)
+	if tokenErr != "" {
+		http.Error(w, tokenErr, 400)
+		return
+	}
+	for i, values := range sets {
+		if _, failed := failures[i]; !failed {
+			recordAudit(r, values.Get("schema_name"), txIDs[i])
+		}
+	}
+
+	launch := r.FormValue("launch") == "true"
+	client := *clients.GetHTTPClient()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="launches.csv"`)
+
+	csvWriter := csv.NewWriter(w)
+	header := []string{"row", "launch_url", "token", "error"}
+	if launch {
+		header = append(header, "runner_status")
+	}
+	csvWriter.Write(header)
+
+	for i, values := range sets {
+		record := []string{strconv.Itoa(i + 1), "", tokens[i], failures[i]}
+
+		var runnerURL string
+		if failures[i] == "" {
+			var runnerErr string
+			runnerURL, runnerErr = authentication.RunnerURLFromPost(values)
+			if runnerErr == "" {
+				var err error
+				if record[1], err = buildRunnerURL(runnerURL, "/session", tokens[i]); err != nil {
+					runnerErr = err.Error()
+				}
+			}
+			record[3] = runnerErr
+		}
+
+		if launch {
+			status := ""
+			if record[1] != "" {
+				if result := launchSession(&client, runnerURL, tokens[i]); result.err != nil {
+					status = result.err.Error()
+				} else {
+					status = strconv.Itoa(result.status)
+				}
+			}
+			record = append(record, status)
+		}
+
+		csvWriter.Write(record)
+	}
+
+	csvWriter.Flush()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/authentication"
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/runnertoken"
+)
+
+const benchUsage = `Usage: eq-questionnaire-launcher bench [--help] [--count N] [--concurrency N[,N...]]
+
+Signs and encrypts --count tokens (default 1000) with the configured keys and algorithms, once for each of
+the --concurrency levels (default 1 and the number of CPUs). Each level is run first creating the signer and
+encrypter for every token, as the launcher did before reusing them, and then reusing them.
+At most TOKEN_SIGNING_WORKERS tokens are signed at once whatever the concurrency, so levels above it show
+how tokens queue for a worker.
+Tokens per second and latencies are printed for every run. The exit code is 1 when tokens cannot be made
+and 2 for invalid arguments.
+`
+
+// benchConcurrency parses the comma separated --concurrency levels
+func benchConcurrency(value string) ([]int, error) {
+	levels := []int{}
+	for _, level := range strings.Split(value, ",") {
+		number, err := strconv.Atoi(strings.TrimSpace(level))
+		if err != nil || number < 1 {
+			return nil, fmt.Errorf("--concurrency must be numbers of at least 1: %s", value)
+		}
+		levels = append(levels, number)
+	}
+	return levels, nil
+}
+
+// benchCommand runs the bench subcommand, returning the process exit code
+func benchCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	for _, arg := range args {
+		if arg == "--help" || arg == "-h" {
+			fmt.Fprint(stdout, benchUsage)
+			return 0
+		}
+	}
+
+	options := make(map[string]string)
+	setOptions := make(map[string]func(string))
+	for _, name := range []string{"count", "concurrency"} {
+		name := name
+		setOptions[name] = func(value string) { options[name] = value }
+	}
+
+	levels := []int{1}
+	if runtime.NumCPU() > 1 {
+		levels = append(levels, runtime.NumCPU())
+	}
+
+	values, err := parseCommandArgs(args, setOptions)
+	if err == nil && len(values) > 0 {
+		err = fmt.Errorf("bench takes no claim values")
+	}
+	var count int
+	if err == nil {
+		count, err = loadTestOption(options, "count", 1000, 1)
+	}
+	if value, ok := options["concurrency"]; ok && err == nil {
+		levels, err = benchConcurrency(value)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		fmt.Fprint(stderr, benchUsage)
+		return 2
+	}
+
+	// Every token is logged at info level, which would be timed along with it
+	logging.SetLevel(logging.WarnLevel)
+	defer runnertoken.SetReuse(true)
+
+	for _, concurrency := range levels {
+		for _, reuse := range []bool{false, true} {
+			runnertoken.SetReuse(reuse)
+
+			start := time.Now()
+			latencies, tokenErr := authentication.BenchmarkTokens(count, concurrency)
+			if tokenErr != "" {
+				fmt.Fprintln(stderr, tokenErr)
+				return 1
+			}
+			reportBench(stdout, concurrency, reuse, latencies, time.Since(start))
+		}
+	}
+	return 0
+}
+
+// reportBench prints the throughput and latency distribution of a run
+func reportBench(w io.Writer, concurrency int, reuse bool, latencies []time.Duration, elapsed time.Duration) {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p int) time.Duration {
+		return latencies[(len(latencies)-1)*p/100]
+	}
+
+	fmt.Fprintf(w, "concurrency %d, reuse %t: %d tokens in %v, %.1f tokens/s\n",
+		concurrency, reuse, len(latencies), elapsed.Round(time.Millisecond), float64(len(latencies))/elapsed.Seconds())
+	fmt.Fprintf(w, "  latency: min %v, p50 %v, p95 %v, p99 %v, max %v\n",
+		latencies[0], percentile(50), percentile(95), percentile(99), latencies[len(latencies)-1])
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/authentication"
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/gorilla/mux"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// runnerCallbacks are the runner's callbacks about a launch already made, each sent its callback token by the
+// launch form action of the same name
+var runnerCallbacks = []struct {
+	Name        string
+	PathSetting string
+}{
+	{"feedback", "RUNNER_FEEDBACK_PATH"},
+	{"receipt", "RUNNER_RECEIPT_PATH"},
+}
+
+// runnerCallbackFromPost is the name and runner path of the callback requested by an action_feedback or
+// action_receipt value
+func runnerCallbackFromPost(values url.Values) (string, string, bool) {
+	for _, callback := range runnerCallbacks {
+		if values.Get("action_"+callback.Name) != "" {
+			return callback.Name, settings.Get(callback.PathSetting), true
+		}
+	}
+	return "", "", false
+}
+
+// sendRunnerCallback generates the callback token for the launch identified by the form's tx_id and posts it to
+// the runner's callback path, reporting the runner's status and response as a flush does
+func sendRunnerCallback(w http.ResponseWriter, r *http.Request, name string, path string) {
+	callbackValues := url.Values{}
+	for key, values := range r.PostForm {
+		callbackValues[key] = values
+	}
+	if callbackValues.Get("tx_id") == "" {
+		http.Error(w, "tx_id of the launch is required to send a "+name, 400)
+		return
+	}
+
+	token, claims, err := authentication.GenerateCallbackTokenFromPost(r.Context(), name, callbackValues)
+	if err != "" {
+		http.Error(w, err, 500)
+		return
+	}
+	recordAudit(r, callbackValues.Get("schema_name"), callbackValues.Get("tx_id"))
+
+	runnerURL, runnerErr := authentication.RunnerURLFromPost(callbackValues)
+	if runnerErr != "" {
+		http.Error(w, runnerErr, 400)
+		return
+	}
+
+	callbackURL, urlErr := buildRunnerURL(runnerURL, path, token)
+	if urlErr != nil {
+		http.Error(w, urlErr.Error(), 400)
+		return
+	}
+
+	resp, postErr := clients.PostWithContext(r.Context(), callbackURL, "application/x-www-form-urlencoded", nil)
+	if postErr != nil {
+		logging.Error("Callback request failed", "callback", name, "err", postErr)
+		http.Error(w, fmt.Sprintf("Callback request failed: %v", postErr), 502)
+		return
+	}
+	defer resp.Body.Close()
+
+	responseBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxFlushResponseBytes))
+	logging.Info("Callback request sent", "callback", name, "tx_id", claims["tx_id"], "status", resp.StatusCode)
+
+	status := 200
+	if resp.StatusCode >= 300 {
+		status = 502
+	}
+	writeJSON(w, status, map[string]interface{}{"runner_status": resp.StatusCode, "runner_response": string(responseBody)})
+}
+
+// postCallbackTokenHandler generates the named callback token for an existing launch and returns it with the
+// runner URL it is sent to, without sending it
+func postCallbackTokenHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	path := ""
+	for _, callback := range runnerCallbacks {
+		if callback.Name == name {
+			path = settings.Get(callback.PathSetting)
+		}
+	}
+	if path == "" {
+		writeAPIError(w, 404, errorNotFound, "Unknown callback token: "+name)
+		return
+	}
+
+	values, ok := readLaunchValues(w, r)
+	if !ok {
+		return
+	}
+	if values.Get("tx_id") == "" {
+		writeAPIError(w, 400, errorInvalidLaunchValues, invalidLaunchValues,
+			authentication.FieldError{Field: "tx_id", Error: "is required to identify the launch"})
+		return
+	}
+
+	token, claims, tokenErr := authentication.GenerateCallbackTokenFromPost(r.Context(), name, values)
+	if tokenErr != "" {
+		writeAPIError(w, 400, errorTokenFailed, tokenErr)
+		return
+	}
+	recordAudit(r, values.Get("schema_name"), values.Get("tx_id"))
+
+	response := map[string]interface{}{"token": token, "tx_id": claims["tx_id"]}
+	if exp, ok := claims["exp"].(jwt.NumericDate); ok {
+		response["expires_at"] = exp.Time().UTC().Format(time.RFC3339)
+	}
+	if runnerURL, runnerErr := authentication.RunnerURLFromPost(values); runnerErr == "" {
+		if callbackURL, urlErr := buildRunnerURL(runnerURL, path, token); urlErr == nil {
+			response["callback_url"] = callbackURL
+		}
+	}
+	writeJSON(w, 200, response)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/authentication"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+const tokenUsage = `Usage: eq-questionnaire-launcher token [--help] [--schema NAME|URL] [--count N] [--out FILE] [--claims-out FILE] [--url-out FILE] [--signed-only] [--<claim>=<value> ...]
+
+Generates a token from the given claim values, which use the same names as the launch form.
+--schema is the schema_name, with any .json suffix removed, or the schema_url when it is a URL.
+--count generates that many tokens, one per line, each with its own jti and generated tx_id.
+The token is printed to stdout unless --out is given. Output files are created with 0600 permissions.
+--signed-only produces a signed but unencrypted token, and requires DEVELOPER_MODE=true.
+--environment selects one of the ENVIRONMENTS_PATH environments, whose keys and runner URL are used.
+Keys and other behaviour come from the same settings as the web server. The exit code is 1 when the
+token can't be generated and 2 for invalid arguments.
+`
+
+// tokenCommandOptions are the output options of the token subcommand
+type tokenCommandOptions struct {
+	out        string
+	claimsOut  string
+	urlOut     string
+	signedOnly bool
+	count      string
+}
+
+var tokenOutputFlags = map[string]func(*tokenCommandOptions, string){
+	"out":         func(o *tokenCommandOptions, v string) { o.out = v },
+	"claims-out":  func(o *tokenCommandOptions, v string) { o.claimsOut = v },
+	"url-out":     func(o *tokenCommandOptions, v string) { o.urlOut = v },
+	"signed-only": func(o *tokenCommandOptions, v string) { o.signedOnly = v != "false" },
+	"count":       func(o *tokenCommandOptions, v string) { o.count = v },
+}
+
+// parseTokenArgs splits the arguments into output options and claim values
+func parseTokenArgs(args []string) (tokenCommandOptions, url.Values, error) {
+	options := tokenCommandOptions{}
+
+	setOptions := make(map[string]func(string))
+	for name, setOption := range tokenOutputFlags {
+		setOption := setOption
+		setOptions[name] = func(value string) { setOption(&options, value) }
+	}
+
+	values, err := parseCommandArgs(args, setOptions)
+	return options, values, err
+}
+
+// parseCommandArgs splits --name=value or --name value arguments into the given options and claim values
+func parseCommandArgs(args []string, options map[string]func(string)) (url.Values, error) {
+	values := url.Values{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			return nil, fmt.Errorf("unexpected argument: %s", arg)
+		}
+
+		key := strings.TrimPrefix(arg, "--")
+		value := ""
+		if separator := strings.Index(key, "="); separator != -1 {
+			key, value = key[:separator], key[separator+1:]
+		} else if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			value = args[i+1]
+			i++
+		}
+
+		if key == "" {
+			return nil, fmt.Errorf("invalid argument: %s", arg)
+		}
+
+		if setOption, ok := options[key]; ok {
+			setOption(value)
+		} else if key == "schema" {
+			setSchemaValue(values, value)
+		} else {
+			values.Add(key, value)
+		}
+	}
+
+	return values, nil
+}
+
+// setSchemaValue sets schema_url when the schema is a URL, otherwise schema_name with any .json suffix removed
+func setSchemaValue(values url.Values, schema string) {
+	if strings.Contains(schema, "://") {
+		values.Set("schema_url", schema)
+	} else {
+		values.Set("schema_name", strings.TrimSuffix(schema, ".json"))
+	}
+}
+
+// writeSensitiveFile writes data to the file at path, restricting it to the current user
+func writeSensitiveFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := file.Chmod(0600); err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	return err
+}
+
+// tokenCommand runs the token subcommand, returning the process exit code
+func tokenCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	for _, arg := range args {
+		if arg == "--help" || arg == "-h" {
+			fmt.Fprint(stdout, tokenUsage)
+			return 0
+		}
+	}
+
+	options, values, err := parseTokenArgs(args)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		fmt.Fprint(stderr, tokenUsage)
+		return 2
+	}
+
+	if options.signedOnly && options.urlOut != "" {
+		fmt.Fprintln(stderr, "--url-out cannot be used with --signed-only as the runner only accepts encrypted tokens")
+		return 2
+	}
+
+	count := 1
+	if options.count != "" {
+		count, err = strconv.Atoi(options.count)
+		if err != nil || count < 1 {
+			fmt.Fprintln(stderr, "--count must be a positive number:", options.count)
+			return 2
+		}
+	}
+
+	if count > 1 && (options.claimsOut != "" || options.urlOut != "") {
+		fmt.Fprintln(stderr, "--claims-out and --url-out cannot be used with --count as they hold a single token")
+		return 2
+	}
+
+	generate := authentication.GenerateTokenAndClaimsFromPost
+	if options.signedOnly {
+		generate = authentication.GenerateSignedTokenAndClaimsFromPost
+	}
+
+	var tokens strings.Builder
+	var token string
+	var claims map[string]interface{}
+	for i := 0; i < count; i++ {
+		var tokenErr string
+		token, claims, tokenErr = generate(values)
+		if tokenErr != "" {
+			fmt.Fprintln(stderr, tokenErr)
+			return 1
+		}
+		tokens.WriteString(token + "\n")
+	}
+
+	if options.out != "" {
+		if err := writeSensitiveFile(options.out, []byte(tokens.String())); err != nil {
+			fmt.Fprintln(stderr, "Failed to write token:", err)
+			return 1
+		}
+	} else {
+		fmt.Fprint(stdout, tokens.String())
+	}
+
+	if options.claimsOut != "" {
+		claimsJSON, err := json.MarshalIndent(claims, "", "  ")
+		if err != nil {
+			fmt.Fprintln(stderr, "Failed to marshal claims:", err)
+			return 1
+		}
+		if err := writeSensitiveFile(options.claimsOut, append(claimsJSON, '\n')); err != nil {
+			fmt.Fprintln(stderr, "Failed to write claims:", err)
+			return 1
+		}
+	}
+
+	if options.urlOut != "" {
+		runnerURL, runnerErr := authentication.RunnerURLFromPost(values)
+		if runnerErr != "" {
+			fmt.Fprintln(stderr, runnerErr)
+			return 1
+		}
+		launchURL, err := buildRunnerURL(runnerURL, "/session", token)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if err := writeSensitiveFile(options.urlOut, []byte(launchURL+"\n")); err != nil {
+			fmt.Fprintln(stderr, "Failed to write launch URL:", err)
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// schemaServer serves a schema with no metadata requirements, for launches by schema_url
+func schemaServer(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"metadata": [], "survey_id": "001", "form_type": "1", "title": "Test"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/test_schema.json"
+}
+
+func TestTokenCommandFileOutput(t *testing.T) {
+	dir := t.TempDir()
+	tokenPath := filepath.Join(dir, "token.txt")
+	claimsPath := filepath.Join(dir, "claims.json")
+	urlPath := filepath.Join(dir, "url.txt")
+
+	var stdout, stderr bytes.Buffer
+	exitCode := tokenCommand([]string{
+		"--schema", schemaServer(t), "--collection_exercise_sid", "789", "--tx_id", "5f9e4c4a-5f3b-4a0a-9d7c-6a7c0e6f0b1d",
+		"--out", tokenPath, "--claims-out=" + claimsPath, "--url-out", urlPath,
+	}, &stdout, &stderr)
+	if exitCode != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", exitCode, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("stdout = %q, want nothing when --out is given", stdout.String())
+	}
+
+	for _, path := range []string{tokenPath, claimsPath, urlPath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%s permissions = %o, want 600", filepath.Base(path), info.Mode().Perm())
+		}
+	}
+
+	token := readFile(t, tokenPath)
+	if strings.Count(strings.TrimSpace(token), ".") != 4 {
+		t.Errorf("token file = %q, want a compact JWE", token)
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal([]byte(readFile(t, claimsPath)), &claims); err != nil {
+		t.Fatalf("claims file is not JSON: %v", err)
+	}
+	if claims["tx_id"] != "5f9e4c4a-5f3b-4a0a-9d7c-6a7c0e6f0b1d" || claims["collection_exercise_sid"] != "789" {
+		t.Errorf("claims = %v, want the given tx_id and collection_exercise_sid", claims)
+	}
+
+	if launchURL := readFile(t, urlPath); !strings.HasSuffix(strings.TrimSpace(launchURL), "/session?token="+strings.TrimSpace(token)) {
+		t.Errorf("launch URL = %q, want the runner /session with the token", launchURL)
+	}
+}
+
+func TestTokenCommandRestrictsExistingFile(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token.txt")
+	if err := ioutil.WriteFile(tokenPath, []byte("old token\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if exitCode := tokenCommand([]string{"--schema", schemaServer(t), "--collection_exercise_sid", "789", "--out", tokenPath}, &stdout, &stderr); exitCode != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", exitCode, stderr.String())
+	}
+
+	info, err := os.Stat(tokenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("permissions = %o, want an existing file restricted to 600", info.Mode().Perm())
+	}
+	if token := readFile(t, tokenPath); strings.Contains(token, "old token") {
+		t.Errorf("token file = %q, want it replaced", token)
+	}
+}
+
+func TestTokenCommandStdout(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if exitCode := tokenCommand([]string{"--schema", schemaServer(t), "--collection_exercise_sid", "789", "--count", "2"}, &stdout, &stderr); exitCode != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", exitCode, stderr.String())
+	}
+
+	if lines := strings.Split(strings.TrimSpace(stdout.String()), "\n"); len(lines) != 2 {
+		t.Errorf("stdout has %d tokens, want 2", len(lines))
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestTokenCommandUnknownSchema(t *testing.T) {
+	useSetting(t, "SURVEY_RUNNER_SCHEMA_URL", "http://127.0.0.1:1")
+
+	var stdout, stderr bytes.Buffer
+	if exitCode := tokenCommand([]string{"--schema_name", "not_a_schema"}, &stdout, &stderr); exitCode != 1 {
+		t.Errorf("exit code = %d, want 1", exitCode)
+	}
+	if !strings.Contains(stderr.String(), "Unknown schema") {
+		t.Errorf("stderr = %q, want the unknown schema reported", stderr.String())
+	}
+}
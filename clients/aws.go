@@ -0,0 +1,72 @@
+package clients
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// HasAWSCredentials reports whether AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are set to sign requests with
+func HasAWSCredentials() bool {
+	return settings.Get("AWS_ACCESS_KEY_ID") != "" && settings.Get("AWS_SECRET_ACCESS_KEY") != ""
+}
+
+// AWSSignatureHeaders gives the headers which sign a request to an AWS service in region with AWS Signature
+// Version 4, using AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and any AWS_SESSION_TOKEN
+func AWSSignatureHeaders(method string, rawURL string, payload []byte, service string, region string, now time.Time) (http.Header, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	payloadHash := sha256.Sum256(payload)
+	payloadHashHex := hex.EncodeToString(payloadHash[:])
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+
+	headers := http.Header{}
+	headers.Set("X-Amz-Date", amzDate)
+	headers.Set("X-Amz-Content-Sha256", payloadHashHex)
+	canonicalHeaders := "host:" + parsed.Host + "\nx-amz-content-sha256:" + payloadHashHex + "\nx-amz-date:" + amzDate + "\n"
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	if sessionToken := settings.Get("AWS_SESSION_TOKEN"); sessionToken != "" {
+		headers.Set("X-Amz-Security-Token", sessionToken)
+		canonicalHeaders += "x-amz-security-token:" + sessionToken + "\n"
+		signedHeaders += ";x-amz-security-token"
+	}
+
+	canonicalPath := parsed.EscapedPath()
+	if canonicalPath == "" {
+		canonicalPath = "/"
+	}
+	canonicalQuery := strings.ReplaceAll(parsed.Query().Encode(), "+", "%20")
+
+	canonicalRequest := strings.Join([]string{method, canonicalPath, canonicalQuery, canonicalHeaders, signedHeaders, payloadHashHex}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	signingKey := []byte("AWS4" + settings.Get("AWS_SECRET_ACCESS_KEY"))
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	headers.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		settings.Get("AWS_ACCESS_KEY_ID"), scope, signedHeaders, signature))
+	return headers, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,64 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+const gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+var (
+	gcpToken       string
+	gcpTokenExpiry time.Time
+	gcpTokenMutex  sync.Mutex
+)
+
+// GCPAccessToken is GCP_ACCESS_TOKEN when it is set, otherwise a token of the instance's service account from
+// the GCP metadata server, cached until shortly before it expires
+func GCPAccessToken(ctx context.Context) (string, error) {
+	if token := settings.Get("GCP_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	gcpTokenMutex.Lock()
+	defer gcpTokenMutex.Unlock()
+
+	if gcpToken != "" && time.Now().Before(gcpTokenExpiry) {
+		return gcpToken, nil
+	}
+
+	resp, err := GetWithHeaders(ctx, gcpMetadataTokenURL, http.Header{"Metadata-Flavor": {"Google"}})
+	if err != nil {
+		return "", fmt.Errorf("GCP metadata server unreachable, set GCP_ACCESS_TOKEN outside GCP: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("GCP metadata server returned %d for a token", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token from GCP metadata server: %v", err)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("invalid token from GCP metadata server: %v", err)
+	}
+
+	gcpToken = token.AccessToken
+	gcpTokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+
+	return gcpToken, nil
+}
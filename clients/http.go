@@ -1,15 +1,78 @@
 package clients
 
 import (
+	"context"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/outbound"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/ONSdigital/eq-questionnaire-launcher/tracing"
 )
 
-var httpClient = &http.Client{
-	Timeout: time.Duration(5) * time.Second,
+var httpClient = newHTTPClient()
+
+func newHTTPClient() *http.Client {
+	timeout, err := strconv.Atoi(settings.Get("HTTP_CLIENT_TIMEOUT_SECONDS"))
+	if err != nil || timeout <= 0 {
+		timeout = 5
+	}
+
+	return &http.Client{
+		Timeout:   time.Duration(timeout) * time.Second,
+		Transport: tracing.Transport(outbound.Transport()),
+	}
 }
 
 // GetHTTPClient returns a single HttpClient for use across the app
-func GetHTTPClient() (*http.Client)  {
+func GetHTTPClient() *http.Client {
 	return httpClient
-}
\ No newline at end of file
+}
+
+// GetWithContext makes a GET request with the shared client which is cancelled with ctx, such as when
+// the incoming request it is made for is abandoned
+func GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return httpClient.Do(req)
+}
+
+// GetWithHeaders makes a GET request like GetWithContext which also carries the given headers, such as
+// the Authorization of an API which needs one
+func GetWithHeaders(ctx context.Context, url string, headers http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	return httpClient.Do(req)
+}
+
+// PostWithContext makes a POST request with the shared client which is cancelled with ctx
+func PostWithContext(ctx context.Context, url string, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return httpClient.Do(req)
+}
+
+// PostWithHeaders makes a POST request like PostWithContext which carries the given headers in place of a
+// Content-Type alone
+func PostWithHeaders(ctx context.Context, url string, headers http.Header, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	return httpClient.Do(req)
+}
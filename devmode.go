@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/reload"
+)
+
+const watchInterval = time.Second
+
+// watchForChanges polls the templates and registered config files, re-parsing them when they change
+func watchForChanges() {
+	templatesModified := lastModified(templateFiles())
+	configModified := lastModified(reload.Files())
+
+	go func() {
+		for range time.Tick(watchInterval) {
+			if modified := lastModified(templateFiles()); !modified.Equal(templatesModified) {
+				templatesModified = modified
+				if err := parseTemplates(); err != nil {
+					logging.Warn("Failed to re-parse templates, keeping current templates", "err", err)
+				} else {
+					logging.Info("Templates re-parsed")
+				}
+			}
+
+			if modified := lastModified(reload.Files()); !modified.Equal(configModified) {
+				configModified = modified
+				logReloadResult(reload.All())
+			}
+		}
+	}()
+}
+
+func templateFiles() []string {
+	files, _ := filepath.Glob(filepath.Join("templates", "*.html"))
+	return files
+}
+
+// lastModified returns the latest modification time of the files, ignoring any which can't be read
+func lastModified(files []string) time.Time {
+	var latest time.Time
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
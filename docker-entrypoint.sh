@@ -5,4 +5,4 @@ if [ -n "$SECRETS_S3_BUCKET" ]; then
     aws s3 sync s3://$SECRETS_S3_BUCKET/ /secrets
 fi
 
-./eq-questionnaire-launcher
+exec ./eq-questionnaire-launcher "$@"
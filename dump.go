@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/authentication"
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+)
+
+// maxDumpResponseBytes caps how much of each runner dump is reported back
+const maxDumpResponseBytes = 1 << 20
+
+// runnerDumps are the runner's dump endpoints, each reported under its name
+var runnerDumps = []struct {
+	Name string
+	Path string
+}{
+	{"debug", "/dump/debug"},
+	{"submission", "/dump/submission"},
+}
+
+// dumpSession opens a session on the runner with a dumper token for the launch values, which identify an
+// existing session by its response_id and other identifiers, and reports the runner's dumps of it, including
+// the submission payload before it is encrypted
+func dumpSession(w http.ResponseWriter, r *http.Request) {
+	dumpValues := url.Values{}
+	for key, values := range r.PostForm {
+		dumpValues[key] = values
+	}
+	dumpValues.Set("roles", "dumper")
+
+	token, claims, err := authentication.GenerateTokenAndClaimsFromPost(dumpValues)
+	if err != "" {
+		http.Error(w, err, 500)
+		return
+	}
+	recordAudit(r, dumpValues.Get("schema_name"), fmt.Sprint(claims["tx_id"]))
+
+	runnerURL, runnerErr := authentication.RunnerURLFromPost(dumpValues)
+	if runnerErr != "" {
+		http.Error(w, runnerErr, 400)
+		return
+	}
+
+	sessionURL, urlErr := buildRunnerURL(runnerURL, "/session", token)
+	if urlErr != nil {
+		http.Error(w, urlErr.Error(), 400)
+		return
+	}
+
+	client, verification, sessionErr := openRunnerSession(r.Context(), sessionURL)
+	if sessionErr != nil {
+		logging.Error("Dump session failed", "err", sessionErr)
+		http.Error(w, fmt.Sprintf("Dump session failed: %v", sessionErr), 502)
+		return
+	}
+	if !verification.Succeeded {
+		writeJSON(w, 502, map[string]interface{}{"session": verification})
+		return
+	}
+
+	response := map[string]interface{}{"session": verification}
+	status := 200
+	for _, dump := range runnerDumps {
+		dumpStatus, body, dumpErr := fetchRunnerDump(r.Context(), client, runnerURL+dump.Path)
+		if dumpErr != nil {
+			logging.Error("Dump request failed", "path", dump.Path, "err", dumpErr)
+			http.Error(w, fmt.Sprintf("Dump request failed: %v", dumpErr), 502)
+			return
+		}
+		if dumpStatus >= 300 {
+			status = 502
+		}
+		response[dump.Name] = map[string]interface{}{"runner_status": dumpStatus, "runner_response": body}
+	}
+
+	logging.Info("Session dumped", "status", status)
+	writeJSON(w, status, response)
+}
+
+// fetchRunnerDump reads a dump with the session's client. A JSON dump is reported as JSON, anything else,
+// such as an error page, as text.
+func fetchRunnerDump(ctx context.Context, client *http.Client, dumpURL string) (int, interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", dumpURL, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDumpResponseBytes))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && json.Valid(body) {
+		return resp.StatusCode, json.RawMessage(body), nil
+	}
+	return resp.StatusCode, string(body), nil
+}
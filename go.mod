@@ -7,6 +7,9 @@ require (
 	github.com/gofrs/uuid v3.2.0+incompatible
 	github.com/gorilla/context v0.0.0-20160226214623-1ea25387ff6f // indirect
 	github.com/gorilla/mux v1.4.0
+	github.com/prometheus/client_golang v1.11.1
 	github.com/stretchr/testify v1.7.0 // indirect
 	gopkg.in/square/go-jose.v2 v2.1.2
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
+	modernc.org/sqlite v1.11.2
 )
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/authentication"
+	"github.com/ONSdigital/eq-questionnaire-launcher/history"
+	"github.com/gorilla/mux"
+)
+
+func getHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	serveTemplate("history.html", history.List(), w, r)
+}
+
+func getHistoryEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, history.List())
+}
+
+// getIssuedTokensHandler lists the jti and tx_id of every token issued since the launcher started, newest first.
+// With duplicates=true only the tokens repeating an earlier jti or tx_id are listed.
+func getIssuedTokensHandler(w http.ResponseWriter, r *http.Request) {
+	issued := authentication.IssuedTokens()
+	if r.URL.Query().Get("duplicates") == "true" {
+		duplicates := []authentication.IssuedToken{}
+		for _, token := range issued {
+			if token.Reissued || token.DuplicateTxID {
+				duplicates = append(duplicates, token)
+			}
+		}
+		issued = duplicates
+	}
+	writeJSON(w, 200, issued)
+}
+
+// postHistoryLaunchHandler launches a recorded launch again with the values it was made with,
+// with any posted values replacing the recorded ones
+func postHistoryLaunchHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if isRequestTooLarge(err) {
+		http.Error(w, http.StatusText(413), 413)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("POST. r.ParseForm() err: %v", err), 500)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	entry, ok := history.Get(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	values := url.Values{}
+	for field, fieldValues := range entry.Values {
+		values[field] = append([]string(nil), fieldValues...)
+	}
+
+	hasAction := false
+	for field, fieldValues := range r.PostForm {
+		values[field] = fieldValues
+		hasAction = hasAction || strings.HasPrefix(field, "action_")
+	}
+	if !hasAction {
+		values.Set("action_launch", "true")
+	}
+
+	r.PostForm = values
+	redirectURL(w, r)
+}
@@ -0,0 +1,218 @@
+package history
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/ONSdigital/eq-questionnaire-launcher/storage"
+)
+
+// Entry is a launch made by the launcher
+type Entry struct {
+	ID         int        `json:"id"`
+	Time       time.Time  `json:"time"`
+	TxID       string     `json:"tx_id"`
+	JTI        string     `json:"jti,omitempty"`
+	SchemaName string     `json:"schema_name"`
+	Outcome    string     `json:"outcome"`
+	Values     url.Values `json:"values"`
+}
+
+// historyList and historyIDKey hold the launches and the last ID given to one in the state backend
+const (
+	historyList  = "history"
+	historyIDKey = "history_id"
+)
+
+var (
+	// historyMutex serialises writes to HISTORY_PATH so that concurrent launches don't lose each other's entries
+	historyMutex sync.Mutex
+	loadOnce     sync.Once
+)
+
+// Record adds a launch to the history, with the jti of its token if one was made, dropping the oldest launch once
+// HISTORY_SIZE are kept. Actions and any HISTORY_REDACT_FIELDS are left out of the recorded values.
+func Record(values url.Values, txID string, jti string, outcome string) {
+	size := historySize()
+	if size == 0 {
+		return
+	}
+
+	recorded := url.Values{}
+	for field, fieldValues := range values {
+		if !strings.HasPrefix(field, "action_") && !isRedacted(field) {
+			recorded[field] = append([]string(nil), fieldValues...)
+		}
+	}
+
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	loadOnce.Do(readHistory)
+
+	backend := storage.Default()
+	id, err := backend.Incr(historyIDKey)
+	if err == nil {
+		var data []byte
+		data, err = json.Marshal(Entry{
+			ID:         int(id),
+			Time:       time.Now().UTC(),
+			TxID:       txID,
+			JTI:        jti,
+			SchemaName: values.Get("schema_name"),
+			Outcome:    outcome,
+			Values:     recorded,
+		})
+		if err == nil {
+			err = backend.Append(historyList, data, size)
+		}
+	}
+	if err != nil {
+		logging.Warn("Failed to record launch", "err", err)
+		return
+	}
+
+	if err := writeHistory(); err != nil {
+		logging.Warn("Failed to write launch history", "path", settings.Get("HISTORY_PATH"), "err", err)
+	}
+}
+
+// List returns the recorded launches, newest first
+func List() []Entry {
+	historyMutex.Lock()
+	loadOnce.Do(readHistory)
+	historyMutex.Unlock()
+
+	entries := readEntries()
+	list := make([]Entry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		list = append(list, entries[i])
+	}
+	return list
+}
+
+// Get returns the recorded launch with the given ID, if it is still kept
+func Get(id int) (Entry, bool) {
+	for _, entry := range List() {
+		if entry.ID == id {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// readEntries returns the launches in the state backend, oldest first
+func readEntries() []Entry {
+	values, err := storage.Default().Range(historyList)
+	if err != nil {
+		logging.Warn("Failed to read launch history", "err", err)
+		return nil
+	}
+
+	entries := make([]Entry, 0, len(values))
+	for _, value := range values {
+		var entry Entry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			logging.Warn("Ignoring unreadable launch history entry", "err", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// historySize is the number of launches kept from HISTORY_SIZE, where zero disables the history
+func historySize() int {
+	size, err := strconv.Atoi(settings.Get("HISTORY_SIZE"))
+	if err != nil || size < 0 {
+		return 0
+	}
+	return size
+}
+
+func isRedacted(field string) bool {
+	for _, redacted := range strings.Split(settings.Get("HISTORY_REDACT_FIELDS"), ",") {
+		if strings.TrimSpace(redacted) == field {
+			return true
+		}
+	}
+	return false
+}
+
+// readHistory loads the history kept at HISTORY_PATH, so that it survives a restart. A shared state backend
+// keeps the history itself, so the file is not used.
+func readHistory() {
+	path := settings.Get("HISTORY_PATH")
+	if path == "" || storage.Shared() {
+		return
+	}
+
+	var entries []Entry
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &entries)
+	}
+	if err != nil {
+		logging.Warn("Failed to read launch history", "path", path, "err", err)
+		return
+	}
+
+	backend := storage.Default()
+	lastID := 0
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err == nil {
+			err = backend.Append(historyList, data, historySize())
+		}
+		if err != nil {
+			logging.Warn("Failed to load launch history", "path", path, "err", err)
+			return
+		}
+		if entry.ID > lastID {
+			lastID = entry.ID
+		}
+	}
+	if err := backend.Put(historyIDKey, []byte(strconv.Itoa(lastID)), 0); err != nil {
+		logging.Warn("Failed to load launch history", "path", path, "err", err)
+	}
+}
+
+// writeHistory replaces the history file via a rename, so a failed write never leaves it half written
+func writeHistory() error {
+	path := settings.Get("HISTORY_PATH")
+	if path == "" || storage.Shared() {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(readEntries(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,129 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// awsRegion is the region of a key ARN, otherwise AWS_REGION
+func awsRegion(keyID string) string {
+	if parts := strings.Split(keyID, ":"); strings.HasPrefix(keyID, "arn:") && len(parts) > 3 {
+		return parts[3]
+	}
+	return settings.Get("AWS_REGION")
+}
+
+// awsKMSCall makes a call of the AWS KMS JSON API, signed with the AWS credentials
+func awsKMSCall(ctx context.Context, keyID string, operation string, request map[string]interface{}) ([]byte, error) {
+	if !clients.HasAWSCredentials() {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for AWS KMS")
+	}
+
+	region := awsRegion(keyID)
+	endpoint := strings.TrimSuffix(settings.Get("KMS_ENDPOINT_URL"), "/")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://kms.%s.amazonaws.com", region)
+	}
+	endpoint += "/"
+
+	request["KeyId"] = keyID
+	payload, _ := json.Marshal(request)
+
+	headers, err := clients.AWSSignatureHeaders("POST", endpoint, payload, "kms", region, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	headers.Set("Content-Type", "application/x-amz-json-1.1")
+	headers.Set("X-Amz-Target", "TrentService."+operation)
+
+	resp, err := clients.PostWithHeaders(ctx, endpoint, headers, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("AWS KMS unreachable: %v", err)
+	}
+	return readKMSResponse(resp, operation)
+}
+
+// newAWSSigner signs with an asymmetric AWS KMS key, choosing the signing algorithm from the key type and options
+func newAWSSigner(ctx context.Context, keyID string) (crypto.Signer, error) {
+	body, err := awsKMSCall(ctx, keyID, "GetPublicKey", map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	var publicKeyResponse struct {
+		PublicKey string `json:"PublicKey"`
+	}
+	if err := json.Unmarshal(body, &publicKeyResponse); err != nil {
+		return nil, fmt.Errorf("invalid AWS KMS GetPublicKey response: %v", err)
+	}
+
+	der, err := base64.StdEncoding.DecodeString(publicKeyResponse.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AWS KMS public key: %v", err)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AWS KMS public key: %v", err)
+	}
+
+	return &signer{publicKey: publicKey, sign: func(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+		return awsSign(ctx, keyID, publicKey, digest, opts)
+	}}, nil
+}
+
+func awsSign(ctx context.Context, keyID string, publicKey crypto.PublicKey, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var bits string
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		bits = "256"
+	case crypto.SHA384:
+		bits = "384"
+	case crypto.SHA512:
+		bits = "512"
+	default:
+		return nil, fmt.Errorf("unsupported digest for AWS KMS: %v", opts.HashFunc())
+	}
+
+	var algorithm string
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		algorithm = "RSASSA_PKCS1_V1_5_SHA_" + bits
+		if _, pss := opts.(*rsa.PSSOptions); pss {
+			algorithm = "RSASSA_PSS_SHA_" + bits
+		}
+	case *ecdsa.PublicKey:
+		algorithm = "ECDSA_SHA_" + bits
+	default:
+		return nil, fmt.Errorf("unsupported AWS KMS key type %T", publicKey)
+	}
+
+	body, err := awsKMSCall(ctx, keyID, "Sign", map[string]interface{}{
+		"Message":          base64.StdEncoding.EncodeToString(digest),
+		"MessageType":      "DIGEST",
+		"SigningAlgorithm": algorithm,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var signResponse struct {
+		Signature string `json:"Signature"`
+	}
+	if err := json.Unmarshal(body, &signResponse); err != nil {
+		return nil, fmt.Errorf("invalid AWS KMS Sign response: %v", err)
+	}
+
+	return base64.StdEncoding.DecodeString(signResponse.Signature)
+}
@@ -0,0 +1,105 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+func gcpKMSURL(keyVersion string) string {
+	endpoint := strings.TrimSuffix(settings.Get("KMS_ENDPOINT_URL"), "/")
+	if endpoint == "" {
+		endpoint = "https://cloudkms.googleapis.com"
+	}
+	return endpoint + "/v1/" + keyVersion
+}
+
+func gcpHeaders(ctx context.Context) (http.Header, error) {
+	token, err := clients.GCPAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return http.Header{"Authorization": {"Bearer " + token}, "Content-Type": {"application/json"}}, nil
+}
+
+// newGCPSigner signs with a Cloud KMS key version, whose algorithm decides the padding and digest
+func newGCPSigner(ctx context.Context, keyVersion string) (crypto.Signer, error) {
+	headers, err := gcpHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := clients.GetWithHeaders(ctx, gcpKMSURL(keyVersion)+"/publicKey", headers)
+	if err != nil {
+		return nil, fmt.Errorf("Cloud KMS unreachable: %v", err)
+	}
+	body, err := readKMSResponse(resp, "publicKey")
+	if err != nil {
+		return nil, err
+	}
+
+	var publicKeyResponse struct {
+		PEM string `json:"pem"`
+	}
+	if err := json.Unmarshal(body, &publicKeyResponse); err != nil {
+		return nil, fmt.Errorf("invalid Cloud KMS public key response: %v", err)
+	}
+
+	publicKey, err := parsePublicKeyPEM(publicKeyResponse.PEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signer{publicKey: publicKey, sign: func(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+		return gcpSign(ctx, keyVersion, digest, opts)
+	}}, nil
+}
+
+func gcpSign(ctx context.Context, keyVersion string, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var digestName string
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		digestName = "sha256"
+	case crypto.SHA384:
+		digestName = "sha384"
+	case crypto.SHA512:
+		digestName = "sha512"
+	default:
+		return nil, fmt.Errorf("unsupported digest for Cloud KMS: %v", opts.HashFunc())
+	}
+
+	request, _ := json.Marshal(map[string]interface{}{
+		"digest": map[string]string{digestName: base64.StdEncoding.EncodeToString(digest)},
+	})
+
+	headers, err := gcpHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := clients.PostWithHeaders(ctx, gcpKMSURL(keyVersion)+":asymmetricSign", headers, bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("Cloud KMS unreachable: %v", err)
+	}
+	body, err := readKMSResponse(resp, "asymmetricSign")
+	if err != nil {
+		return nil, err
+	}
+
+	var signResponse struct {
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(body, &signResponse); err != nil {
+		return nil, fmt.Errorf("invalid Cloud KMS asymmetricSign response: %v", err)
+	}
+
+	return base64.StdEncoding.DecodeString(signResponse.Signature)
+}
@@ -0,0 +1,68 @@
+// Package kms signs with keys held in AWS KMS or GCP Cloud KMS, whose private halves never leave the KMS.
+// A key is named by a URI, aws-kms://<key ID or ARN> or gcp-kms://projects/.../cryptoKeyVersions/<version>,
+// and is used through crypto.Signer like a key read from a file.
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	awsKMSPrefix = "aws-kms://"
+	gcpKMSPrefix = "gcp-kms://"
+)
+
+// signer is a crypto.Signer whose signatures are made by the KMS
+type signer struct {
+	publicKey crypto.PublicKey
+	sign      func(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+func (s *signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+func (s *signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.sign(context.Background(), digest, opts)
+}
+
+// NewSigner fetches the public key of the KMS key named by the URI, returning a signer for it
+func NewSigner(ctx context.Context, uri string) (crypto.Signer, error) {
+	switch {
+	case strings.HasPrefix(uri, awsKMSPrefix):
+		return newAWSSigner(ctx, strings.TrimPrefix(uri, awsKMSPrefix))
+	case strings.HasPrefix(uri, gcpKMSPrefix):
+		return newGCPSigner(ctx, strings.TrimPrefix(uri, gcpKMSPrefix))
+	}
+	return nil, fmt.Errorf("KMS key %q must start with %s or %s", uri, awsKMSPrefix, gcpKMSPrefix)
+}
+
+func readKMSResponse(resp *http.Response, operation string) ([]byte, error) {
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read KMS %s response: %v", operation, err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("KMS %s returned %d: %s", operation, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func parsePublicKeyPEM(publicKeyPEM string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, errors.New("failed to decode KMS public key PEM")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
@@ -1,23 +1,39 @@
 package main // import "github.com/ONSdigital/eq-questionnaire-launcher"
 
 import (
+	"context"
 	"fmt"
 
 	"html/template"
+	"io"
+	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"html"
 
 	"github.com/ONSdigital/eq-questionnaire-launcher/authentication"
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/history"
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/metrics"
 	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/ONSdigital/eq-questionnaire-launcher/storage"
 	"github.com/ONSdigital/eq-questionnaire-launcher/surveys"
+	"github.com/ONSdigital/eq-questionnaire-launcher/tracing"
+	"github.com/ONSdigital/eq-questionnaire-launcher/webhook"
 	"github.com/gofrs/uuid"
 	"github.com/gorilla/mux"
 	"gopkg.in/square/go-jose.v2/json"
+	"gopkg.in/square/go-jose.v2/jwt"
 )
 
 func randomNumericString(n int) string {
@@ -30,27 +46,55 @@ func randomNumericString(n int) string {
 	return string(output)
 }
 
-func serveTemplate(templateName string, data interface{}, w http.ResponseWriter, r *http.Request) {
+var (
+	parsedTemplates      = make(map[string]*template.Template)
+	parsedTemplatesMutex sync.RWMutex
+)
+
+// parseTemplates parses every page template with the layout, keeping the current templates if any fail to parse
+func parseTemplates() error {
 	lp := filepath.Join("templates", "layout.html")
-	fp := filepath.Join("templates", filepath.Clean(templateName))
 
-	// Return a 404 if the template doesn't exist or is directory
-	info, err := os.Stat(fp)
-	if err != nil && (os.IsNotExist(err) || info.IsDir()) {
-		log.Println("Cannot find: " + fp)
-		http.NotFound(w, r)
-		return
+	pages, err := filepath.Glob(filepath.Join("templates", "*.html"))
+	if err != nil {
+		return err
 	}
 
-	tmpl, err := template.ParseFiles(lp, fp)
-	if err != nil {
-		log.Println(err.Error())
-		http.Error(w, http.StatusText(500), 500)
+	templates := make(map[string]*template.Template)
+	for _, fp := range pages {
+		if fp == lp {
+			continue
+		}
+
+		tmpl, err := template.ParseFiles(lp, fp)
+		if err != nil {
+			return err
+		}
+		templates[filepath.Base(fp)] = tmpl
+	}
+
+	parsedTemplatesMutex.Lock()
+	defer parsedTemplatesMutex.Unlock()
+
+	parsedTemplates = templates
+
+	return nil
+}
+
+func serveTemplate(templateName string, data interface{}, w http.ResponseWriter, r *http.Request) {
+	parsedTemplatesMutex.RLock()
+	tmpl, ok := parsedTemplates[filepath.Clean(templateName)]
+	parsedTemplatesMutex.RUnlock()
+
+	// Return a 404 if the template doesn't exist
+	if !ok {
+		logging.Warn("Cannot find template", "template", templateName)
+		http.NotFound(w, r)
 		return
 	}
 
 	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
-		log.Println(err.Error())
+		logging.Error("Failed to render template", "template", templateName, "err", err)
 		http.Error(w, http.StatusText(500), 500)
 	}
 }
@@ -59,23 +103,158 @@ type page struct {
 	Schemas                 surveys.LauncherSchemas
 	AccountServiceURL       string
 	AccountServiceLogOutURL string
+	Environments            []string
+	RegionPresets           []authentication.RegionPreset
+	ClaimDefaults           url.Values
 }
 
 func getStatusPage(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
+func getHealthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	failedKey, keyErr := authentication.CheckKeys()
+	if keyErr != nil {
+		logging.Error("Healthcheck failed to load key", "key", failedKey, "op", keyErr.Op, "err", keyErr.Err)
+		writeJSON(w, 503, map[string]string{"status": "unavailable", "key": failedKey, "op": keyErr.Op, "error": keyErr.Err})
+		return
+	}
+
+	writeJSON(w, 200, map[string]string{"status": "ok"})
+}
+
+// checkRunner reports whether the runner's /status endpoint responds successfully
+func checkRunner(ctx context.Context) error {
+	statusURL := strings.TrimSuffix(settings.Get("SURVEY_RUNNER_URL"), "/") + "/status"
+
+	resp, err := clients.GetWithContext(ctx, statusURL)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("runner status returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// getReadinessHandler checks the keys as the healthcheck does, that any shared state backend is reachable and, when
+// READINESS_CHECK_RUNNER is true, that the runner is up
+func getReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	failedKey, keyErr := authentication.CheckKeys()
+	if keyErr != nil {
+		logging.Error("Readiness check failed to load key", "key", failedKey, "op", keyErr.Op, "err", keyErr.Err)
+		writeJSON(w, 503, map[string]string{"status": "unavailable", "key": failedKey, "op": keyErr.Op, "error": keyErr.Err})
+		return
+	}
+
+	if err := storage.Check(); err != nil {
+		logging.Error("Readiness check failed to reach state backend", "err", err)
+		writeJSON(w, 503, map[string]string{"status": "unavailable", "state_backend": "unreachable", "error": err.Error()})
+		return
+	}
+
+	if settings.Get("READINESS_CHECK_RUNNER") == "true" {
+		if err := checkRunner(r.Context()); err != nil {
+			logging.Error("Readiness check failed to reach runner", "err", err)
+			writeJSON(w, 503, map[string]string{"status": "unavailable", "runner": "unreachable", "error": err.Error()})
+			return
+		}
+	}
+
+	writeJSON(w, 200, map[string]string{"status": "ok"})
+}
+
 func getLaunchHandler(w http.ResponseWriter, r *http.Request) {
+	accountServiceURL, accountServiceLogOutURL := accountServiceURLs(r, url.Values{})
 	p := page{
 		Schemas:                 surveys.GetAvailableSchemas(),
-		AccountServiceURL:       getAccountServiceURL(r),
-		AccountServiceLogOutURL: getAccountServiceURL(r),
+		AccountServiceURL:       accountServiceURL,
+		AccountServiceLogOutURL: accountServiceLogOutURL,
+		Environments:            authentication.EnvironmentNames(),
+		RegionPresets:           authentication.RegionPresets(),
+		ClaimDefaults:           authentication.ClaimDefaults(),
 	}
 	serveTemplate("launch.html", p, w, r)
 }
 
+func getSchemasHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, surveys.GetAvailableSchemas())
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// instrumentRequests records the duration of each request against the template of the route it matches and
+// logs it with a request ID, taken from X-Request-ID when the caller sends one, which is echoed in the response
+func instrumentRequests(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: 200}
+
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			generatedID, _ := uuid.NewV4()
+			requestID = generatedID.String()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		route := "unmatched"
+		var match mux.RouteMatch
+		if router.Match(r, &match) {
+			if template, err := match.Route.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header), r.Method+" "+route, tracing.KindServer)
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("request_id", requestID)
+
+		router.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttribute("http.response.status_code", recorder.status)
+		if recorder.status >= 500 {
+			span.SetError(http.StatusText(recorder.status))
+		}
+		span.End()
+
+		metrics.ObserveHTTPRequest(route, r.Method, recorder.status, start)
+		logging.Info("Request handled", "request_id", requestID, "method", r.Method, "route", route, "status", recorder.status, "duration_seconds", time.Since(start).Seconds())
+	})
+}
+
+// limitRequestBody caps the size of the request body at MAX_REQUEST_BODY_BYTES
+func limitRequestBody(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if maxBytes, err := strconv.ParseInt(settings.Get("MAX_REQUEST_BODY_BYTES"), 10, 64); err == nil && maxBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		}
+		next(w, r)
+	}
+}
+
+// isRequestTooLarge reports whether err was caused by exceeding the limitRequestBody limit
+func isRequestTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "http: request body too large")
+}
+
 func postLaunchHandler(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
+	if isRequestTooLarge(err) {
+		http.Error(w, http.StatusText(413), 413)
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("POST. r.ParseForm() err: %v", err), 500)
 		return
@@ -83,16 +262,38 @@ func postLaunchHandler(w http.ResponseWriter, r *http.Request) {
 	redirectURL(w, r)
 }
 
-func getMetadataHandler(w http.ResponseWriter, r *http.Request) {
+// launcherSchemaFromQuery finds the schema named by the schema and schema_url query values, responding with
+// an API error when there is none
+func launcherSchemaFromQuery(w http.ResponseWriter, r *http.Request) (surveys.LauncherSchema, bool) {
 	schema := r.URL.Query().Get("schema")
-	log.Println("Searching for schema: " + schema)
+	logging.Debug("Searching for schema", "schema", schema)
 
-	launcherSchema := surveys.FindSurveyByName(schema)
+	if schemaURL := r.URL.Query().Get("schema_url"); schemaURL != "" {
+		launcherSchema, schemaErr := authentication.ExternalLauncherSchema(schema, schemaURL)
+		if schemaErr != "" {
+			writeAPIError(w, 400, errorInvalidRequest, schemaErr)
+			return launcherSchema, false
+		}
+		return launcherSchema, true
+	}
 
-	metadata, err := authentication.GetRequiredMetadata(launcherSchema)
+	launcherSchema, ok := surveys.LookupSurveyByName(schema)
+	if !ok {
+		writeAPIError(w, 404, errorNotFound, "Schema not found: "+schema)
+	}
+	return launcherSchema, ok
+}
+
+func getMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	launcherSchema, ok := launcherSchemaFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	metadata, err := authentication.GetRequiredMetadataWithContext(r.Context(), launcherSchema)
 
 	if err != "" {
-		http.Error(w, fmt.Sprintf("GetRequiredMetadata err: %v", err), 500)
+		writeAPIError(w, 500, errorInternal, fmt.Sprintf("GetRequiredMetadata err: %v", err))
 		return
 	}
 
@@ -103,6 +304,48 @@ func getMetadataHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// getLanguagesHandler lists the languages the schema is available in, each with the region_code to launch it with
+func getLanguagesHandler(w http.ResponseWriter, r *http.Request) {
+	launcherSchema, ok := launcherSchemaFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	languages, err := authentication.GetSchemaLanguages(r.Context(), launcherSchema)
+	if err != "" {
+		writeAPIError(w, 500, errorInternal, fmt.Sprintf("GetSchemaLanguages err: %v", err))
+		return
+	}
+
+	writeJSON(w, 200, languages)
+}
+
+// getRegionPresetsHandler lists the region presets a launch can select with region_preset
+func getRegionPresetsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, authentication.RegionPresets())
+}
+
+// accountServiceURLs are ACCOUNT_SERVICE_URL and ACCOUNT_SERVICE_LOG_OUT_URL, or the launcher's own URL when unset,
+// overridden by any values the launch gives
+func accountServiceURLs(r *http.Request, values url.Values) (string, string) {
+	accountServiceURL := settings.Get("ACCOUNT_SERVICE_URL")
+	if accountServiceURL == "" {
+		accountServiceURL = getAccountServiceURL(r)
+	}
+	logOutURL := settings.Get("ACCOUNT_SERVICE_LOG_OUT_URL")
+	if logOutURL == "" {
+		logOutURL = getAccountServiceURL(r)
+	}
+
+	if value := values.Get("account_service_url"); value != "" {
+		accountServiceURL = value
+	}
+	if value := values.Get("account_service_log_out_url"); value != "" {
+		logOutURL = value
+	}
+	return accountServiceURL, logOutURL
+}
+
 func getAccountServiceURL(r *http.Request) string {
 	forwardedProtocol := r.Header.Get("X-Forwarded-Proto")
 
@@ -117,23 +360,242 @@ func getAccountServiceURL(r *http.Request) string {
 		html.EscapeString(r.Host))
 }
 
+// buildRunnerURL joins the runner path and token onto hostURL, refusing hosts outside REDIRECT_HOST_ALLOWLIST
+func buildRunnerURL(hostURL string, path string, token string) (string, error) {
+	if allowlist := settings.Get("REDIRECT_HOST_ALLOWLIST"); allowlist != "" {
+		parsedURL, err := url.Parse(hostURL)
+		if err != nil || parsedURL.Host == "" {
+			return "", fmt.Errorf("invalid runner URL: %s", hostURL)
+		}
+
+		allowed := false
+		for _, host := range strings.Split(allowlist, ",") {
+			host = strings.TrimSpace(host)
+			if strings.EqualFold(host, parsedURL.Host) || strings.EqualFold(host, parsedURL.Hostname()) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return "", fmt.Errorf("runner host is not in REDIRECT_HOST_ALLOWLIST: %s", parsedURL.Host)
+		}
+	}
+
+	return hostURL + path + "?token=" + url.QueryEscape(token), nil
+}
+
+type previewPage struct {
+	Claims     string
+	Token      string
+	SessionURL string
+	Mappings   []authentication.ClaimMapping
+}
+
+// previewLaunch shows the claims of the token that would be sent and the token itself, with a link to launch it
+func previewLaunch(w http.ResponseWriter, r *http.Request) {
+	token, claims, err := authentication.GenerateTokenAndClaimsFromPostWithContext(r.Context(), r.PostForm)
+	if err != "" {
+		http.Error(w, err, 500)
+		return
+	}
+	recordAudit(r, r.PostForm.Get("schema_name"), fmt.Sprint(claims["tx_id"]))
+
+	claimsJSON, marshalErr := json.MarshalIndent(claims, "", "  ")
+	if marshalErr != nil {
+		http.Error(w, fmt.Sprintf("json.MarshalIndent err: %v", marshalErr), 500)
+		return
+	}
+
+	runnerURL, runnerErr := authentication.RunnerURLFromPost(r.PostForm)
+	if runnerErr != "" {
+		http.Error(w, runnerErr, 400)
+		return
+	}
+
+	sessionURL, urlErr := buildRunnerURL(runnerURL, "/session", token)
+	if urlErr != nil {
+		http.Error(w, urlErr.Error(), 400)
+		return
+	}
+
+	serveTemplate("preview.html", previewPage{
+		Claims:     string(claimsJSON),
+		Token:      token,
+		SessionURL: sessionURL,
+		Mappings:   authentication.ClaimMappings(r.PostForm, claims),
+	}, w, r)
+}
+
+// maxFlushResponseBytes caps how much of the runner's flush response is reported back
+const maxFlushResponseBytes = 4096
+
+// flushSubmission posts a flusher token for the launch values to the runner's /flush endpoint and reports its response
+func flushSubmission(w http.ResponseWriter, r *http.Request) {
+	flushValues := url.Values{}
+	for key, values := range r.PostForm {
+		flushValues[key] = values
+	}
+	flushValues.Set("roles", "flusher")
+
+	token, claims, err := authentication.GenerateTokenAndClaimsFromPost(flushValues)
+	if err != "" {
+		http.Error(w, err, 500)
+		return
+	}
+	recordAudit(r, flushValues.Get("schema_name"), fmt.Sprint(claims["tx_id"]))
+
+	runnerURL, runnerErr := authentication.RunnerURLFromPost(flushValues)
+	if runnerErr != "" {
+		http.Error(w, runnerErr, 400)
+		return
+	}
+
+	flushURL, urlErr := buildRunnerURL(runnerURL, "/flush", token)
+	if urlErr != nil {
+		http.Error(w, urlErr.Error(), 400)
+		return
+	}
+
+	resp, postErr := clients.PostWithContext(r.Context(), flushURL, "application/x-www-form-urlencoded", nil)
+	if postErr != nil {
+		logging.Error("Flush request failed", "err", postErr)
+		http.Error(w, fmt.Sprintf("Flush request failed: %v", postErr), 502)
+		return
+	}
+	defer resp.Body.Close()
+
+	responseBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxFlushResponseBytes))
+	logging.Info("Flush request sent", "status", resp.StatusCode)
+
+	status := 200
+	if resp.StatusCode >= 300 {
+		status = 502
+	}
+	writeJSON(w, status, map[string]interface{}{"runner_status": resp.StatusCode, "runner_response": string(responseBody)})
+}
+
+const invalidLaunchValues = "Invalid launch values"
+
+// writeFieldErrors responds 400 with a line for each offending launch value
+func writeFieldErrors(w http.ResponseWriter, fields []authentication.FieldError) {
+	lines := []string{invalidLaunchValues}
+	for _, field := range fields {
+		lines = append(lines, field.Field+": "+field.Error)
+	}
+	http.Error(w, strings.Join(lines, "\n"), 400)
+}
+
+// recordLaunch adds a launch to the history and sends it to any WEBHOOK_URL, with the claims of its token
+func recordLaunch(values url.Values, txID string, outcome string, succeeded bool, claims map[string]interface{}) {
+	jti, _ := claims["jti"].(string)
+	history.Record(values, txID, jti, outcome)
+	webhook.NotifyLaunch(values, txID, outcome, succeeded, claims)
+}
+
 func redirectURL(w http.ResponseWriter, r *http.Request) {
-	hostURL := settings.Get("SURVEY_RUNNER_URL")
+	if r.PostForm.Get("action_preview") != "" && r.URL.Query().Get("fault") == "" {
+		previewLaunch(w, r)
+		return
+	}
+
+	if r.PostForm.Get("action_flush") != "" && r.URL.Query().Get("fault") == "" {
+		flushSubmission(w, r)
+		return
+	}
 
-	token, err := authentication.GenerateTokenFromPost(r.PostForm)
+	if r.PostForm.Get("action_dump") != "" && r.URL.Query().Get("fault") == "" {
+		dumpSession(w, r)
+		return
+	}
+
+	if r.PostForm.Get("action_link") != "" && r.URL.Query().Get("fault") == "" {
+		createFormLaunchLink(w, r)
+		return
+	}
+
+	if name, path, ok := runnerCallbackFromPost(r.PostForm); ok && r.URL.Query().Get("fault") == "" {
+		sendRunnerCallback(w, r, name, path)
+		return
+	}
+
+	var token, err string
+	var claims map[string]interface{}
+	txID := r.PostForm.Get("tx_id")
+	fault := r.URL.Query().Get("fault")
+	if fault != "" {
+		token, err = authentication.GenerateFaultyTokenFromPost(r.PostForm, fault)
+	} else {
+		if fields := authentication.ValidateLaunchValues(r.PostForm); len(fields) > 0 {
+			recordLaunch(r.PostForm, txID, "invalid launch values", false, nil)
+			writeFieldErrors(w, fields)
+			return
+		}
+		token, claims, err = authentication.GenerateTokenAndClaimsFromPostWithContext(r.Context(), r.PostForm)
+		if claimsTxID, ok := claims["tx_id"].(string); ok {
+			txID = claimsTxID
+			tracing.FromContext(r.Context()).SetAttribute("tx_id", txID)
+		}
+	}
 	if err != "" {
+		recordLaunch(r.PostForm, txID, "failed: "+err, false, claims)
 		http.Error(w, err, 500)
 		return
 	}
+	recordAudit(r, r.PostForm.Get("schema_name"), txID)
+
+	hostURL, runnerErr := authentication.RunnerURLFromPost(r.PostForm)
+	if runnerErr != "" {
+		http.Error(w, runnerErr, 400)
+		return
+	}
 
 	launchAction := r.PostForm.Get("action_launch")
 	flushAction := r.PostForm.Get("action_flush")
-	log.Println("Request: " + r.PostForm.Encode())
+	verifyAction := r.PostForm.Get("action_verify")
+	logging.Info("Launch request received", "schema_name", r.PostForm.Get("schema_name"), "schema_url", r.PostForm.Get("schema_url"))
+	logging.Sensitive("Launch values", "values", r.PostForm.Encode())
+
+	outcome := "redirected to runner"
+	if fault != "" {
+		outcome += " with fault " + fault
+	}
 
 	if flushAction != "" {
-		http.Redirect(w, r, hostURL+"/flush?token="+token, 307)
+		flushURL, err := buildRunnerURL(hostURL, "/flush", token)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		recordLaunch(r.PostForm, txID, "flush "+outcome, true, claims)
+		http.Redirect(w, r, flushURL, 307)
+	} else if verifyAction != "" {
+		sessionURL, err := buildRunnerURL(hostURL, "/session", token)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		verification, verifyErr := verifyLaunch(r.Context(), sessionURL)
+		if verifyErr != nil {
+			logging.Error("Launch verification failed", "err", verifyErr)
+			recordLaunch(r.PostForm, txID, "verification failed: "+verifyErr.Error(), false, claims)
+			http.Error(w, fmt.Sprintf("Launch verification failed: %v", verifyErr), 502)
+			return
+		}
+		recordLaunch(r.PostForm, txID, fmt.Sprintf("verified, runner responded %d", verification.RunnerStatus), verification.Succeeded, claims)
+		status := 200
+		if !verification.Succeeded {
+			status = 502
+		}
+		writeJSON(w, status, verification)
 	} else if launchAction != "" {
-		http.Redirect(w, r, hostURL+"/session?token="+token, 301)
+		sessionURL, err := buildRunnerURL(hostURL, "/session", token)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		recordLaunch(r.PostForm, txID, outcome, true, claims)
+		http.Redirect(w, r, sessionURL, 301)
 	} else {
 		http.Error(w, fmt.Sprintf("Invalid Action"), 500)
 	}
@@ -141,47 +603,558 @@ func redirectURL(w http.ResponseWriter, r *http.Request) {
 
 func quickLauncherHandler(w http.ResponseWriter, r *http.Request) {
 	hostURL := settings.Get("SURVEY_RUNNER_URL")
-	accountServiceURL := getAccountServiceURL(r)
-	AccountServiceLogOutURL := getAccountServiceURL(r)
 	urlValues := r.URL.Query()
+	accountServiceURL, AccountServiceLogOutURL := accountServiceURLs(r, urlValues)
 	surveyURL := urlValues.Get("url")
-	defaultValues := authentication.GetDefaultValues()
-	log.Println("Quick launch request received", surveyURL)
 
-	urlValues.Add("ru_ref", defaultValues["ru_ref"])
-	collectionExerciseSid, _ := uuid.NewV4()
-	caseID, _ := uuid.NewV4()
-	urlValues.Add("collection_exercise_sid", collectionExerciseSid.String())
-	urlValues.Add("case_id", caseID.String())
-	urlValues.Add("response_id", randomNumericString(16))
-	urlValues.Add("language_code", defaultValues["language_code"])
+	if schema := urlValues.Get("schema"); surveyURL == "" && schema != "" {
+		quickLaunchSchema(w, r, urlValues, schema)
+		return
+	}
+
+	logging.Info("Quick launch request received", "url", surveyURL)
 
-	token, err := authentication.GenerateTokenFromDefaults(surveyURL, accountServiceURL, AccountServiceLogOutURL, urlValues)
+	addQuickLaunchDefaults(urlValues)
+
+	token, claims, err := authentication.GenerateTokenAndClaimsFromDefaults(surveyURL, accountServiceURL, AccountServiceLogOutURL, urlValues)
 	if err != "" {
 		http.Error(w, err, 400)
 		return
 	}
+	schemaName, _ := claims["schema_name"].(string)
+	txID, _ := claims["tx_id"].(string)
+	recordAudit(r, schemaName, txID)
 
 	if surveyURL != "" {
-		http.Redirect(w, r, hostURL+"/session?token="+token, 302)
+		sessionURL, err := buildRunnerURL(hostURL, "/session", token)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		http.Redirect(w, r, sessionURL, 302)
 	} else {
 		http.Error(w, fmt.Sprintf("Not Found"), 404)
 	}
 }
 
+// addQuickLaunchDefaults fills the claims a quick launch leaves out with generated or default values
+func addQuickLaunchDefaults(urlValues url.Values) {
+	defaultValues := authentication.GetDefaultValues()
+	collectionExerciseSid, _ := uuid.NewV4()
+	caseID, _ := uuid.NewV4()
+
+	for key, value := range map[string]string{
+		"ru_ref":                  defaultValues["ru_ref"],
+		"collection_exercise_sid": collectionExerciseSid.String(),
+		"case_id":                 caseID.String(),
+		"response_id":             randomNumericString(16),
+		"language_code":           defaultValues["language_code"],
+	} {
+		if urlValues.Get(key) == "" {
+			urlValues.Set(key, value)
+		}
+	}
+}
+
+// quickLaunchSchema launches the schema named by the schema query parameter, a file name such as
+// test_textfield.json or a URL, with the other query parameters as claims and defaults for the rest,
+// so that a launch can be bookmarked as a single link
+func quickLaunchSchema(w http.ResponseWriter, r *http.Request, urlValues url.Values, schema string) {
+	urlValues.Del("schema")
+	setSchemaValue(urlValues, schema)
+	logging.Info("Quick launch request received", "schema_name", urlValues.Get("schema_name"), "schema_url", urlValues.Get("schema_url"))
+
+	if schemaName := urlValues.Get("schema_name"); schemaName != "" {
+		if _, ok := surveys.LookupSurveyByName(schemaName); !ok {
+			http.Error(w, "Schema not found: "+schemaName, 404)
+			return
+		}
+	}
+
+	addQuickLaunchDefaults(urlValues)
+	accountServiceURL, accountServiceLogOutURL := accountServiceURLs(r, urlValues)
+	urlValues.Set("account_service_url", accountServiceURL)
+	urlValues.Set("account_service_log_out_url", accountServiceLogOutURL)
+
+	if fields := authentication.ValidateLaunchValues(urlValues); len(fields) > 0 {
+		writeFieldErrors(w, fields)
+		return
+	}
+
+	token, claims, err := authentication.GenerateTokenAndClaimsFromPostWithContext(r.Context(), urlValues)
+	txID, _ := claims["tx_id"].(string)
+	if err != "" {
+		recordLaunch(urlValues, urlValues.Get("tx_id"), "failed: "+err, false, claims)
+		http.Error(w, err, 400)
+		return
+	}
+	recordAudit(r, urlValues.Get("schema_name"), txID)
+
+	runnerURL, runnerErr := authentication.RunnerURLFromPost(urlValues)
+	if runnerErr != "" {
+		http.Error(w, runnerErr, 400)
+		return
+	}
+
+	sessionURL, buildErr := buildRunnerURL(runnerURL, "/session", token)
+	if buildErr != nil {
+		http.Error(w, buildErr.Error(), 400)
+		return
+	}
+	recordLaunch(urlValues, txID, "quick launch redirected to runner", true, claims)
+	http.Redirect(w, r, sessionURL, 302)
+}
+
+type targetTokensRequest struct {
+	Values  map[string]interface{}       `json:"values"`
+	Targets []authentication.TokenTarget `json:"targets"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	responseJSON, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("json.Marshal err: %v", err), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(responseJSON)
+}
+
+func postTargetTokensHandler(w http.ResponseWriter, r *http.Request) {
+	var request targetTokensRequest
+	// numbers are kept as written so that long references such as ru_ref are not rounded
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&request); err != nil {
+		if isRequestTooLarge(err) {
+			writeAPIError(w, 413, errorRequestTooLarge, http.StatusText(413))
+			return
+		}
+		writeAPIError(w, 400, errorInvalidRequest, fmt.Sprintf("Invalid JSON body: %v", err))
+		return
+	}
+
+	values := authentication.ValuesFromJSON(request.Values)
+	tokens, keys, txID, err := authentication.GenerateTokensForTargets(values, request.Targets)
+	if err != "" {
+		writeAPIError(w, 400, errorTokenFailed, err)
+		return
+	}
+	recordAudit(r, values.Get("schema_name"), txID)
+
+	response := map[string]interface{}{"tokens": tokens, "keys": keys, "tx_id": txID}
+
+	if settings.Get("RETURN_WRAPPED_TOKENS") == "true" {
+		wrappedTokens := make(map[string]string)
+		for name, token := range tokens {
+			wrappedTokens[name] = authentication.WrapToken(token)
+		}
+		response["tokens_base64url"] = wrappedTokens
+	}
+
+	writeJSON(w, 200, response)
+}
+
+// readLaunchValues reads the launch values of a JSON or form body, responding with an API error and returning
+// false when they cannot be read
+func readLaunchValues(w http.ResponseWriter, r *http.Request) (url.Values, bool) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		body, err := ioutil.ReadAll(r.Body)
+		if isRequestTooLarge(err) {
+			writeAPIError(w, 413, errorRequestTooLarge, http.StatusText(413))
+			return nil, false
+		}
+		if err != nil {
+			writeAPIError(w, 500, errorInternal, fmt.Sprintf("Error reading body: %v", err))
+			return nil, false
+		}
+		values, valuesErr := authentication.ValuesFromJSONBodyWithContext(r.Context(), body)
+		if valuesErr != "" {
+			writeAPIError(w, 400, errorInvalidRequest, valuesErr)
+			return nil, false
+		}
+		return values, true
+	}
+
+	err := r.ParseForm()
+	if isRequestTooLarge(err) {
+		writeAPIError(w, 413, errorRequestTooLarge, http.StatusText(413))
+		return nil, false
+	}
+	if err != nil {
+		writeAPIError(w, 400, errorInvalidRequest, fmt.Sprintf("POST. r.ParseForm() err: %v", err))
+		return nil, false
+	}
+	return r.PostForm, true
+}
+
+// postTokenHandler generates a token from a JSON object or form encoded launch values and returns it
+// with its tx_id and expiry, so that automated clients need not follow the launch redirect
+func postTokenHandler(w http.ResponseWriter, r *http.Request) {
+	values, ok := readLaunchValues(w, r)
+	if !ok {
+		return
+	}
+
+	// token_format chooses the kind of token and is not a claim
+	generate := func(values url.Values) (string, map[string]interface{}, string) {
+		return authentication.GenerateTokenAndClaimsFromPostWithContext(r.Context(), values)
+	}
+	tokenFormat := values.Get("token_format")
+	switch tokenFormat {
+	case "", "jwe":
+	case "jws":
+		if !authentication.SignedOnlyTokensEnabled() {
+			writeAPIError(w, 403, errorForbidden, "Signed only tokens are disabled, DEVELOPER_MODE is not true")
+			return
+		}
+		generate = authentication.GenerateSignedTokenAndClaimsFromPost
+	default:
+		writeAPIError(w, 400, errorInvalidRequest, "Unsupported token_format: "+tokenFormat+", expected jwe or jws")
+		return
+	}
+	values.Del("token_format")
+
+	// verify_launch opens the token's runner session and reports the outcome, and is not a claim
+	verify := values.Get("verify_launch") == "true"
+	values.Del("verify_launch")
+	runnerURL, runnerErr := authentication.RunnerURLFromPost(values)
+	if verify && runnerErr != "" {
+		writeAPIError(w, 400, errorInvalidRequest, runnerErr)
+		return
+	}
+
+	if fields := authentication.ValidateLaunchValues(values); len(fields) > 0 {
+		writeAPIError(w, 400, errorInvalidLaunchValues, invalidLaunchValues, fields...)
+		return
+	}
+
+	token, claims, tokenErr := generate(values)
+	if tokenErr != "" {
+		writeAPIError(w, 400, errorTokenFailed, tokenErr)
+		return
+	}
+
+	tracing.FromContext(r.Context()).SetAttribute("tx_id", fmt.Sprint(claims["tx_id"]))
+	recordAudit(r, values.Get("schema_name"), fmt.Sprint(claims["tx_id"]))
+
+	response := map[string]interface{}{"token": token, "tx_id": claims["tx_id"]}
+	if exp, ok := claims["exp"].(jwt.NumericDate); ok {
+		response["expires_at"] = exp.Time().UTC().Format(time.RFC3339)
+	}
+	if settings.Get("RETURN_WRAPPED_TOKENS") == "true" {
+		response["token_base64url"] = authentication.WrapToken(token)
+	}
+
+	var sessionURL string
+	var urlErr error
+	if runnerErr == "" {
+		sessionURL, urlErr = buildRunnerURL(runnerURL, "/session", token)
+		// The runner only accepts encrypted tokens, so signed only tokens have no launch URL
+		if urlErr == nil && tokenFormat != "jws" {
+			response["launch_url"] = sessionURL
+		}
+	}
+
+	if verify {
+		if urlErr != nil {
+			writeAPIError(w, 400, errorInvalidRequest, urlErr.Error())
+			return
+		}
+		verification, verifyErr := verifyLaunch(r.Context(), sessionURL)
+		if verifyErr != nil {
+			writeAPIError(w, 502, errorLaunchFailed, fmt.Sprintf("Launch verification failed: %v", verifyErr))
+			return
+		}
+		response["launch"] = verification
+	}
+
+	writeJSON(w, 200, response)
+}
+
+// postPooledTokenHandler hands out a token pregenerated from TOKEN_POOL_TEMPLATE, for automated suites which
+// launch in bursts
+func postPooledTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if !authentication.TokenPoolEnabled() {
+		writeAPIError(w, 404, errorNotFound, "The token pool is disabled, TOKEN_POOL_SIZE is not set")
+		return
+	}
+
+	pooled, hit, err := authentication.TakePooledToken()
+	if err != "" {
+		writeAPIError(w, 500, errorTokenFailed, err)
+		return
+	}
+	recordAudit(r, authentication.TokenPoolSchemaName(), pooled.TxID)
+
+	response := map[string]interface{}{"token": pooled.Token, "tx_id": pooled.TxID, "pooled": hit}
+	if !pooled.ExpiresAt.IsZero() {
+		response["expires_at"] = pooled.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if settings.Get("RETURN_WRAPPED_TOKENS") == "true" {
+		response["token_base64url"] = authentication.WrapToken(pooled.Token)
+	}
+
+	writeJSON(w, 200, response)
+}
+
+type batchTokensRequest struct {
+	Launches []map[string]interface{} `json:"launches"`
+	Template map[string]interface{}   `json:"template"`
+	Count    int                      `json:"count"`
+}
+
+func postBatchTokensHandler(w http.ResponseWriter, r *http.Request) {
+	var request batchTokensRequest
+	// numbers are kept as written so that long references such as ru_ref are not rounded
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&request); err != nil {
+		if isRequestTooLarge(err) {
+			writeAPIError(w, 413, errorRequestTooLarge, http.StatusText(413))
+			return
+		}
+		writeAPIError(w, 400, errorInvalidRequest, fmt.Sprintf("Invalid JSON body: %v", err))
+		return
+	}
+
+	if request.Template != nil && len(request.Launches) > 0 {
+		writeAPIError(w, 400, errorInvalidRequest, "A batch is either a list of launches or a template and count, not both")
+		return
+	}
+
+	var sets []url.Values
+	if request.Template != nil {
+		sets = authentication.ExpandBatchTemplate(authentication.ValuesFromJSON(request.Template), request.Count)
+	} else {
+		for _, launch := range request.Launches {
+			sets = append(sets, authentication.ValuesFromJSON(launch))
+		}
+	}
+
+	if maxSize, err := strconv.Atoi(settings.Get("MAX_BATCH_SIZE")); err == nil && maxSize > 0 && len(sets) > maxSize {
+		writeAPIError(w, 400, errorInvalidRequest, fmt.Sprintf("A batch may have at most %d launches, got %d", maxSize, len(sets)))
+		return
+	}
+
+	tokens, txIDs, failures, err := authentication.GenerateTokensFromPosts(sets)
+	if err != "" {
+		writeAPIError(w, 400, errorTokenFailed, err)
+		return
+	}
+	for i, values := range sets {
+		if _, failed := failures[i]; !failed {
+			recordAudit(r, values.Get("schema_name"), txIDs[i])
+		}
+	}
+
+	// JSON object keys are strings, and the json package used here does not convert integer keys itself
+	failuresByIndex := make(map[string]string, len(failures))
+	for i, failure := range failures {
+		failuresByIndex[strconv.Itoa(i)] = failure
+	}
+
+	writeJSON(w, 200, map[string]interface{}{"tokens": tokens, "tx_ids": txIDs, "errors": failuresByIndex})
+}
+
+func getDecodeHandler(w http.ResponseWriter, r *http.Request) {
+	serveTemplate("decode.html", nil, w, r)
+}
+
+type decodeRequest struct {
+	Token string `json:"token"`
+}
+
+func postDecodeHandler(w http.ResponseWriter, r *http.Request) {
+	var request decodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		if isRequestTooLarge(err) {
+			writeAPIError(w, 413, errorRequestTooLarge, http.StatusText(413))
+			return
+		}
+		writeAPIError(w, 400, errorInvalidRequest, fmt.Sprintf("Invalid JSON body: %v", err))
+		return
+	}
+
+	claims, keys, err := authentication.DecodeTokenWithKeys(request.Token)
+	if err != nil {
+		writeJSON(w, 400, map[string]interface{}{"error": apiError{Code: errorDecodeFailed, Message: err.Error()}, "claims": claims, "keys": keys})
+		return
+	}
+
+	writeJSON(w, 200, map[string]interface{}{"claims": claims, "keys": keys})
+}
+
+// getBucketSchemaHandler serves the schema JSON of a schema from SCHEMA_BUCKETS, read with the launcher's credentials
+func getBucketSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	schema, found, err := surveys.FetchBucketSchema(r.Context(), name)
+	if err != nil {
+		logging.Error("Failed to read bucket schema", "schema", name, "err", err)
+		writeAPIError(w, 500, errorInternal, err.Error())
+		return
+	}
+	if !found {
+		writeAPIError(w, 404, errorNotFound, "Schema not found: "+name)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(schema)
+}
+
+// getCIRInstrumentsHandler lists the questionnaires held by the Collection Instrument Registry
+func getCIRInstrumentsHandler(w http.ResponseWriter, r *http.Request) {
+	if settings.Get("CIR_API_URL") == "" {
+		writeAPIError(w, 404, errorNotFound, "CIR_API_URL is not set")
+		return
+	}
+
+	instruments, err := surveys.FetchCIRInstruments(r.Context())
+	if err != nil {
+		logging.Error("Failed to list collection instruments", "err", err)
+		writeAPIError(w, 500, errorInternal, err.Error())
+		return
+	}
+
+	writeJSON(w, 200, instruments)
+}
+
+// getSupplementaryDataHandler lists the supplementary datasets SDS holds for the survey_id and period_id query parameters
+func getSupplementaryDataHandler(w http.ResponseWriter, r *http.Request) {
+	if settings.Get("SDS_API_URL") == "" {
+		writeAPIError(w, 404, errorNotFound, "SDS_API_URL is not set")
+		return
+	}
+
+	surveyID, periodID := r.URL.Query().Get("survey_id"), r.URL.Query().Get("period_id")
+	if surveyID == "" || periodID == "" {
+		writeAPIError(w, 400, errorInvalidRequest, "survey_id and period_id are required")
+		return
+	}
+
+	datasets, err := authentication.GetSupplementaryDatasets(r.Context(), surveyID, periodID)
+	if err != "" {
+		writeAPIError(w, 500, errorInternal, err)
+		return
+	}
+
+	writeJSON(w, 200, datasets)
+}
+
+// getRandomiseHandler returns generated respondent values, in the form of launch values, for the launch form to fill in
+func getRandomiseHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, authentication.RandomLaunchValues())
+}
+
+func getJWKSHandler(w http.ResponseWriter, r *http.Request) {
+	jwks, err := authentication.BuildJWKS()
+	if err != nil {
+		writeAPIError(w, 500, errorInternal, fmt.Sprintf("BuildJWKS failed err: %v", err))
+		return
+	}
+
+	writeJSON(w, 200, jwks)
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		os.Exit(tokenCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		os.Exit(loadTestCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		os.Exit(benchCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	if err := parseTemplates(); err != nil {
+		log.Fatal("Failed to parse templates: ", err)
+	}
+
+	if settings.Get("DEV_MODE") == "true" {
+		logging.Info("DEV_MODE enabled, watching templates and config files for changes")
+		watchForChanges()
+	} else {
+		reloadOnFileChange()
+	}
+
+	if failedKey, keyErr := authentication.PreloadKeys(); keyErr != nil {
+		logging.Warn("Failed to preload key, it will be loaded on first use", "key", failedKey, "op", keyErr.Op, "err", keyErr.Err)
+	}
+
+	if err := storage.Check(); err != nil {
+		log.Fatal("Failed to open state backend: ", err)
+	}
+
+	if err := authentication.StartTokenPool(); err != nil {
+		log.Fatal("Failed to start token pool: ", err)
+	}
+
 	r := mux.NewRouter()
 
 	// Launch handlers
 	r.HandleFunc("/", getLaunchHandler).Methods("GET")
-	r.HandleFunc("/", postLaunchHandler).Methods("POST")
+	r.HandleFunc("/", rateLimit(limitRequestBody(postLaunchHandler))).Methods("POST")
 	r.HandleFunc("/metadata", getMetadataHandler).Methods("GET")
+	r.HandleFunc("/languages", getLanguagesHandler).Methods("GET")
+	r.HandleFunc("/region-presets", getRegionPresetsHandler).Methods("GET")
+	r.HandleFunc("/schemas", getSchemasHandler).Methods("GET")
+	r.HandleFunc("/supplementary-data", getSupplementaryDataHandler).Methods("GET")
+	r.HandleFunc("/cir-instruments", getCIRInstrumentsHandler).Methods("GET")
+	r.HandleFunc("/bucket-schemas/{name}", getBucketSchemaHandler).Methods("GET")
+	r.HandleFunc("/uploaded-schemas", getUploadedSchemasHandler).Methods("GET")
+	r.HandleFunc("/uploaded-schemas", limitRequestBody(postUploadedSchemaHandler)).Methods("POST")
+	r.HandleFunc("/uploaded-schemas/{name}", getUploadedSchemaHandler).Methods("GET")
+
+	// Launch profiles
+	r.HandleFunc("/profiles", getProfilesHandler).Methods("GET")
+	r.HandleFunc("/profiles/{name}", getProfileHandler).Methods("GET")
+	r.HandleFunc("/profiles/{name}", limitRequestBody(postProfileHandler)).Methods("POST")
+	r.HandleFunc("/profiles/{name}", deleteProfileHandler).Methods("DELETE")
+	r.HandleFunc("/profiles/{name}/launch", rateLimit(limitRequestBody(postProfileLaunchHandler))).Methods("POST")
+
+	// Debug views
+	r.HandleFunc("/randomise", getRandomiseHandler).Methods("GET")
+	r.HandleFunc("/history", getHistoryHandler).Methods("GET")
+	r.HandleFunc("/history/entries", getHistoryEntriesHandler).Methods("GET")
+	r.HandleFunc("/history/issued", getIssuedTokensHandler).Methods("GET")
+	r.HandleFunc("/history/{id}/launch", rateLimit(limitRequestBody(postHistoryLaunchHandler))).Methods("POST")
 
 	//Author Launcher with passed parameters in Url
-	r.HandleFunc("/quick-launch", quickLauncherHandler).Methods("GET")
+	r.HandleFunc("/quick-launch", rateLimit(quickLauncherHandler)).Methods("GET")
+
+	// Token API handlers
+	r.HandleFunc("/tokens", requireAPIKey(rateLimit(limitRequestBody(postTokenHandler)))).Methods("POST")
+	r.HandleFunc("/tokens/targets", requireAPIKey(rateLimit(limitRequestBody(postTargetTokensHandler)))).Methods("POST")
+	r.HandleFunc("/tokens/batch", requireAPIKey(rateLimit(limitRequestBody(postBatchTokensHandler)))).Methods("POST")
+	r.HandleFunc("/tokens/pool", requireAPIKey(rateLimit(postPooledTokenHandler))).Methods("POST")
+	r.HandleFunc("/tokens/callbacks/{name}", requireAPIKey(rateLimit(limitRequestBody(postCallbackTokenHandler)))).Methods("POST")
+	r.HandleFunc("/links", requireAPIKey(rateLimit(limitRequestBody(postLaunchLinkHandler)))).Methods("POST")
+	r.HandleFunc("/l/{id}", rateLimit(getLaunchLinkHandler)).Methods("GET")
+	r.HandleFunc("/batch", getBatchCSVHandler).Methods("GET")
+	r.HandleFunc("/batch", rateLimit(limitRequestBody(postBatchCSVHandler))).Methods("POST")
+	r.HandleFunc("/decode", getDecodeHandler).Methods("GET")
+	r.HandleFunc("/decode", limitRequestBody(postDecodeHandler)).Methods("POST")
+
+	// Key discovery
+	r.HandleFunc("/.well-known/jwks.json", getJWKSHandler).Methods("GET")
+	r.HandleFunc("/openapi.json", getOpenAPIHandler).Methods("GET")
+
+	// Admin handlers
+	r.HandleFunc("/admin/reload", requireAdmin(postReloadHandler)).Methods("POST")
+	r.HandleFunc("/admin/settings", requireAdmin(getAdminSettingsHandler)).Methods("GET")
+	r.HandleFunc("/admin/settings", requireAdmin(limitRequestBody(patchAdminSettingsHandler))).Methods("PATCH")
+	r.HandleFunc("/admin/audit", requireAdmin(getAuditHandler)).Methods("GET")
+	reloadOnSIGHUP()
 
 	// Status Page
 	r.HandleFunc("/status", getStatusPage).Methods("GET")
+	r.HandleFunc("/healthcheck", getHealthcheckHandler).Methods("GET")
+	r.HandleFunc("/ready", getReadinessHandler).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
 
 	// Serve static assets
 	staticFs := http.FileServer(http.Dir("static"))
@@ -190,6 +1163,20 @@ func main() {
 	// Bind to a port and pass our router in
 	hostname := settings.Get("GO_LAUNCH_A_SURVEY_LISTEN_HOST") + ":" + settings.Get("GO_LAUNCH_A_SURVEY_LISTEN_PORT")
 
-	log.Println("Listening on " + hostname)
-	log.Fatal(http.ListenAndServe(hostname, r))
+	if authentication.FaultInjectionEnabled() {
+		logging.Warn("FAULT_INJECTION is enabled, malformed tokens can be requested with ?fault=")
+	}
+
+	if (settings.Get("BASIC_AUTH_USERNAME") == "") != (settings.Get("BASIC_AUTH_PASSWORD") == "") {
+		log.Fatal("BASIC_AUTH_USERNAME and BASIC_AUTH_PASSWORD must be set together")
+	}
+
+	if _, err := apiKeys(); err != nil {
+		log.Fatal(err)
+	}
+
+	logging.Info("Listening", "address", hostname, "tls", settings.Get("TLS_CERT_PATH") != "")
+	if err := serve(newServer(hostname, requireAccess(instrumentRequests(r)))); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/authentication"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// useSetting overrides a setting until the test ends
+func useSetting(tb testing.TB, name string, value string) {
+	tb.Helper()
+	if err := settings.Override(name, value); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { settings.ClearOverride(name) })
+}
+
+// useGeneratedEncryptionKey encrypts with the public half of a new RSA key, and decodes with its private half
+func useGeneratedEncryptionKey(tb testing.TB) {
+	tb.Helper()
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	dir := tb.TempDir()
+	encryptionKeyPath := filepath.Join(dir, "encryption-key.pem")
+	decryptionKeyPath := filepath.Join(dir, "decryption-key.pem")
+	for path, block := range map[string]*pem.Block{
+		encryptionKeyPath: {Type: "PUBLIC KEY", Bytes: publicKey},
+		decryptionKeyPath: {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)},
+	} {
+		if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	useSetting(tb, "JWT_ENCRYPTION_KEY_PATH", encryptionKeyPath)
+	useSetting(tb, "JWT_DECRYPTION_KEY_PATH", decryptionKeyPath)
+}
+
+// decodeClaims decrypts and verifies a token made with useGeneratedEncryptionKey
+func decodeClaims(t *testing.T, token string) map[string]interface{} {
+	t.Helper()
+	claims, err := authentication.DecodeToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestLimitRequestBody(t *testing.T) {
+	useSetting(t, "MAX_REQUEST_BODY_BYTES", "64")
+
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"launch form over the limit", postLaunchHandler, "application/x-www-form-urlencoded", "schema_name=" + strings.Repeat("a", 100), 413},
+		{"token form over the limit", postTokenHandler, "application/x-www-form-urlencoded", "schema_name=" + strings.Repeat("a", 100), 413},
+		{"token JSON over the limit", postTokenHandler, "application/json", `{"schema_name": "` + strings.Repeat("a", 100) + `"}`, 413},
+		{"target tokens JSON over the limit", postTargetTokensHandler, "application/json", `{"values": {"schema_name": "` + strings.Repeat("a", 100) + `"}}`, 413},
+		{"target tokens JSON within the limit", postTargetTokensHandler, "application/json", `{"values": `, 400},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest("POST", "/", strings.NewReader(test.body))
+			request.Header.Set("Content-Type", test.contentType)
+			recorder := httptest.NewRecorder()
+
+			limitRequestBody(test.handler)(recorder, request)
+
+			if recorder.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestLimitRequestBodyUnlimited(t *testing.T) {
+	useSetting(t, "MAX_REQUEST_BODY_BYTES", "0")
+
+	body := `{"values": ` + strings.Repeat(" ", 10000)
+	request := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+
+	limitRequestBody(postTargetTokensHandler)(recorder, request)
+
+	if recorder.Code != 400 {
+		t.Errorf("status = %d, want 400: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestIsRequestTooLarge(t *testing.T) {
+	if isRequestTooLarge(nil) {
+		t.Error("isRequestTooLarge(nil) = true")
+	}
+	if isRequestTooLarge(errors.New("unexpected EOF")) {
+		t.Error("isRequestTooLarge(unexpected EOF) = true")
+	}
+	if !isRequestTooLarge(errors.New("http: request body too large")) {
+		t.Error("isRequestTooLarge(http: request body too large) = false")
+	}
+}
+
+func TestBuildRunnerURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowlist string
+		hostURL   string
+		want      string
+		wantErr   string
+	}{
+		{"no allowlist", "", "https://anywhere.example.com", "https://anywhere.example.com/session?token=a%2Bb", ""},
+		{"allowed host", "runner.example.com, other.example.com", "https://runner.example.com", "https://runner.example.com/session?token=a%2Bb", ""},
+		{"allowed host in another case", "Runner.Example.com", "https://runner.example.com", "https://runner.example.com/session?token=a%2Bb", ""},
+		{"allowed hostname with a port", "localhost", "http://localhost:5000", "http://localhost:5000/session?token=a%2Bb", ""},
+		{"allowed host and port", "localhost:5000", "http://localhost:5000", "http://localhost:5000/session?token=a%2Bb", ""},
+		{"other port of an allowed host and port", "localhost:5000", "http://localhost:5001", "", "runner host is not in REDIRECT_HOST_ALLOWLIST: localhost:5001"},
+		{"disallowed host", "runner.example.com", "https://evil.example.com", "", "runner host is not in REDIRECT_HOST_ALLOWLIST: evil.example.com"},
+		{"allowed host as a suffix", "example.com", "https://runner.example.com.evil.test", "", "runner host is not in REDIRECT_HOST_ALLOWLIST: runner.example.com.evil.test"},
+		{"URL without a host", "runner.example.com", "/session", "", "invalid runner URL: /session"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useSetting(t, "REDIRECT_HOST_ALLOWLIST", test.allowlist)
+
+			got, err := buildRunnerURL(test.hostURL, "/session", "a+b")
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("buildRunnerURL error = %v, want %s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildRunnerURL failed: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("buildRunnerURL = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPostTargetTokensHandlerNumericValues(t *testing.T) {
+	useGeneratedEncryptionKey(t)
+
+	body := `{"values": {"schema_url": "` + schemaServer(t) + `", "collection_exercise_sid": "789", "ru_ref": 49900000001}, "targets": [{"name": "runner"}]}`
+	request := httptest.NewRequest("POST", "/tokens/targets", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	postTargetTokensHandler(recorder, request)
+
+	if recorder.Code != 200 {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body.String())
+	}
+	var response struct {
+		Tokens map[string]string `json:"tokens"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if ruRef := decodeClaims(t, response.Tokens["runner"])["ru_ref"]; ruRef != "49900000001" {
+		t.Errorf("ru_ref = %v, want 49900000001", ruRef)
+	}
+}
+
+// postBatchTokens posts a batch to postBatchTokensHandler, returning the claims of each token made
+func postBatchTokens(t *testing.T, body string) []map[string]interface{} {
+	t.Helper()
+	request := httptest.NewRequest("POST", "/tokens/batch", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	postBatchTokensHandler(recorder, request)
+
+	if recorder.Code != 200 {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body.String())
+	}
+	var response struct {
+		Tokens []string          `json:"tokens"`
+		Errors map[string]string `json:"errors"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Errors) > 0 {
+		t.Fatalf("errors = %v, want none", response.Errors)
+	}
+
+	var claims []map[string]interface{}
+	for _, token := range response.Tokens {
+		claims = append(claims, decodeClaims(t, token))
+	}
+	return claims
+}
+
+func TestPostBatchTokensHandlerNumericValues(t *testing.T) {
+	useGeneratedEncryptionKey(t)
+
+	claims := postBatchTokens(t, `{"launches": [{"schema_url": "`+schemaServer(t)+`", "collection_exercise_sid": "789", "ru_ref": 49900000001}]}`)
+
+	if len(claims) != 1 {
+		t.Fatalf("got %d tokens, want 1", len(claims))
+	}
+	if ruRef := claims[0]["ru_ref"]; ruRef != "49900000001" {
+		t.Errorf("ru_ref = %v, want 49900000001", ruRef)
+	}
+}
+
+func TestPostBatchTokensHandlerNumericTemplate(t *testing.T) {
+	useGeneratedEncryptionKey(t)
+
+	claims := postBatchTokens(t, `{"template": {"schema_url": "`+schemaServer(t)+`", "collection_exercise_sid": "789", "response_id": 1234567890123456}, "count": 2}`)
+
+	if len(claims) != 2 {
+		t.Fatalf("got %d tokens, want 2", len(claims))
+	}
+	for i, tokenClaims := range claims {
+		if responseID := tokenClaims["response_id"]; responseID != "1234567890123456" {
+			t.Errorf("token %d response_id = %v, want 1234567890123456", i, responseID)
+		}
+	}
+}
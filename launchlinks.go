@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/authentication"
+	"github.com/ONSdigital/eq-questionnaire-launcher/links"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/gorilla/mux"
+)
+
+// launchLinkResponse is a created launch link
+type launchLinkResponse struct {
+	ID        string `json:"id"`
+	URL       string `json:"url"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// launchLinkExpiry reads link_expires_in, the seconds a link can be opened for, defaulting to
+// LAUNCH_LINK_EXPIRY_SECONDS and at most LAUNCH_LINK_MAX_EXPIRY_SECONDS
+func launchLinkExpiry(values url.Values) (time.Duration, error) {
+	maxSeconds, _ := strconv.Atoi(settings.Get("LAUNCH_LINK_MAX_EXPIRY_SECONDS"))
+
+	value := values.Get("link_expires_in")
+	if value == "" {
+		value = settings.Get("LAUNCH_LINK_EXPIRY_SECONDS")
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 || (maxSeconds > 0 && seconds > maxSeconds) {
+		return 0, fmt.Errorf("link_expires_in must be a number of seconds from 1 to %d: %s", maxSeconds, value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// createLaunchLink stores the launch values as a link, leaving out actions and link_expires_in
+func createLaunchLink(r *http.Request, values url.Values, expiry time.Duration) (launchLinkResponse, error) {
+	stored := url.Values{}
+	for field, fieldValues := range values {
+		if !strings.HasPrefix(field, "action_") && field != "link_expires_in" {
+			stored[field] = fieldValues
+		}
+	}
+
+	link, err := links.Create(stored, expiry)
+	if err != nil {
+		return launchLinkResponse{}, err
+	}
+
+	return launchLinkResponse{
+		ID:        link.ID,
+		URL:       getAccountServiceURL(r) + "/l/" + link.ID,
+		ExpiresAt: link.ExpiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// postLaunchLinkHandler creates a short link which launches with the posted values when opened, so that a
+// launch can be shared without its token
+func postLaunchLinkHandler(w http.ResponseWriter, r *http.Request) {
+	values, ok := readLaunchValues(w, r)
+	if !ok {
+		return
+	}
+
+	expiry, err := launchLinkExpiry(values)
+	if err != nil {
+		writeAPIError(w, 400, errorInvalidRequest, err.Error())
+		return
+	}
+	values.Del("link_expires_in")
+
+	if fields := append(authentication.ValidateLaunchValues(values), authentication.ValidateLaunchSchema(values)...); len(fields) > 0 {
+		writeAPIError(w, 400, errorInvalidLaunchValues, invalidLaunchValues, fields...)
+		return
+	}
+	if _, runnerErr := authentication.RunnerURLFromPost(values); runnerErr != "" {
+		writeAPIError(w, 400, errorInvalidRequest, runnerErr)
+		return
+	}
+
+	link, err := createLaunchLink(r, values, expiry)
+	if err != nil {
+		writeAPIError(w, 500, errorInternal, fmt.Sprintf("Create launch link err: %v", err))
+		return
+	}
+	recordAudit(r, values.Get("schema_name"), "")
+
+	writeJSON(w, 201, link)
+}
+
+// getLaunchLinkHandler opens a launch link, redirecting to the runner with a token made for this opening
+func getLaunchLinkHandler(w http.ResponseWriter, r *http.Request) {
+	link, ok := links.Get(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "Launch link not found or expired", 404)
+		return
+	}
+
+	values := url.Values{}
+	for field, fieldValues := range link.Values {
+		values[field] = append([]string(nil), fieldValues...)
+	}
+	accountServiceURL, accountServiceLogOutURL := accountServiceURLs(r, values)
+	values.Set("account_service_url", accountServiceURL)
+	values.Set("account_service_log_out_url", accountServiceLogOutURL)
+
+	token, claims, err := authentication.GenerateTokenAndClaimsFromPostWithContext(r.Context(), values)
+	txID, _ := claims["tx_id"].(string)
+	if err != "" {
+		recordLaunch(values, txID, "failed: "+err, false, claims)
+		http.Error(w, err, 500)
+		return
+	}
+	recordAudit(r, values.Get("schema_name"), txID)
+
+	runnerURL, runnerErr := authentication.RunnerURLFromPost(values)
+	if runnerErr != "" {
+		http.Error(w, runnerErr, 400)
+		return
+	}
+
+	sessionURL, buildErr := buildRunnerURL(runnerURL, "/session", token)
+	if buildErr != nil {
+		http.Error(w, buildErr.Error(), 400)
+		return
+	}
+
+	recordLaunch(values, txID, "launch link redirected to runner", true, claims)
+	// Every opening has its own token, so the redirect must not be cached
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, sessionURL, 302)
+}
+
+// createFormLaunchLink creates a launch link from the launch form's values, with the default expiry
+func createFormLaunchLink(w http.ResponseWriter, r *http.Request) {
+	if fields := append(authentication.ValidateLaunchValues(r.PostForm), authentication.ValidateLaunchSchema(r.PostForm)...); len(fields) > 0 {
+		writeFieldErrors(w, fields)
+		return
+	}
+
+	expiry, err := launchLinkExpiry(r.PostForm)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	link, err := createLaunchLink(r, r.PostForm, expiry)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Create launch link err: %v", err), 500)
+		return
+	}
+
+	writeJSON(w, 201, link)
+}
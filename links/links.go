@@ -0,0 +1,178 @@
+package links
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/ONSdigital/eq-questionnaire-launcher/storage"
+)
+
+// Link is a stored launch, opened by its ID until it expires. It holds the launch values rather than a token,
+// so that every opening gets a fresh token which the runner has not seen.
+type Link struct {
+	ID        string     `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	Values    url.Values `json:"values"`
+}
+
+// linkKeyPrefix starts the state backend key of each link, which is kept until it expires
+const linkKeyPrefix = "link:"
+
+var (
+	// linksMutex serialises writes to LAUNCH_LINKS_PATH so that concurrent links don't lose each other
+	linksMutex sync.Mutex
+	loadOnce   sync.Once
+)
+
+// Create stores the launch values as a link which can be opened until expiry has passed
+func Create(values url.Values, expiry time.Duration) (Link, error) {
+	id, err := newID()
+	if err != nil {
+		return Link{}, err
+	}
+
+	stored := url.Values{}
+	for field, fieldValues := range values {
+		stored[field] = append([]string(nil), fieldValues...)
+	}
+
+	now := time.Now().UTC()
+	link := Link{ID: id, CreatedAt: now, ExpiresAt: now.Add(expiry), Values: stored}
+
+	linksMutex.Lock()
+	defer linksMutex.Unlock()
+	loadOnce.Do(loadLinks)
+
+	if err := putLink(link); err != nil {
+		return Link{}, err
+	}
+	if err := writeLinks(); err != nil {
+		logging.Warn("Failed to write launch links", "path", settings.Get("LAUNCH_LINKS_PATH"), "err", err)
+	}
+	return link, nil
+}
+
+// Get returns the link with the given ID, unless it has expired
+func Get(id string) (Link, bool) {
+	linksMutex.Lock()
+	loadOnce.Do(loadLinks)
+	linksMutex.Unlock()
+
+	data, ok, err := storage.Default().Get(linkKeyPrefix + id)
+	if err != nil {
+		logging.Warn("Failed to read launch link", "err", err)
+		return Link{}, false
+	}
+
+	var link Link
+	if !ok || json.Unmarshal(data, &link) != nil || time.Now().After(link.ExpiresAt) {
+		return Link{}, false
+	}
+	return link, true
+}
+
+// putLink stores a link in the state backend until it expires
+func putLink(link Link) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+	return storage.Default().Put(linkKeyPrefix+link.ID, data, time.Until(link.ExpiresAt))
+}
+
+// newID is 128 random bits, so that links cannot be guessed
+func newID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(id), nil
+}
+
+// loadLinks reads the links kept at LAUNCH_LINKS_PATH on first use, so that they survive a restart, and drops
+// any which have expired. A shared state backend keeps the links itself, so the file is not used.
+func loadLinks() {
+	path := settings.Get("LAUNCH_LINKS_PATH")
+	if path == "" || storage.Shared() {
+		return
+	}
+
+	links := make(map[string]Link)
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &links)
+	}
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Warn("Failed to read launch links", "path", path, "err", err)
+		}
+		return
+	}
+
+	now := time.Now()
+	for _, link := range links {
+		if now.After(link.ExpiresAt) {
+			continue
+		}
+		if err := putLink(link); err != nil {
+			logging.Warn("Failed to load launch link", "path", path, "err", err)
+		}
+	}
+}
+
+// writeLinks replaces the links file via a rename, so a failed write never leaves it half written
+func writeLinks() error {
+	path := settings.Get("LAUNCH_LINKS_PATH")
+	if path == "" || storage.Shared() {
+		return nil
+	}
+
+	backend := storage.Default()
+	keys, err := backend.Keys(linkKeyPrefix)
+	if err != nil {
+		return err
+	}
+
+	links := make(map[string]Link)
+	for _, key := range keys {
+		data, ok, err := backend.Get(key)
+		if err != nil {
+			return err
+		}
+		var link Link
+		if ok && json.Unmarshal(data, &link) == nil {
+			links[link.ID] = link
+		}
+	}
+
+	data, err := json.MarshalIndent(links, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
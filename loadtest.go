@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/authentication"
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+)
+
+const loadTestUsage = `Usage: eq-questionnaire-launcher loadtest [--help] [--count N] [--concurrency N] [--rate N] [--schema NAME|URL] [--<claim>=<value> ...]
+
+Launches --count sessions (default 10) against the runner at SURVEY_RUNNER_URL, or that of --environment,
+using at most --concurrency requests at once (default 1) and starting at most --rate sessions per second
+(default unlimited).
+Every session gets its own user_id, ru_ref and tx_id; other claim values are as for the token command.
+A session succeeds when the runner's /session responds without an error status; redirects are not followed.
+Success and error counts and latencies are printed when the run completes. The exit code is 1 when any
+session fails and 2 for invalid arguments.
+`
+
+// loadTestResult is the outcome of a single session launch
+type loadTestResult struct {
+	status  int
+	err     error
+	latency time.Duration
+}
+
+// loadTestOption reads the named integer option, which must be at least min
+func loadTestOption(options map[string]string, name string, defaultValue int, min int) (int, error) {
+	value, ok := options[name]
+	if !ok {
+		return defaultValue, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < min {
+		return 0, fmt.Errorf("--%s must be a number of at least %d: %s", name, min, value)
+	}
+	return number, nil
+}
+
+// loadTestCommand runs the loadtest subcommand, returning the process exit code
+func loadTestCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	for _, arg := range args {
+		if arg == "--help" || arg == "-h" {
+			fmt.Fprint(stdout, loadTestUsage)
+			return 0
+		}
+	}
+
+	options := make(map[string]string)
+	setOptions := make(map[string]func(string))
+	for _, name := range []string{"count", "concurrency", "rate"} {
+		name := name
+		setOptions[name] = func(value string) { options[name] = value }
+	}
+
+	values, err := parseCommandArgs(args, setOptions)
+	var count, concurrency, rate int
+	if err == nil {
+		count, err = loadTestOption(options, "count", 10, 1)
+	}
+	if err == nil {
+		concurrency, err = loadTestOption(options, "concurrency", 1, 1)
+	}
+	if err == nil {
+		rate, err = loadTestOption(options, "rate", 0, 0)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		fmt.Fprint(stderr, loadTestUsage)
+		return 2
+	}
+
+	tokens, _, failures, tokenErr := authentication.GenerateTokensFromPosts(authentication.ExpandBatchTemplate(values, count))
+	if tokenErr != "" {
+		fmt.Fprintln(stderr, tokenErr)
+		return 1
+	}
+	for i := 0; i < count; i++ {
+		if failure, ok := failures[i]; ok {
+			fmt.Fprintln(stderr, failure)
+			return 1
+		}
+	}
+
+	runnerURL, runnerErr := authentication.RunnerURLFromPost(values)
+	if runnerErr != "" {
+		fmt.Fprintln(stderr, runnerErr)
+		return 1
+	}
+
+	results := runLoadTest(runnerURL, tokens, concurrency, rate)
+	reportLoadTest(stdout, results)
+
+	for _, result := range results {
+		if result.err != nil || result.status >= 400 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// runLoadTest launches a session on the runner with each token, with at most concurrency in flight and rate started per second
+func runLoadTest(runnerURL string, tokens []string, concurrency int, rate int) []loadTestResult {
+	client := *clients.GetHTTPClient()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	var ticker *time.Ticker
+	if rate > 0 {
+		ticker = time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+	}
+
+	results := make([]loadTestResult, len(tokens))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, token := range tokens {
+		if ticker != nil && i > 0 {
+			<-ticker.C
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, token string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			results[i] = launchSession(&client, runnerURL, token)
+		}(i, token)
+	}
+
+	wg.Wait()
+	return results
+}
+
+func launchSession(client *http.Client, runnerURL string, token string) loadTestResult {
+	sessionURL, err := buildRunnerURL(runnerURL, "/session", token)
+	if err != nil {
+		return loadTestResult{err: err}
+	}
+
+	start := time.Now()
+	resp, err := client.Get(sessionURL)
+	if err != nil {
+		return loadTestResult{err: err, latency: time.Since(start)}
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	return loadTestResult{status: resp.StatusCode, latency: time.Since(start)}
+}
+
+// reportLoadTest prints the counts of each outcome and the latency distribution of the completed requests
+func reportLoadTest(w io.Writer, results []loadTestResult) {
+	outcomes := make(map[string]int)
+	var latencies []time.Duration
+	var total time.Duration
+
+	for _, result := range results {
+		if result.err != nil {
+			outcomes["error"]++
+			continue
+		}
+		outcomes[strconv.Itoa(result.status)]++
+		latencies = append(latencies, result.latency)
+		total += result.latency
+	}
+
+	names := make([]string, 0, len(outcomes))
+	for name := range outcomes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "sessions: %d\n", len(results))
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s: %d\n", name, outcomes[name])
+	}
+
+	if len(latencies) == 0 {
+		return
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p int) time.Duration {
+		return latencies[(len(latencies)-1)*p/100]
+	}
+
+	fmt.Fprintf(w, "latency: min %v, mean %v, p50 %v, p95 %v, p99 %v, max %v\n",
+		latencies[0], total/time.Duration(len(latencies)), percentile(50), percentile(95), percentile(99), latencies[len(latencies)-1])
+}
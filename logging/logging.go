@@ -0,0 +1,197 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+// Levels in increasing order of severity
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel returns the Level with the given name, and false when the name is not a level
+func ParseLevel(name string) (Level, bool) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, true
+		}
+	}
+	return InfoLevel, false
+}
+
+// Logger writes a structured log entry. keyvals are alternating keys and values.
+type Logger interface {
+	Log(level Level, msg string, keyvals ...interface{})
+}
+
+type stdLogger struct{}
+
+// Log writes the entry as key=value pairs through the standard library logger
+func (stdLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	var entry strings.Builder
+	fmt.Fprintf(&entry, "level=%s msg=%q", level, msg)
+
+	for i := 0; i < len(keyvals); i += 2 {
+		var value interface{} = "(missing)"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		fmt.Fprintf(&entry, " %v=%s", keyvals[i], formatValue(value))
+	}
+
+	log.Print(entry.String())
+}
+
+type jsonLogger struct{}
+
+// Log writes the entry as a single line JSON object, for log pipelines which parse JSON
+func (jsonLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	entry := map[string]interface{}{
+		"time":  time.Now().UTC().Format(time.RFC3339Nano),
+		"level": level.String(),
+		"msg":   msg,
+	}
+
+	for i := 0; i < len(keyvals); i += 2 {
+		var value interface{} = "(missing)"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		entry[fmt.Sprint(keyvals[i])] = value
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]string{"level": level.String(), "msg": msg, "log_error": err.Error()})
+	}
+
+	fmt.Fprintln(os.Stderr, string(line))
+}
+
+func formatValue(value interface{}) string {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+
+	formatted := fmt.Sprintf("%v", value)
+	if formatted == "" || strings.ContainsAny(formatted, " \t\n\"=") {
+		return fmt.Sprintf("%q", formatted)
+	}
+	return formatted
+}
+
+var (
+	logger    Logger = stdLogger{}
+	minLevel         = InfoLevel
+	sensitive        = false
+	mutex     sync.RWMutex
+)
+
+func init() {
+	if settings.Get("LOG_FORMAT") == "json" {
+		logger = jsonLogger{}
+	}
+
+	sensitive = settings.Get("LOG_SENSITIVE") == "true"
+
+	if level, ok := ParseLevel(settings.Get("LOG_LEVEL")); ok {
+		minLevel = level
+	} else {
+		log.Printf("Unknown LOG_LEVEL %q, using %s", settings.Get("LOG_LEVEL"), minLevel)
+	}
+}
+
+// SetLogger replaces the Logger that entries are written to
+func SetLogger(l Logger) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	logger = l
+}
+
+// SetLevel sets the least severe level which is written
+func SetLevel(level Level) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	minLevel = level
+}
+
+func write(level Level, msg string, keyvals []interface{}) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if level >= minLevel {
+		logger.Log(level, msg, keyvals...)
+	}
+}
+
+// Debug logs at debug level
+func Debug(msg string, keyvals ...interface{}) {
+	write(DebugLevel, msg, keyvals)
+}
+
+// Sensitive logs at debug level, but only when LOG_SENSITIVE is true. It is the only way to log
+// claims, form values or anything else that may identify a respondent.
+func Sensitive(msg string, keyvals ...interface{}) {
+	if sensitive {
+		write(DebugLevel, msg, keyvals)
+	}
+}
+
+// Info logs at info level
+func Info(msg string, keyvals ...interface{}) {
+	write(InfoLevel, msg, keyvals)
+}
+
+// Warn logs at warn level
+func Warn(msg string, keyvals ...interface{}) {
+	write(WarnLevel, msg, keyvals)
+}
+
+// Error logs at error level
+func Error(msg string, keyvals ...interface{}) {
+	write(ErrorLevel, msg, keyvals)
+}
+
+const redactedTokenPrefixLength = 10
+
+// RedactToken shortens a token to a prefix which identifies it in logs without making it usable.
+// The whole token is kept when LOG_SENSITIVE is true.
+func RedactToken(token string) string {
+	if sensitive {
+		return token
+	}
+	if len(token) <= redactedTokenPrefixLength {
+		return "[redacted]"
+	}
+	return token[:redactedTokenPrefixLength] + "...[redacted]"
+}
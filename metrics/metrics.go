@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Stages at which token generation can fail, used as the stage label of the failures counter
+const (
+	StageKeyLoad    = "key_load"
+	StageSign       = "sign"
+	StageEncrypt    = "encrypt"
+	StageValidation = "validation"
+	StageOther      = "other"
+)
+
+var (
+	tokensGenerated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "launcher_tokens_generated_total",
+		Help: "Total number of tokens generated.",
+	})
+
+	tokenFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "launcher_token_failures_total",
+		Help: "Total number of failed token generations, by the stage that failed.",
+	}, []string{"stage"})
+
+	tokenGenerationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "launcher_token_generation_seconds",
+		Help:    "Time taken to turn a set of launch values into a token.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	schemaListFetchSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "launcher_schema_list_fetch_seconds",
+		Help:    "Time taken to fetch the schema list from the runner, by whether the fetch succeeded.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	httpRequestSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "launcher_http_request_seconds",
+		Help:    "Time taken to handle HTTP requests, by route, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	tokenPoolSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "launcher_token_pool_size",
+		Help: "Number of pregenerated tokens waiting in the token pool.",
+	})
+
+	tokenPoolRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "launcher_token_pool_requests_total",
+		Help: "Total number of tokens taken from the token pool, by whether one was waiting (hit) or had to be generated (miss).",
+	}, []string{"outcome"})
+
+	tokenPoolDiscarded = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "launcher_token_pool_discarded_total",
+		Help: "Total number of pooled tokens discarded for being too close to expiry or on reload.",
+	})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "launcher_webhook_deliveries_total",
+		Help: "Total number of launch webhooks, by outcome: success, failure, or dropped when the queue was full.",
+	}, []string{"outcome"})
+)
+
+func init() {
+	prometheus.MustRegister(tokensGenerated, tokenFailures, tokenGenerationSeconds, schemaListFetchSeconds, httpRequestSeconds,
+		tokenPoolSize, tokenPoolRequests, tokenPoolDiscarded, webhookDeliveries)
+
+	for _, stage := range []string{StageKeyLoad, StageSign, StageEncrypt, StageValidation, StageOther} {
+		tokenFailures.WithLabelValues(stage)
+	}
+}
+
+// TokenGenerated counts a successfully generated token
+func TokenGenerated() {
+	tokensGenerated.Inc()
+}
+
+// TokenFailed counts a failed token generation at the given stage
+func TokenFailed(stage string) {
+	if stage == "" {
+		stage = StageOther
+	}
+	tokenFailures.WithLabelValues(stage).Inc()
+}
+
+// ObserveTokenGeneration records the time since start as a token generation latency
+func ObserveTokenGeneration(start time.Time) {
+	tokenGenerationSeconds.Observe(time.Since(start).Seconds())
+}
+
+// ObserveSchemaListFetch records the time since start as a schema list fetch from the runner
+func ObserveSchemaListFetch(start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	schemaListFetchSeconds.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
+
+// ObserveHTTPRequest records the time since start as the duration of a request to the route
+func ObserveHTTPRequest(route string, method string, code int, start time.Time) {
+	httpRequestSeconds.WithLabelValues(route, method, strconv.Itoa(code)).Observe(time.Since(start).Seconds())
+}
+
+// SetTokenPoolSize records the number of tokens waiting in the token pool
+func SetTokenPoolSize(size int) {
+	tokenPoolSize.Set(float64(size))
+}
+
+// TokenPoolTaken counts a token taken from the pool, or generated because the pool was empty
+func TokenPoolTaken(hit bool) {
+	outcome := "miss"
+	if hit {
+		outcome = "hit"
+	}
+	tokenPoolRequests.WithLabelValues(outcome).Inc()
+}
+
+// TokenPoolDiscarded counts a pooled token thrown away unused
+func TokenPoolDiscarded() {
+	tokenPoolDiscarded.Inc()
+}
+
+// WebhookDelivered counts a launch webhook by outcome
+func WebhookDelivered(outcome string) {
+	webhookDeliveries.WithLabelValues(outcome).Inc()
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
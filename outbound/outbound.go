@@ -0,0 +1,52 @@
+package outbound
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// Transport builds the transport for outbound requests, which goes through the HTTP_PROXY/HTTPS_PROXY proxies
+// and trusts the CA_BUNDLE_PATH certificates as well as the system ones
+func Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFromSettings
+
+	if caBundlePath := settings.Get("CA_BUNDLE_PATH"); caBundlePath != "" {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+
+		caBundle, err := ioutil.ReadFile(caBundlePath)
+		if err != nil {
+			log.Fatal("Failed to read CA bundle from file: ", caBundlePath)
+		}
+		if !rootCAs.AppendCertsFromPEM(caBundle) {
+			log.Fatal("No certificates found in CA bundle: ", caBundlePath)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	}
+
+	return transport
+}
+
+// proxyFromSettings picks the proxy for a request from the HTTP_PROXY/HTTPS_PROXY settings
+func proxyFromSettings(r *http.Request) (*url.URL, error) {
+	proxy := settings.Get("HTTP_PROXY")
+	if r.URL.Scheme == "https" {
+		proxy = settings.Get("HTTPS_PROXY")
+	}
+
+	if proxy == "" {
+		return nil, nil
+	}
+
+	return url.Parse(proxy)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/authentication"
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/reload"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// overridableSetting is a setting which is safe to change while the launcher runs. check reports why a new value
+// is invalid, apply puts a value into effect where the setting is only read at startup, and reloads names the
+// reloaders of anything cached from the old value.
+type overridableSetting struct {
+	check   func(value string) string
+	apply   func(value string)
+	reloads []string
+}
+
+var overridableSettings = map[string]overridableSetting{
+	"SURVEY_RUNNER_URL":        {check: checkOverrideURL},
+	"SURVEY_RUNNER_SCHEMA_URL": {check: checkOverrideURL, reloads: []string{"schema_list"}},
+	"CLAIM_DEFAULTS":           {check: authentication.CheckClaimDefaults, reloads: []string{"token_pool"}},
+	"LOG_LEVEL":                {check: checkOverrideLogLevel, apply: applyLogLevel},
+}
+
+// adminSetting is a setting as shown by /admin/settings
+type adminSetting struct {
+	Value       string `json:"value"`
+	Source      string `json:"source"`
+	Secret      bool   `json:"secret,omitempty"`
+	Overridable bool   `json:"overridable"`
+}
+
+func checkOverrideURL(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "must be an http or https URL"
+	}
+	return ""
+}
+
+func checkOverrideLogLevel(value string) string {
+	if _, ok := logging.ParseLevel(value); !ok {
+		return "must be one of debug, info, warn or error"
+	}
+	return ""
+}
+
+// applyLogLevel sets the level logged at, falling back to info as at startup for an unknown configured level
+func applyLogLevel(value string) {
+	level, _ := logging.ParseLevel(value)
+	logging.SetLevel(level)
+}
+
+func writeAdminSettings(w http.ResponseWriter) {
+	effective := make(map[string]adminSetting)
+	for name, setting := range settings.Effective() {
+		_, overridable := overridableSettings[name]
+		effective[name] = adminSetting{Value: setting.Value, Source: setting.Source, Secret: setting.Secret, Overridable: overridable}
+	}
+	writeJSON(w, 200, map[string]interface{}{"settings": effective})
+}
+
+// getAdminSettingsHandler shows the effective value of every setting, with secrets masked, and where it came from
+func getAdminSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	writeAdminSettings(w)
+}
+
+// patchAdminSettingsHandler overrides settings for the life of the process from a JSON object of setting name to
+// value, where null returns a setting to its configured value. Every value is checked before any is changed.
+func patchAdminSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if isRequestTooLarge(err) {
+		writeAPIError(w, 413, errorRequestTooLarge, http.StatusText(413))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, 500, errorInternal, fmt.Sprintf("Error reading body: %v", err))
+		return
+	}
+
+	var changes map[string]*string
+	if err := json.Unmarshal(body, &changes); err != nil {
+		writeAPIError(w, 400, errorInvalidRequest, "Body must be a JSON object of setting name to a string value or null")
+		return
+	}
+
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fieldErrors []authentication.FieldError
+	for _, name := range names {
+		setting, ok := overridableSettings[name]
+		if !ok {
+			fieldErrors = append(fieldErrors, authentication.FieldError{Field: name, Error: "cannot be changed at runtime"})
+			continue
+		}
+		if value := changes[name]; value != nil {
+			if checkErr := setting.check(*value); checkErr != "" {
+				fieldErrors = append(fieldErrors, authentication.FieldError{Field: name, Error: checkErr})
+			}
+		}
+	}
+	if len(fieldErrors) > 0 {
+		writeAPIError(w, 400, errorInvalidRequest, "Invalid settings", fieldErrors...)
+		return
+	}
+
+	var reloads []string
+	for _, name := range names {
+		setting := overridableSettings[name]
+		if value := changes[name]; value != nil {
+			if err := settings.Override(name, *value); err != nil {
+				writeAPIError(w, 500, errorInternal, fmt.Sprintf("Override failed err: %v", err))
+				return
+			}
+			logging.Info("Setting overridden", "name", name, "value", *value)
+		} else {
+			settings.ClearOverride(name)
+			logging.Info("Setting override cleared", "name", name, "value", settings.Get(name))
+		}
+
+		if setting.apply != nil {
+			setting.apply(settings.Get(name))
+		}
+		reloads = append(reloads, setting.reloads...)
+	}
+	logReloadResult(reload.Named(reloads...))
+
+	writeAdminSettings(w)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/profiles"
+	"github.com/gorilla/mux"
+)
+
+func getProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	names, err := profiles.ListProfiles()
+	if err == profiles.ErrProfilesDisabled {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("ListProfiles err: %v", err), 500)
+		return
+	}
+
+	writeJSON(w, 200, names)
+}
+
+func getProfileHandler(w http.ResponseWriter, r *http.Request) {
+	values, err := profiles.LoadProfile(mux.Vars(r)["name"])
+	if err == profiles.ErrProfilesDisabled || err == profiles.ErrProfileNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("LoadProfile err: %v", err), 500)
+		return
+	}
+
+	writeJSON(w, 200, values)
+}
+
+func postProfileHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if isRequestTooLarge(err) {
+		http.Error(w, http.StatusText(413), 413)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("POST. r.ParseForm() err: %v", err), 500)
+		return
+	}
+
+	values := r.PostForm
+	for field := range values {
+		if strings.HasPrefix(field, "action_") {
+			values.Del(field)
+		}
+	}
+
+	err = profiles.SaveProfile(mux.Vars(r)["name"], values)
+	if err == profiles.ErrProfilesDisabled {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("SaveProfile err: %v", err), 500)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func deleteProfileHandler(w http.ResponseWriter, r *http.Request) {
+	err := profiles.DeleteProfile(mux.Vars(r)["name"])
+	if err == profiles.ErrProfilesDisabled || err == profiles.ErrProfileNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DeleteProfile err: %v", err), 500)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// postProfileLaunchHandler launches a saved profile, with any posted values replacing the profile's own
+func postProfileLaunchHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if isRequestTooLarge(err) {
+		http.Error(w, http.StatusText(413), 413)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("POST. r.ParseForm() err: %v", err), 500)
+		return
+	}
+
+	values, err := profiles.LoadProfile(mux.Vars(r)["name"])
+	if err == profiles.ErrProfilesDisabled || err == profiles.ErrProfileNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("LoadProfile err: %v", err), 500)
+		return
+	}
+
+	hasAction := false
+	for field, fieldValues := range r.PostForm {
+		values[field] = fieldValues
+		hasAction = hasAction || strings.HasPrefix(field, "action_")
+	}
+	if !hasAction {
+		values.Set("action_launch", "true")
+	}
+
+	r.PostForm = values
+	redirectURL(w, r)
+}
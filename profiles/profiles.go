@@ -0,0 +1,194 @@
+package profiles
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/ONSdigital/eq-questionnaire-launcher/storage"
+)
+
+// ErrProfilesDisabled is returned when PROFILES_PATH is not set and the state is kept in memory
+var ErrProfilesDisabled = errors.New("launch profiles are disabled, PROFILES_PATH is not set")
+
+// profileKeyPrefix starts the state backend key of each profile, when a shared state backend keeps them
+const profileKeyPrefix = "profile:"
+
+// ErrProfileNotFound is returned when loading a profile which has not been saved
+var ErrProfileNotFound = errors.New("launch profile not found")
+
+// profilesMutex serialises access to the profiles file so that concurrent saves don't lose each other's changes
+var profilesMutex sync.Mutex
+
+// SaveProfile stores the launch values under the given name, replacing any profile of that name. Profiles are kept
+// in the PROFILES_PATH file, or in the state backend when it is shared.
+func SaveProfile(name string, values url.Values) error {
+	if name == "" {
+		return errors.New("a launch profile must have a name")
+	}
+
+	if storage.Shared() {
+		data, err := json.Marshal(values)
+		if err != nil {
+			return err
+		}
+		return storage.Default().Put(profileKeyPrefix+name, data, 0)
+	}
+
+	profilesMutex.Lock()
+	defer profilesMutex.Unlock()
+
+	profiles, err := readProfiles()
+	if err != nil {
+		return err
+	}
+
+	profiles[name] = values
+
+	return writeProfiles(profiles)
+}
+
+// LoadProfile returns the launch values saved under the given name
+func LoadProfile(name string) (url.Values, error) {
+	if storage.Shared() {
+		data, ok, err := storage.Default().Get(profileKeyPrefix + name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrProfileNotFound
+		}
+		values := url.Values{}
+		return values, json.Unmarshal(data, &values)
+	}
+
+	profilesMutex.Lock()
+	defer profilesMutex.Unlock()
+
+	profiles, err := readProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := profiles[name]
+	if !ok {
+		return nil, ErrProfileNotFound
+	}
+
+	return values, nil
+}
+
+// DeleteProfile removes the profile saved under the given name
+func DeleteProfile(name string) error {
+	if storage.Shared() {
+		deleted, err := storage.Default().Delete(profileKeyPrefix + name)
+		if err == nil && !deleted {
+			err = ErrProfileNotFound
+		}
+		return err
+	}
+
+	profilesMutex.Lock()
+	defer profilesMutex.Unlock()
+
+	profiles, err := readProfiles()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := profiles[name]; !ok {
+		return ErrProfileNotFound
+	}
+	delete(profiles, name)
+
+	return writeProfiles(profiles)
+}
+
+// ListProfiles returns the sorted names of the saved profiles
+func ListProfiles() ([]string, error) {
+	if storage.Shared() {
+		keys, err := storage.Default().Keys(profileKeyPrefix)
+		if err != nil {
+			return nil, err
+		}
+		names := []string{}
+		for _, key := range keys {
+			names = append(names, strings.TrimPrefix(key, profileKeyPrefix))
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+
+	profilesMutex.Lock()
+	defer profilesMutex.Unlock()
+
+	profiles, err := readProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// readProfiles reads the profiles file, which is treated as empty until the first profile is saved
+func readProfiles() (map[string]url.Values, error) {
+	path := settings.Get("PROFILES_PATH")
+	if path == "" {
+		return nil, ErrProfilesDisabled
+	}
+
+	profiles := make(map[string]url.Values)
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// writeProfiles replaces the profiles file via a rename, so a failed write never leaves it half written
+func writeProfiles(profiles map[string]url.Values) error {
+	path := settings.Get("PROFILES_PATH")
+
+	data, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// tokenBucket holds the requests a client may still make, refilled continuously up to the burst size
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+var (
+	rateLimitBuckets = map[string]*tokenBucket{}
+	rateLimitMutex   sync.Mutex
+)
+
+// maxRateLimitClients bounds the buckets kept; when it is reached the buckets which have refilled are dropped
+const maxRateLimitClients = 10000
+
+// rateLimitClient identifies the client of a request: the name of the API key it was made with, otherwise the
+// client IP. A header the client chooses freely is never used, as a new value would give it a new bucket.
+func rateLimitClient(r *http.Request) string {
+	if name := apiKeyName(r.Context()); name != "" {
+		return "api_key:" + name
+	}
+	return "ip:" + clientIP(r)
+}
+
+// clientIP is the IP address of the client of a request, taken from X-Forwarded-For when
+// RATE_LIMIT_TRUST_FORWARDED_FOR is true
+func clientIP(r *http.Request) string {
+	if settings.Get("RATE_LIMIT_TRUST_FORWARDED_FOR") == "true" {
+		if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+			return strings.TrimSpace(strings.Split(forwardedFor, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host
+}
+
+// takeRateLimitToken takes a request from the client's bucket, returning how long the client must wait
+// when the bucket is empty
+func takeRateLimitToken(client string, perMinute int, burst int, now time.Time) (bool, time.Duration) {
+	rate := float64(perMinute) / 60
+
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+
+	bucket, ok := rateLimitBuckets[client]
+	if !ok {
+		if len(rateLimitBuckets) >= maxRateLimitClients {
+			for name, other := range rateLimitBuckets {
+				if other.tokens+now.Sub(other.updated).Seconds()*rate >= float64(burst) {
+					delete(rateLimitBuckets, name)
+				}
+			}
+		}
+		bucket = &tokenBucket{tokens: float64(burst), updated: now}
+		rateLimitBuckets[client] = bucket
+	}
+
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*rate)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// rateLimit limits each client to RATE_LIMIT_PER_MINUTE requests to the token endpoints, with bursts of up
+// to RATE_LIMIT_BURST, so that one runaway load test cannot starve a shared launcher. Clients over the
+// limit get a 429 with a Retry-After header. It does nothing when RATE_LIMIT_PER_MINUTE is unset or zero.
+func rateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		perMinute, err := strconv.Atoi(settings.Get("RATE_LIMIT_PER_MINUTE"))
+		if err != nil || perMinute <= 0 {
+			next(w, r)
+			return
+		}
+
+		burst, err := strconv.Atoi(settings.Get("RATE_LIMIT_BURST"))
+		if err != nil || burst <= 0 {
+			burst = perMinute
+		}
+
+		client := rateLimitClient(r)
+		if allowed, wait := takeRateLimitToken(client, perMinute, burst, time.Now()); !allowed {
+			logging.Warn("Rate limit exceeded", "client", client, "path", r.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeAPIError(w, 429, errorRateLimited, "Rate limit exceeded, retry after "+w.Header().Get("Retry-After")+" seconds")
+			return
+		}
+
+		next(w, r)
+	}
+}
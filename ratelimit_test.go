@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestRateLimitIgnoresClientChosenHeaders(t *testing.T) {
+	useSetting(t, "RATE_LIMIT_PER_MINUTE", "2")
+	rateLimitBuckets = map[string]*tokenBucket{}
+	t.Cleanup(func() { rateLimitBuckets = map[string]*tokenBucket{} })
+
+	handler := rateLimit(func(w http.ResponseWriter, r *http.Request) {})
+
+	statuses := []int{}
+	for i := 0; i < 3; i++ {
+		request := httptest.NewRequest("POST", "/tokens", nil)
+		request.RemoteAddr = "192.0.2.1:1234"
+		request.Header.Set("X-API-Key", "key-"+strconv.Itoa(i))
+		recorder := httptest.NewRecorder()
+
+		handler(recorder, request)
+		statuses = append(statuses, recorder.Code)
+	}
+
+	if statuses[0] != 200 || statuses[1] != 200 || statuses[2] != 429 {
+		t.Errorf("statuses = %v, want [200 200 429] from one IP whatever header it sends", statuses)
+	}
+}
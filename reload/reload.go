@@ -0,0 +1,101 @@
+package reload
+
+import (
+	"sort"
+	"sync"
+)
+
+// Reloader re-reads a piece of on-disk configuration. It must leave the currently loaded
+// configuration in place when the new configuration cannot be read or parsed.
+type Reloader func() error
+
+var (
+	reloaders      = make(map[string]Reloader)
+	watchedFiles   = make(map[string][]string)
+	reloadersMutex sync.Mutex
+)
+
+// Register adds a named Reloader to be run by All, along with any files it reads
+func Register(name string, reloader Reloader, files ...string) {
+	reloadersMutex.Lock()
+	defer reloadersMutex.Unlock()
+
+	reloaders[name] = reloader
+
+	watchedFiles[name] = nil
+	for _, file := range files {
+		if file != "" {
+			watchedFiles[name] = append(watchedFiles[name], file)
+		}
+	}
+}
+
+// Unregister removes a named Reloader and its files, so that it is no longer run by All
+func Unregister(name string) {
+	reloadersMutex.Lock()
+	defer reloadersMutex.Unlock()
+
+	delete(reloaders, name)
+	delete(watchedFiles, name)
+}
+
+// Files returns every file read by the registered Reloaders
+func Files() []string {
+	reloadersMutex.Lock()
+	defer reloadersMutex.Unlock()
+
+	var files []string
+	for _, reloaderFiles := range watchedFiles {
+		files = append(files, reloaderFiles...)
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+// Result is the outcome of running every registered Reloader
+type Result struct {
+	Reloaded []string          `json:"reloaded"`
+	Errors   map[string]string `json:"errors"`
+}
+
+// All runs every registered Reloader, collecting the names which succeeded and the errors of those which failed
+func All() Result {
+	reloadersMutex.Lock()
+	defer reloadersMutex.Unlock()
+
+	names := make([]string, 0, len(reloaders))
+	for name := range reloaders {
+		names = append(names, name)
+	}
+	return run(names)
+}
+
+// Named runs the named Reloaders only, for configuration which has changed other than on disk. Names which
+// are not registered are ignored.
+func Named(names ...string) Result {
+	reloadersMutex.Lock()
+	defer reloadersMutex.Unlock()
+
+	return run(names)
+}
+
+func run(names []string) Result {
+	result := Result{Reloaded: []string{}, Errors: make(map[string]string)}
+
+	for _, name := range names {
+		reloader, ok := reloaders[name]
+		if !ok {
+			continue
+		}
+		if err := reloader(); err != nil {
+			result.Errors[name] = err.Error()
+		} else {
+			result.Reloaded = append(result.Reloaded, name)
+		}
+	}
+
+	sort.Strings(result.Reloaded)
+
+	return result
+}
@@ -0,0 +1,123 @@
+package reload
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fileConfig is configuration read from a file, as the launcher's Reloaders read theirs
+type fileConfig struct {
+	path  string
+	value string
+}
+
+// reload reads the file, keeping the current value when it cannot be read or is empty
+func (c *fileConfig) reload() error {
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return errors.New("empty configuration")
+	}
+	c.value = value
+	return nil
+}
+
+func writeConfig(t *testing.T, path string, value string) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, []byte(value), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNamedReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	writeConfig(t, path, "first")
+	config := &fileConfig{path: path}
+	Register("test-config", config.reload, path)
+	t.Cleanup(func() { Unregister("test-config") })
+
+	writeConfig(t, path, "second")
+	result := Named("test-config")
+	if !reflect.DeepEqual(result.Reloaded, []string{"test-config"}) || len(result.Errors) != 0 {
+		t.Fatalf("Named() = %+v, want test-config reloaded", result)
+	}
+	if config.value != "second" {
+		t.Errorf("value after reload = %q, want second", config.value)
+	}
+
+	writeConfig(t, path, "")
+	result = Named("test-config")
+	if len(result.Reloaded) != 0 || result.Errors["test-config"] != "empty configuration" {
+		t.Fatalf("Named() = %+v, want test-config to fail with empty configuration", result)
+	}
+	if config.value != "second" {
+		t.Errorf("value after failed reload = %q, want second to be kept", config.value)
+	}
+}
+
+func TestAllReload(t *testing.T) {
+	dir := t.TempDir()
+	goodPath := filepath.Join(dir, "good")
+	badPath := filepath.Join(dir, "bad")
+	writeConfig(t, goodPath, "good")
+	good := &fileConfig{path: goodPath}
+	bad := &fileConfig{path: badPath, value: "current"}
+	Register("test-good", good.reload, goodPath)
+	Register("test-bad", bad.reload, badPath)
+	t.Cleanup(func() {
+		Unregister("test-good")
+		Unregister("test-bad")
+	})
+
+	result := All()
+	if !contains(result.Reloaded, "test-good") || contains(result.Reloaded, "test-bad") {
+		t.Errorf("Reloaded = %v, want test-good and not test-bad", result.Reloaded)
+	}
+	if _, ok := result.Errors["test-bad"]; !ok {
+		t.Errorf("Errors = %v, want an error for test-bad", result.Errors)
+	}
+	if good.value != "good" || bad.value != "current" {
+		t.Errorf("values = %q, %q, want good and current", good.value, bad.value)
+	}
+
+	files := Files()
+	if !contains(files, goodPath) || !contains(files, badPath) {
+		t.Errorf("Files() = %v, want %s and %s", files, goodPath, badPath)
+	}
+}
+
+func TestNamedIgnoresUnknown(t *testing.T) {
+	result := Named("test-unregistered")
+	if len(result.Reloaded) != 0 || len(result.Errors) != 0 {
+		t.Errorf("Named(test-unregistered) = %+v, want nothing reloaded", result)
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	Register("test-unregister", (&fileConfig{path: path}).reload, path)
+	Unregister("test-unregister")
+
+	if result := All(); contains(result.Reloaded, "test-unregister") || result.Errors["test-unregister"] != "" {
+		t.Errorf("All() = %+v, want test-unregister not run", result)
+	}
+	if contains(Files(), path) {
+		t.Errorf("Files() = %v, want %s removed", Files(), path)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
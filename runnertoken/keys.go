@@ -0,0 +1,90 @@
+package runnertoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedKeyType is returned for keys which are neither RSA nor EC keys
+var ErrUnsupportedKeyType = errors.New("key is not an RSA or EC key")
+
+// ParseSigningKey parses a PEM encoded RSA or EC private key, decrypting it with the passphrase when it
+// is protected by the traditional OpenSSL encryption
+func ParseSigningKey(keyPEM []byte, passphrase string) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("failed to decode signing key PEM")
+	}
+
+	if x509.IsEncryptedPEMBlock(block) {
+		keyBytes, err := x509.DecryptPEMBlock(block, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt signing key: %v", err)
+		}
+		block = &pem.Block{Type: block.Type, Bytes: keyBytes}
+	}
+
+	return ParsePrivateKey(block)
+}
+
+// ParsePrivateKey parses a PKCS#1 or SEC 1 private key, falling back to PKCS#8
+func ParsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	if block.Type == "EC PRIVATE KEY" {
+		if privateKey, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+			return privateKey, nil
+		}
+	} else if privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return privateKey, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("failed to parse signing key from PEM")
+	}
+
+	switch privateKey := key.(type) {
+	case *rsa.PrivateKey:
+		return privateKey, nil
+	case *ecdsa.PrivateKey:
+		return privateKey, nil
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+// ParseEncryptionKey parses a PEM encoded RSA or EC public key
+func ParseEncryptionKey(keyPEM []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("failed to decode encryption key PEM")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("failed to parse encryption key PEM")
+	}
+
+	switch publicKey.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return publicKey, nil
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+// KeyID derives the kid the runner expects for a key, the SHA-1 digest of its PEM encoded public key
+func KeyID(publicKey crypto.PublicKey) (string, error) {
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %v", err)
+	}
+
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})
+	return fmt.Sprintf("%x", sha1.Sum(publicKeyPEM)), nil
+}
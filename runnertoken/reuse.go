@@ -0,0 +1,122 @@
+package runnertoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"sync"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// maxReusedObjects bounds the signers and encrypters kept for reuse. Tokens are usually made with a handful of
+// keys, but fault injection signs with a new key each time, so the cache starts over when it fills.
+const maxReusedObjects = 64
+
+type signerKey struct {
+	key       crypto.Signer
+	algorithm jose.SignatureAlgorithm
+	kid       string
+}
+
+type encrypterKey struct {
+	key              crypto.PublicKey
+	kid              string
+	keyAlgorithm     jose.KeyAlgorithm
+	contentAlgorithm jose.ContentEncryption
+}
+
+var (
+	reuse            = true
+	reuseMutex       sync.Mutex
+	reusedSigners    = make(map[signerKey]jose.Signer)
+	reusedEncrypters = make(map[encrypterKey]jose.Encrypter)
+)
+
+// SetReuse sets whether the signers and encrypters tokens are made with are kept and reused for later tokens with
+// the same keys and algorithms, which they are by default. Without reuse they are created for every token, as the
+// bench command does to compare the two.
+func SetReuse(enabled bool) {
+	reuseMutex.Lock()
+	defer reuseMutex.Unlock()
+
+	reuse = enabled
+	reusedSigners = make(map[signerKey]jose.Signer)
+	reusedEncrypters = make(map[encrypterKey]jose.Encrypter)
+}
+
+// reusable reports whether objects made with the key can be kept, which needs a key that can be compared, as
+// RSA and EC keys held by pointer can. Keys such as symmetric ones given as bytes are never kept.
+func reusable(key interface{}) bool {
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, *rsa.PublicKey, *ecdsa.PublicKey:
+		return true
+	}
+	return false
+}
+
+// joseSigner is the signer for the key, algorithm and kid, created on first use. Signers and encrypters are safe
+// for concurrent use, each token drawing its own randomness.
+func joseSigner(key crypto.Signer, algorithm jose.SignatureAlgorithm, kid string) (jose.Signer, error) {
+	cacheKey := signerKey{key: key, algorithm: algorithm, kid: kid}
+
+	if reusable(key) {
+		reuseMutex.Lock()
+		signer, ok := reusedSigners[cacheKey]
+		reuseMutex.Unlock()
+		if ok {
+			return signer, nil
+		}
+	}
+
+	opts := jose.SignerOptions{}
+	opts.WithType("JWT")
+	opts.WithHeader("kid", kid)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: algorithm, Key: key}, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	reuseMutex.Lock()
+	defer reuseMutex.Unlock()
+	if reuse && reusable(key) {
+		if len(reusedSigners) >= maxReusedObjects {
+			reusedSigners = make(map[signerKey]jose.Signer)
+		}
+		reusedSigners[cacheKey] = signer
+	}
+	return signer, nil
+}
+
+// joseEncrypter is the compact encrypter for a single recipient, created on first use like joseSigner
+func joseEncrypter(key crypto.PublicKey, kid string, keyAlgorithm jose.KeyAlgorithm, contentAlgorithm jose.ContentEncryption) (jose.Encrypter, error) {
+	cacheKey := encrypterKey{key: key, kid: kid, keyAlgorithm: keyAlgorithm, contentAlgorithm: contentAlgorithm}
+
+	if reusable(key) {
+		reuseMutex.Lock()
+		encrypter, ok := reusedEncrypters[cacheKey]
+		reuseMutex.Unlock()
+		if ok {
+			return encrypter, nil
+		}
+	}
+
+	encrypter, err := jose.NewEncrypter(
+		contentAlgorithm,
+		jose.Recipient{Algorithm: keyAlgorithm, Key: key, KeyID: kid},
+		(&jose.EncrypterOptions{}).WithType("JWT").WithContentType("JWT"))
+	if err != nil {
+		return nil, err
+	}
+
+	reuseMutex.Lock()
+	defer reuseMutex.Unlock()
+	if reuse && reusable(key) {
+		if len(reusedEncrypters) >= maxReusedObjects {
+			reusedEncrypters = make(map[encrypterKey]jose.Encrypter)
+		}
+		reusedEncrypters[cacheKey] = encrypter
+	}
+	return encrypter, nil
+}
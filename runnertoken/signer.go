@@ -0,0 +1,81 @@
+package runnertoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// signWithSigner creates the compact JWS with a crypto.Signer whose private key go-jose cannot use directly,
+// such as a key held in a KMS, signing the digest of the signing input
+func signWithSigner(payload []byte, key crypto.Signer, algorithm jose.SignatureAlgorithm, kid string) (string, error) {
+	hash, opts, err := signerOptions(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": string(algorithm), "kid": kid, "typ": "JWT"})
+	if err != nil {
+		return "", fmt.Errorf("error signing JWT: %v", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := hash.New()
+	digest.Write([]byte(signingInput))
+
+	signature, err := key.Sign(rand.Reader, digest.Sum(nil), opts)
+	if err != nil {
+		return "", fmt.Errorf("error signing JWT: %v", err)
+	}
+
+	if publicKey, ok := key.Public().(*ecdsa.PublicKey); ok {
+		if signature, err = rawECDSASignature(signature, publicKey); err != nil {
+			return "", fmt.Errorf("error signing JWT: %v", err)
+		}
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func signerOptions(algorithm jose.SignatureAlgorithm) (crypto.Hash, crypto.SignerOpts, error) {
+	switch algorithm {
+	case jose.RS256, jose.ES256:
+		return crypto.SHA256, crypto.SHA256, nil
+	case jose.RS384, jose.ES384:
+		return crypto.SHA384, crypto.SHA384, nil
+	case jose.RS512, jose.ES512:
+		return crypto.SHA512, crypto.SHA512, nil
+	case jose.PS256:
+		return crypto.SHA256, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}, nil
+	case jose.PS384:
+		return crypto.SHA384, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA384}, nil
+	case jose.PS512:
+		return crypto.SHA512, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}, nil
+	}
+	return 0, nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+}
+
+// rawECDSASignature converts the ASN.1 DER signature which crypto.Signer gives for EC keys into the fixed
+// length r || s form of JWS
+func rawECDSASignature(der []byte, publicKey *ecdsa.PublicKey) ([]byte, error) {
+	var signature struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &signature); err != nil {
+		return nil, fmt.Errorf("invalid ECDSA signature: %v", err)
+	}
+
+	size := (publicKey.Curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	signature.R.FillBytes(raw[:size])
+	signature.S.FillBytes(raw[size:])
+	return raw, nil
+}
@@ -0,0 +1,202 @@
+// Package runnertoken mints the signed and encrypted tokens which launch a questionnaire on the runner.
+// It takes its keys and algorithms from the caller rather than from the launcher's settings, so that
+// test suites can create runner tokens without running the launcher:
+//
+//	signingKey, _ := runnertoken.ParseSigningKey(signingPEM, "")
+//	encryptionKey, _ := runnertoken.ParseEncryptionKey(encryptionPEM)
+//	claims := runnertoken.NewClaims(10 * time.Minute)
+//	claims["schema_name"] = "test_checkbox"
+//	token, err := runnertoken.Keys{SigningKey: signingKey, EncryptionKey: encryptionKey}.Mint(claims)
+package runnertoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/json"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// Keys are the keys and algorithms a token is signed and encrypted with. The kids default to those derived
+// from the keys, the signing algorithm to the one suiting the signing key, the key management algorithm to
+// RSA-OAEP and the content encryption to A256GCM.
+type Keys struct {
+	SigningKey       crypto.Signer
+	SigningKid       string
+	SigningAlgorithm jose.SignatureAlgorithm
+
+	EncryptionKey    crypto.PublicKey
+	EncryptionKid    string
+	KeyAlgorithm     jose.KeyAlgorithm
+	ContentAlgorithm jose.ContentEncryption
+}
+
+// NewClaims creates the claims every runner token has: iat, exp after the expiry, and generated jti and tx_id
+func NewClaims(expiry time.Duration) map[string]interface{} {
+	issued := time.Now()
+	jti, _ := uuid.NewV4()
+	txID, _ := uuid.NewV4()
+
+	return map[string]interface{}{
+		"iat":   jwt.NewNumericDate(issued),
+		"exp":   jwt.NewNumericDate(issued.Add(expiry)),
+		"jti":   jti.String(),
+		"tx_id": txID.String(),
+	}
+}
+
+// Mint signs and encrypts the claims, returning the compact JWE
+func (k Keys) Mint(claims map[string]interface{}) (string, error) {
+	if k.SigningKey == nil || k.EncryptionKey == nil {
+		return "", errors.New("a signing key and an encryption key are required")
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling claims: %v", err)
+	}
+
+	signingKid, err := keyIDOrDefault(k.SigningKid, k.SigningKey.Public())
+	if err != nil {
+		return "", err
+	}
+	encryptionKid, err := keyIDOrDefault(k.EncryptionKid, k.EncryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	signingAlgorithm := k.SigningAlgorithm
+	if signingAlgorithm == "" {
+		signingAlgorithm = SigningAlgorithmForKey(k.SigningKey)
+	}
+	keyAlgorithm := k.KeyAlgorithm
+	if keyAlgorithm == "" {
+		keyAlgorithm = jose.RSA_OAEP
+	}
+	contentAlgorithm := k.ContentAlgorithm
+	if contentAlgorithm == "" {
+		contentAlgorithm = jose.A256GCM
+	}
+
+	signed, err := Sign(payload, k.SigningKey, signingAlgorithm, signingKid)
+	if err != nil {
+		return "", err
+	}
+	return Encrypt(signed, k.EncryptionKey, encryptionKid, keyAlgorithm, contentAlgorithm)
+}
+
+func keyIDOrDefault(kid string, publicKey crypto.PublicKey) (string, error) {
+	if kid != "" {
+		return kid, nil
+	}
+	return KeyID(publicKey)
+}
+
+// SigningAlgorithmForKey is the usual signing algorithm for the key, RS256 for RSA keys and the ES algorithm
+// matching the curve of EC keys, or an empty algorithm for other keys
+func SigningAlgorithmForKey(key crypto.Signer) jose.SignatureAlgorithm {
+	switch publicKey := key.Public().(type) {
+	case *rsa.PublicKey:
+		return jose.RS256
+	case *ecdsa.PublicKey:
+		switch publicKey.Curve {
+		case elliptic.P256():
+			return jose.ES256
+		case elliptic.P384():
+			return jose.ES384
+		case elliptic.P521():
+			return jose.ES512
+		}
+	}
+	return ""
+}
+
+// Sign signs the exact payload bytes, returning the compact JWS with the kid and a JWT type header. Keys other
+// than RSA and EC private keys, such as those held in a KMS, sign through their crypto.Signer.
+func Sign(payload []byte, key crypto.Signer, algorithm jose.SignatureAlgorithm, kid string) (string, error) {
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+	default:
+		return signWithSigner(payload, key, algorithm, kid)
+	}
+
+	signer, err := joseSigner(key, algorithm, kid)
+	if err != nil {
+		return "", fmt.Errorf("error creating JWT signer: %v", err)
+	}
+
+	signature, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("error signing JWT: %v", err)
+	}
+
+	signed, err := signature.CompactSerialize()
+	if err != nil {
+		return "", fmt.Errorf("error signing JWT: %v", err)
+	}
+
+	return signed, nil
+}
+
+// Encrypt encrypts a compact JWS for the runner, returning the compact JWE
+func Encrypt(signed string, key crypto.PublicKey, kid string, keyAlgorithm jose.KeyAlgorithm, contentAlgorithm jose.ContentEncryption) (string, error) {
+	encryptor, err := joseEncrypter(key, kid, keyAlgorithm, contentAlgorithm)
+	if err != nil {
+		return "", fmt.Errorf("error creating JWT encrypter: %v", err)
+	}
+
+	encrypted, err := encryptor.Encrypt([]byte(signed))
+	if err != nil {
+		return "", fmt.Errorf("error encrypting JWT: %v", err)
+	}
+
+	token, err := encrypted.CompactSerialize()
+	if err != nil {
+		return "", fmt.Errorf("error encrypting JWT: %v", err)
+	}
+
+	return token, nil
+}
+
+// Recipient is a key a token is encrypted for, identified by its kid
+type Recipient struct {
+	Key crypto.PublicKey
+	Kid string
+}
+
+// EncryptForRecipients encrypts a compact JWS for every recipient, so that runners holding any one of their
+// keys can decrypt it. The compact serialization has room for a single recipient, so with more than one the
+// token is a JWE in the general JSON serialization.
+func EncryptForRecipients(signed string, recipients []Recipient, keyAlgorithm jose.KeyAlgorithm, contentAlgorithm jose.ContentEncryption) (string, error) {
+	if len(recipients) == 0 {
+		return "", errors.New("at least one recipient is required")
+	}
+	if len(recipients) == 1 {
+		return Encrypt(signed, recipients[0].Key, recipients[0].Kid, keyAlgorithm, contentAlgorithm)
+	}
+
+	joseRecipients := make([]jose.Recipient, 0, len(recipients))
+	for _, recipient := range recipients {
+		joseRecipients = append(joseRecipients, jose.Recipient{Algorithm: keyAlgorithm, Key: recipient.Key, KeyID: recipient.Kid})
+	}
+
+	encryptor, err := jose.NewMultiEncrypter(contentAlgorithm, joseRecipients,
+		(&jose.EncrypterOptions{}).WithType("JWT").WithContentType("JWT"))
+	if err != nil {
+		return "", fmt.Errorf("error creating JWT encrypter: %v", err)
+	}
+
+	encrypted, err := encryptor.Encrypt([]byte(signed))
+	if err != nil {
+		return "", fmt.Errorf("error encrypting JWT: %v", err)
+	}
+
+	return encrypted.FullSerialize(), nil
+}
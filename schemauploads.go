@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/ONSdigital/eq-questionnaire-launcher/surveys"
+	"github.com/gorilla/mux"
+)
+
+// uploadedSchema is an uploaded schema and the URL the runner reads it from
+type uploadedSchema struct {
+	Name      string `json:"name"`
+	SchemaURL string `json:"schema_url"`
+}
+
+// uploadedSchemaURL is where the launcher serves an uploaded schema, under SCHEMA_UPLOAD_BASE_URL when the
+// runner reaches the launcher at a different address to the tester
+func uploadedSchemaURL(r *http.Request, name string) string {
+	baseURL := strings.TrimSuffix(settings.Get("SCHEMA_UPLOAD_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = getAccountServiceURL(r)
+	}
+	return baseURL + "/uploaded-schemas/" + url.PathEscape(name)
+}
+
+// postUploadedSchemaHandler stores a schema, taken as the file field of a multipart form or as a JSON body.
+// It is named by the name value, or for a file by its filename without .json.
+func postUploadedSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	var schema []byte
+	var err error
+	name := r.URL.Query().Get("name")
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, fileErr := r.FormFile("file")
+		if isRequestTooLarge(fileErr) {
+			writeAPIError(w, 413, errorRequestTooLarge, http.StatusText(413))
+			return
+		}
+		if fileErr != nil {
+			writeAPIError(w, 400, errorInvalidRequest, "A schema file is required as the file field")
+			return
+		}
+		defer file.Close()
+
+		if formName := r.FormValue("name"); formName != "" {
+			name = formName
+		} else if name == "" {
+			name = surveys.UploadedSchemaName(header.Filename)
+		}
+		schema, err = ioutil.ReadAll(file)
+	} else {
+		schema, err = ioutil.ReadAll(r.Body)
+	}
+	if isRequestTooLarge(err) {
+		writeAPIError(w, 413, errorRequestTooLarge, http.StatusText(413))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, 500, errorInternal, fmt.Sprintf("Error reading schema: %v", err))
+		return
+	}
+	if name == "" {
+		writeAPIError(w, 400, errorInvalidRequest, "A schema name is required")
+		return
+	}
+
+	if err := surveys.SaveUploadedSchema(name, schema); err != nil {
+		writeAPIError(w, 400, errorInvalidRequest, err.Error())
+		return
+	}
+
+	logging.Info("Schema uploaded", "schema", name, "bytes", len(schema))
+	writeJSON(w, 201, uploadedSchema{Name: name, SchemaURL: uploadedSchemaURL(r, name)})
+}
+
+// getUploadedSchemasHandler lists the uploaded schemas
+func getUploadedSchemasHandler(w http.ResponseWriter, r *http.Request) {
+	names, err := surveys.ListUploadedSchemas()
+	if err != nil {
+		writeAPIError(w, 500, errorInternal, err.Error())
+		return
+	}
+
+	schemas := []uploadedSchema{}
+	for _, name := range names {
+		schemas = append(schemas, uploadedSchema{Name: name, SchemaURL: uploadedSchemaURL(r, name)})
+	}
+	writeJSON(w, 200, map[string]interface{}{"schemas": schemas})
+}
+
+// getUploadedSchemaHandler serves the JSON of an uploaded schema, for the runner to load by schema_url
+func getUploadedSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	schema, err := surveys.LoadUploadedSchema(name)
+	if err == surveys.ErrUploadedSchemaNotFound {
+		writeAPIError(w, 404, errorNotFound, "Schema not found: "+name)
+		return
+	}
+	if err != nil {
+		writeAPIError(w, 500, errorInternal, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(schema)
+}
@@ -0,0 +1,63 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// awsRegion is the region of a secret ARN, otherwise AWS_REGION
+func awsRegion(secretID string) string {
+	if parts := strings.Split(secretID, ":"); strings.HasPrefix(secretID, "arn:") && len(parts) > 3 {
+		return parts[3]
+	}
+	return settings.Get("AWS_REGION")
+}
+
+// fetchAWSSecret reads a secret with GetSecretValue, signed with the AWS credentials. A binary secret is
+// returned as its bytes, otherwise the secret string is.
+func fetchAWSSecret(ctx context.Context, secretID string) ([]byte, error) {
+	if !clients.HasAWSCredentials() {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for AWS Secrets Manager")
+	}
+
+	region := awsRegion(secretID)
+	url := endpoint(fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region)) + "/"
+	payload, _ := json.Marshal(map[string]string{"SecretId": secretID})
+
+	headers, err := clients.AWSSignatureHeaders("POST", url, payload, "secretsmanager", region, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	headers.Set("Content-Type", "application/x-amz-json-1.1")
+	headers.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+
+	resp, err := clients.PostWithHeaders(ctx, url, headers, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("AWS Secrets Manager unreachable: %v", err)
+	}
+	body, err := readSecretResponse(resp, "AWS Secrets Manager")
+	if err != nil {
+		return nil, err
+	}
+
+	var secretResponse struct {
+		SecretString string `json:"SecretString"`
+		SecretBinary string `json:"SecretBinary"`
+	}
+	if err := json.Unmarshal(body, &secretResponse); err != nil {
+		return nil, fmt.Errorf("invalid AWS Secrets Manager GetSecretValue response: %v", err)
+	}
+
+	if secretResponse.SecretBinary != "" {
+		return base64.StdEncoding.DecodeString(secretResponse.SecretBinary)
+	}
+	return []byte(secretResponse.SecretString), nil
+}
@@ -0,0 +1,46 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"gopkg.in/square/go-jose.v2/json"
+)
+
+// fetchGCPSecret reads a secret version with the access method, authorised by GCP_ACCESS_TOKEN or the service
+// account of the instance. A secret named without a version is read at its latest version.
+func fetchGCPSecret(ctx context.Context, name string) ([]byte, error) {
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+
+	token, err := clients.GCPAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	headers := http.Header{"Authorization": {"Bearer " + token}}
+
+	resp, err := clients.GetWithHeaders(ctx, endpoint("https://secretmanager.googleapis.com")+"/v1/"+name+":access", headers)
+	if err != nil {
+		return nil, fmt.Errorf("GCP Secret Manager unreachable: %v", err)
+	}
+	body, err := readSecretResponse(resp, "GCP Secret Manager")
+	if err != nil {
+		return nil, err
+	}
+
+	var accessResponse struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &accessResponse); err != nil {
+		return nil, fmt.Errorf("invalid GCP Secret Manager access response: %v", err)
+	}
+
+	return base64.StdEncoding.DecodeString(accessResponse.Payload.Data)
+}
@@ -0,0 +1,51 @@
+// Package secrets reads secrets held in AWS Secrets Manager or GCP Secret Manager, such as the PEM text of a key.
+// A secret is named by a URI, aws-sm://<secret name or ARN> or gcp-sm://projects/<project>/secrets/<secret>, which
+// may end /versions/<version> to read other than the latest version.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+const (
+	awsSecretsManagerPrefix = "aws-sm://"
+	gcpSecretManagerPrefix  = "gcp-sm://"
+)
+
+// Fetch reads the current value of the secret named by the URI
+func Fetch(ctx context.Context, uri string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(uri, awsSecretsManagerPrefix):
+		return fetchAWSSecret(ctx, strings.TrimPrefix(uri, awsSecretsManagerPrefix))
+	case strings.HasPrefix(uri, gcpSecretManagerPrefix):
+		return fetchGCPSecret(ctx, strings.TrimPrefix(uri, gcpSecretManagerPrefix))
+	}
+	return nil, fmt.Errorf("secret %q must start with %s or %s", uri, awsSecretsManagerPrefix, gcpSecretManagerPrefix)
+}
+
+// endpoint is SECRETS_ENDPOINT_URL, otherwise the default endpoint of the secret manager
+func endpoint(defaultEndpoint string) string {
+	if override := strings.TrimSuffix(settings.Get("SECRETS_ENDPOINT_URL"), "/"); override != "" {
+		return override
+	}
+	return defaultEndpoint
+}
+
+func readSecretResponse(resp *http.Response, service string) ([]byte, error) {
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %v", service, err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s returned %d: %s", service, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+	"github.com/ONSdigital/eq-questionnaire-launcher/tracing"
+)
+
+// secondsSetting reads the named setting as a number of seconds, using defaultSeconds when it is not a
+// number. Zero or less disables the timeout it configures.
+func secondsSetting(name string, defaultSeconds int) time.Duration {
+	seconds, err := strconv.Atoi(settings.Get(name))
+	if err != nil {
+		seconds = defaultSeconds
+	}
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// newServer creates the HTTP server with the SERVER_*_TIMEOUT_SECONDS timeouts, so that slow or idle
+// clients cannot hold connections open indefinitely
+func newServer(address string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		ReadHeaderTimeout: secondsSetting("SERVER_READ_TIMEOUT_SECONDS", 15),
+		ReadTimeout:       secondsSetting("SERVER_READ_TIMEOUT_SECONDS", 15),
+		WriteTimeout:      secondsSetting("SERVER_WRITE_TIMEOUT_SECONDS", 60),
+		IdleTimeout:       secondsSetting("SERVER_IDLE_TIMEOUT_SECONDS", 120),
+	}
+}
+
+// serve runs the server, over HTTPS when TLS_CERT_PATH and TLS_KEY_PATH are set, until it fails or the
+// process receives SIGTERM or SIGINT. On a signal it stops accepting connections and waits up to
+// SHUTDOWN_DRAIN_SECONDS for in-flight requests to complete, so that a rollout does not cut off launches
+// part way through.
+func serve(server *http.Server) error {
+	serveErr := make(chan error, 1)
+	certPath, keyPath := settings.Get("TLS_CERT_PATH"), settings.Get("TLS_KEY_PATH")
+	go func() {
+		if certPath != "" || keyPath != "" {
+			serveErr <- server.ListenAndServeTLS(certPath, keyPath)
+			return
+		}
+		serveErr <- server.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case received := <-signals:
+		drain := secondsSetting("SHUTDOWN_DRAIN_SECONDS", 25)
+		logging.Info("Shutting down, draining in-flight requests", "signal", received.String(), "drain_seconds", drain.Seconds())
+
+		ctx := context.Background()
+		if drain > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, drain)
+			defer cancel()
+		}
+
+		if err := server.Shutdown(ctx); err != nil {
+			logging.Warn("In-flight requests did not complete before the drain period ended", "err", err)
+			return server.Close()
+		}
+		tracing.Shutdown(ctx)
+		logging.Info("Shutdown complete")
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configPathVariable is the environment variable naming the config file. It is read from the
+// environment only, as it is needed before any other setting.
+const configPathVariable = "CONFIG_PATH"
+
+// jsonObjectSettings take a JSON object, so a mapping for one of them in the config file is kept whole as JSON
+// rather than joined into setting names
+var jsonObjectSettings = map[string]bool{"JWT_SIGNING_KEYS": true, "JWT_ENCRYPTION_KEYS": true, "CLAIM_DEFAULTS": true,
+	"TOKEN_POOL_TEMPLATE": true}
+
+// readConfigFile reads the settings of a YAML (or JSON) config file. Setting names are matched
+// case-insensitively, nested mappings are joined with underscores so that jwt: {kid: x} sets JWT_KID,
+// and lists are joined with commas.
+func readConfigFile(path string) (map[string]string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(contents, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flattenConfig("", config, values); err != nil {
+		return nil, fmt.Errorf("invalid setting in %s: %v", path, err)
+	}
+	return values, nil
+}
+
+func flattenConfig(prefix string, config map[string]interface{}, values map[string]string) error {
+	for key, value := range config {
+		name := strings.ToUpper(prefix + key)
+
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if jsonObjectSettings[name] {
+				encoded, err := json.Marshal(value)
+				if err != nil {
+					return fmt.Errorf("%s could not be made JSON: %v", name, err)
+				}
+				values[name] = string(encoded)
+				continue
+			}
+			if err := flattenConfig(name+"_", value, values); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, len(value))
+			for i, item := range value {
+				if _, ok := item.(map[string]interface{}); ok {
+					return fmt.Errorf("%s is a list which may only hold values", name)
+				}
+				items[i] = fmt.Sprint(item)
+			}
+			values[name] = strings.Join(items, ",")
+		case nil:
+			values[name] = ""
+		default:
+			values[name] = fmt.Sprint(value)
+		}
+	}
+	return nil
+}
+
+// unknownConfigSettings lists the settings of the config file which the launcher does not have, which are
+// most likely misspelt
+func unknownConfigSettings() []string {
+	var unknown []string
+	for name := range _fileSettings {
+		if _, ok := _settings[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
@@ -0,0 +1,85 @@
+package settings
+
+import (
+	"fmt"
+)
+
+// Sources of a setting's value
+const (
+	SourceDefault     = "default"
+	SourceEnvironment = "environment"
+	SourceConfigFile  = "config_file"
+	SourceOverride    = "override"
+)
+
+// maskedValue replaces the values of secret settings
+const maskedValue = "********"
+
+// secretSettings hold credentials or key material, whose values are never shown
+var secretSettings = map[string]bool{
+	"BASIC_AUTH_PASSWORD":        true,
+	"CIR_API_TOKEN":              true,
+	"JWT_SIGNING_KEY":            true,
+	"JWT_SIGNING_KEY_PASSPHRASE": true,
+	"AWS_SECRET_ACCESS_KEY":      true,
+	"AWS_SESSION_TOKEN":          true,
+	"GCS_ACCESS_TOKEN":           true,
+	"GCP_ACCESS_TOKEN":           true,
+	"OTEL_EXPORTER_OTLP_HEADERS": true,
+	"WEBHOOK_SECRET":             true,
+	"ADMIN_TOKEN":                true,
+	"REDIS_URL":                  true,
+	"API_KEYS":                   true,
+}
+
+// _overrides are values set while the launcher runs, which take precedence over every other source
+var _overrides = make(map[string]string)
+
+// Setting is the effective value of a setting and where it came from
+type Setting struct {
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// Override sets the named setting for the life of the process, taking precedence over the environment and the
+// config file. Only settings the launcher has can be overridden.
+func Override(name string, value string) error {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	if _, ok := _settings[name]; !ok {
+		return fmt.Errorf("unknown setting %s", name)
+	}
+	_overrides[name] = value
+	return nil
+}
+
+// ClearOverride returns the named setting to its configured value
+func ClearOverride(name string) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	delete(_overrides, name)
+}
+
+// Effective lists every setting with its effective value, masking the values of secrets which are set
+func Effective() map[string]Setting {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+
+	effective := make(map[string]Setting, len(_settings))
+	for name, value := range _settings {
+		source := _sources[name]
+		if override, ok := _overrides[name]; ok {
+			value, source = override, SourceOverride
+		}
+
+		setting := Setting{Value: value, Source: source, Secret: secretSettings[name]}
+		if setting.Secret && value != "" {
+			setting.Value = maskedValue
+		}
+		effective[name] = setting
+	}
+	return effective
+}
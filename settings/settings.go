@@ -1,30 +1,199 @@
 package settings
 
-import "os"
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
 
 var _settings map[string]string
 
+// _fileSettings are the settings of the CONFIG_PATH file, which environment variables take precedence over
+var _fileSettings map[string]string
+
+// _sources records where each setting's configured value came from
+var _sources map[string]string
+
+// settingsMutex guards the settings against runtime overrides
+var settingsMutex sync.RWMutex
+
 func setSetting(key string, defaultValue string) {
 	if value, present := os.LookupEnv(key); present {
 		_settings[key] = value
+		_sources[key] = SourceEnvironment
+	} else if value, present := _fileSettings[key]; present {
+		_settings[key] = value
+		_sources[key] = SourceConfigFile
 	} else {
 		_settings[key] = defaultValue
+		_sources[key] = SourceDefault
 	}
 }
 
 func init() {
 	_settings = make(map[string]string)
+	_fileSettings = make(map[string]string)
+	_sources = make(map[string]string)
+	if configPath := os.Getenv(configPathVariable); configPath != "" {
+		fileSettings, err := readConfigFile(configPath)
+		if err != nil {
+			log.Fatalf("Failed to load %s: %v", configPathVariable, err)
+		}
+		_fileSettings = fileSettings
+	}
+
 	setSetting("GO_LAUNCH_A_SURVEY_LISTEN_HOST", "0.0.0.0")
 	setSetting("GO_LAUNCH_A_SURVEY_LISTEN_PORT", "8000")
+	setSetting("SERVER_READ_TIMEOUT_SECONDS", "15")
+	setSetting("SERVER_WRITE_TIMEOUT_SECONDS", "60")
+	setSetting("SERVER_IDLE_TIMEOUT_SECONDS", "120")
+	setSetting("SHUTDOWN_DRAIN_SECONDS", "25")
+	setSetting("TLS_CERT_PATH", "")
+	setSetting("TLS_KEY_PATH", "")
+	setSetting("BASIC_AUTH_USERNAME", "")
+	setSetting("BASIC_AUTH_PASSWORD", "")
+	setSetting("AUTH_PROXY_HEADER", "")
+	setSetting("AUTH_PROXY_ALLOWED_USERS", "")
 	setSetting("SURVEY_RUNNER_URL", "http://localhost:5000")
 	setSetting("SURVEY_RUNNER_SCHEMA_URL", Get("SURVEY_RUNNER_URL"))
+	setSetting("READINESS_CHECK_RUNNER", "false")
 	setSetting("SCHEMA_VALIDATOR_URL", "")
 	setSetting("SURVEY_REGISTER_URL", "")
+	setSetting("SDS_API_URL", "")
+	setSetting("CIR_API_URL", "")
+	setSetting("CIR_API_TOKEN", "")
 	setSetting("JWT_ENCRYPTION_KEY_PATH", "jwt-test-keys/sdc-user-authentication-encryption-sr-public-key.pem")
 	setSetting("JWT_SIGNING_KEY_PATH", "jwt-test-keys/sdc-user-authentication-signing-launcher-private-key.pem")
+	setSetting("JWT_SIGNING_KEY_PASSPHRASE", "")
+	setSetting("JWT_ENCRYPTION_KEY", "")
+	setSetting("JWT_SIGNING_KEY", "")
+	setSetting("JWT_SIGNING_KMS_KEY", "")
+	setSetting("JWT_DECRYPTION_KEY_PATH", "")
+	setSetting("JWT_VERIFICATION_KEY_PATH", "")
+	setSetting("JWT_SIGNING_ALGORITHM", "RS256")
+	setSetting("JWT_KID", "")
+	setSetting("JWT_KID_DIGEST", "sha1")
+	setSetting("DEFAULT_CLAIMS_VERSION", "v1")
+	setSetting("JWT_SIGNING_KEYS", "")
+	setSetting("JWT_ENCRYPTION_KEYS", "")
+	setSetting("JWT_KEY_ALGORITHM", "RSA-OAEP")
+	setSetting("JWT_CONTENT_ALGORITHM", "A256GCM")
+	setSetting("DEVELOPER_MODE", "false")
+	setSetting("JWT_ENCRYPTION_DISABLED", "false")
+	setSetting("JTI_REISSUE_ENABLED", "false")
+	setSetting("FEEDBACK_TOKEN_CLAIMS", "case_id,response_id,schema_name,survey_id,form_type,language_code,region_code,channel,survey_metadata")
+	setSetting("RECEIPT_TOKEN_CLAIMS", "case_id,response_id,collection_exercise_sid,ru_ref,survey_id,period_id,schema_name,survey_metadata")
+	setSetting("RUNNER_FEEDBACK_PATH", "/feedback")
+	setSetting("RUNNER_RECEIPT_PATH", "/receipt")
+	setSetting("JWT_EXPIRY_MINUTES", "10")
+	setSetting("JWT_MAX_EXPIRY_SECONDS", "")
+	setSetting("JWT_IAT_OFFSET_SECONDS", "")
+	setSetting("JWT_EXP_OFFSET_SECONDS", "")
+	setSetting("JWT_ISSUER", "")
+	setSetting("JWT_AUDIENCE", "")
+	setSetting("JWKS_INCLUDE_SIGNING_KEY", "false")
+	setSetting("SCHEMA_LIST_CACHE_SECONDS", "60")
+	setSetting("SCHEMA_BUCKETS", "")
+	setSetting("SCHEMA_BUCKET_PROXY_URL", "")
+	setSetting("SCHEMA_UPLOADS_PATH", "")
+	setSetting("SCHEMA_UPLOAD_BASE_URL", "")
+	setSetting("AWS_REGION", "eu-west-2")
+	setSetting("AWS_ACCESS_KEY_ID", "")
+	setSetting("AWS_SECRET_ACCESS_KEY", "")
+	setSetting("AWS_SESSION_TOKEN", "")
+	setSetting("S3_ENDPOINT_URL", "")
+	setSetting("GCS_ACCESS_TOKEN", "")
+	setSetting("GCP_ACCESS_TOKEN", "")
+	setSetting("KMS_ENDPOINT_URL", "")
+	setSetting("JWT_SIGNING_KEY_SECRET", "")
+	setSetting("JWT_ENCRYPTION_KEY_SECRET", "")
+	setSetting("SECRETS_ENDPOINT_URL", "")
+	setSetting("RESPONSE_EXPIRY_DAYS", "7")
+	setSetting("RESPONSE_EXPIRY_OFFSET", "")
+	setSetting("SUPPORTED_LANGUAGE_CODES", "en,cy,ga,eo")
+	setSetting("SUPPORTED_REGION_CODES", "")
+	setSetting("REGION_PRESETS_PATH", "")
+	setSetting("DEFAULT_CHANNEL", "")
+	setSetting("ACCOUNT_SERVICE_URL", "")
+	setSetting("ACCOUNT_SERVICE_LOG_OUT_URL", "")
+	setSetting("CLAIM_DEFAULTS", "")
+	setSetting("AUTO_USER_ID", "false")
+	setSetting("AUTO_CASE_ID", "true")
+	setSetting("ROLES_FORMAT", "array")
+	setSetting("PROFILES_PATH", "")
+	setSetting("STATE_BACKEND", "memory")
+	setSetting("REDIS_URL", "")
+	setSetting("SQLITE_PATH", "")
+	setSetting("STATE_KEY_PREFIX", "launcher:")
+	setSetting("HISTORY_SIZE", "50")
+	setSetting("HISTORY_PATH", "")
+	setSetting("HISTORY_REDACT_FIELDS", "")
+	setSetting("LAUNCH_LINKS_PATH", "")
+	setSetting("LAUNCH_LINK_EXPIRY_SECONDS", "86400")
+	setSetting("LAUNCH_LINK_MAX_EXPIRY_SECONDS", "604800")
+	setSetting("WEBHOOK_URL", "")
+	setSetting("WEBHOOK_SECRET", "")
+	setSetting("LOG_LEVEL", "info")
+	setSetting("LOG_FORMAT", "text")
+	setSetting("LOG_SENSITIVE", "false")
+	setSetting("OTEL_SDK_DISABLED", "false")
+	setSetting("OTEL_TRACES_EXPORTER", "")
+	setSetting("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	setSetting("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	setSetting("OTEL_EXPORTER_OTLP_HEADERS", "")
+	setSetting("OTEL_SERVICE_NAME", "")
+	setSetting("OTEL_RESOURCE_ATTRIBUTES", "")
+	setSetting("OTEL_TRACES_SAMPLER", "parentbased_always_on")
+	setSetting("OTEL_TRACES_SAMPLER_ARG", "")
+	setSetting("OTEL_BSP_SCHEDULE_DELAY", "5000")
+	setSetting("OTEL_BSP_MAX_QUEUE_SIZE", "2048")
+	setSetting("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", "512")
+	setSetting("HTTP_PROXY", "")
+	setSetting("HTTPS_PROXY", "")
+	setSetting("CA_BUNDLE_PATH", "")
+	setSetting("HTTP_CLIENT_TIMEOUT_SECONDS", "5")
+	setSetting("CLAIMS_JSON_STYLE", "minified")
+	setSetting("FAULT_INJECTION", "false")
+	setSetting("REDIRECT_HOST_ALLOWLIST", "")
+	setSetting("TX_ID_EMBED_METADATA", "false")
+	setSetting("TX_ID_SEPARATOR", "-")
+	setSetting("TX_ID_MAX_LENGTH", "64")
+	setSetting("MAX_REQUEST_BODY_BYTES", "1048576")
+	setSetting("MAX_BATCH_SIZE", "1000")
+	setSetting("TOKEN_SIGNING_WORKERS", "0")
+	setSetting("TOKEN_POOL_SIZE", "0")
+	setSetting("TOKEN_POOL_REFILL_PER_SECOND", "50")
+	setSetting("TOKEN_POOL_TEMPLATE", "")
+	setSetting("RATE_LIMIT_PER_MINUTE", "0")
+	setSetting("RATE_LIMIT_BURST", "")
+	setSetting("RATE_LIMIT_TRUST_FORWARDED_FOR", "false")
+	setSetting("RETURN_WRAPPED_TOKENS", "false")
+	setSetting("ADMIN_TOKEN", "")
+	setSetting("API_KEYS", "")
+	setSetting("AUDIT_LOG_SIZE", "10000")
+	setSetting("AUDIT_LOG_PATH", "")
+	setSetting("VALIDATION_PROFILES_PATH", "")
+	setSetting("ENVIRONMENTS_PATH", "")
+	setSetting("DEV_MODE", "false")
+	setSetting("CONFIG_WATCH_SECONDS", "0")
+	setSetting("COMPOSITE_REFERENCE_CLAIM", "")
+	setSetting("COMPOSITE_REFERENCE_TEMPLATE", "{ru_ref}{period_id}")
+	setSetting("REQUIRED_CLAIMS", "collection_exercise_sid")
+	setSetting("VALIDATE_SCHEMA_METADATA", "true")
+
+	if unknown := unknownConfigSettings(); len(unknown) > 0 {
+		log.Fatalf("Unknown settings in %s: %s", os.Getenv(configPathVariable), strings.Join(unknown, ", "))
+	}
 }
 
 // Get returns the value for the specified named setting
 func Get(name string) string {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+
+	if value, ok := _overrides[name]; ok {
+		return value
+	}
 	return _settings[name]
 }
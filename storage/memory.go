@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryValue struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// memoryBackend keeps the state in maps guarded by a mutex
+type memoryBackend struct {
+	values map[string]memoryValue
+	lists  map[string][][]byte
+	mutex  sync.Mutex
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{values: make(map[string]memoryValue), lists: make(map[string][][]byte)}
+}
+
+// value returns the unexpired value under key, dropping it once it has expired. The mutex must be held.
+func (m *memoryBackend) value(key string) (memoryValue, bool) {
+	stored, ok := m.values[key]
+	if ok && !stored.expiresAt.IsZero() && time.Now().After(stored.expiresAt) {
+		delete(m.values, key)
+		return memoryValue{}, false
+	}
+	return stored, ok
+}
+
+func (m *memoryBackend) Get(key string) ([]byte, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stored, ok := m.value(key)
+	return stored.value, ok, nil
+}
+
+func (m *memoryBackend) Put(key string, value []byte, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stored := memoryValue{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		stored.expiresAt = time.Now().Add(ttl)
+	}
+	m.values[key] = stored
+	return nil
+}
+
+func (m *memoryBackend) Delete(key string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, isValue := m.value(key)
+	_, isList := m.lists[key]
+	delete(m.values, key)
+	delete(m.lists, key)
+	return isValue || isList, nil
+}
+
+func (m *memoryBackend) Keys(prefix string) ([]string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	keys := []string{}
+	for key := range m.values {
+		if _, ok := m.value(key); ok && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *memoryBackend) Incr(key string) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stored, _ := m.value(key)
+	number, _ := strconv.ParseInt(string(stored.value), 10, 64)
+	number++
+	m.values[key] = memoryValue{value: []byte(strconv.FormatInt(number, 10))}
+	return number, nil
+}
+
+func (m *memoryBackend) Append(list string, value []byte, keep int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	values := append(m.lists[list], append([]byte(nil), value...))
+	if keep > 0 && len(values) > keep {
+		values = values[len(values)-keep:]
+	}
+	m.lists[list] = values
+	return nil
+}
+
+func (m *memoryBackend) Range(list string) ([][]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([][]byte(nil), m.lists[list]...), nil
+}
+
+func (m *memoryBackend) Pop(list string) ([]byte, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	values := m.lists[list]
+	if len(values) == 0 {
+		return nil, false, nil
+	}
+	m.lists[list] = values[1:]
+	return values[0], true, nil
+}
+
+func (m *memoryBackend) Len(list string) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return len(m.lists[list]), nil
+}
@@ -0,0 +1,322 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// redisTimeout bounds connecting to Redis and each command, so that an unreachable Redis fails launches
+	// quickly rather than holding them
+	redisTimeout = 5 * time.Second
+	// maxIdleRedisConnections are kept open between commands
+	maxIdleRedisConnections = 8
+	// redisScanCount is how many keys Redis is asked to examine on each SCAN
+	redisScanCount = "100"
+)
+
+// redisBackend keeps the state in Redis under keys starting with its prefix, speaking the Redis protocol over
+// connections it keeps open between commands
+type redisBackend struct {
+	address  string
+	useTLS   bool
+	username string
+	password string
+	database string
+	prefix   string
+	idle     chan *redisConnection
+}
+
+type redisConnection struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// redisError is an error reply from Redis, after which the connection can still be used
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// newRedisBackend connects to the Redis at a redis:// or, for TLS, rediss:// URL with any password and database
+// number, such as redis://:password@localhost:6379/0
+func newRedisBackend(rawURL string, prefix string) (*redisBackend, error) {
+	if rawURL == "" {
+		return nil, errors.New("REDIS_URL must be set when STATE_BACKEND is redis")
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "redis" && parsed.Scheme != "rediss") || parsed.Host == "" {
+		return nil, errors.New("REDIS_URL must be a redis:// or rediss:// URL")
+	}
+
+	backend := &redisBackend{
+		address:  parsed.Host,
+		useTLS:   parsed.Scheme == "rediss",
+		database: strings.TrimPrefix(parsed.Path, "/"),
+		prefix:   prefix,
+		idle:     make(chan *redisConnection, maxIdleRedisConnections),
+	}
+	if parsed.Port() == "" {
+		backend.address = net.JoinHostPort(parsed.Hostname(), "6379")
+	}
+	if parsed.User != nil {
+		backend.username = parsed.User.Username()
+		backend.password, _ = parsed.User.Password()
+	}
+	if backend.database != "" {
+		if _, err := strconv.Atoi(backend.database); err != nil {
+			return nil, errors.New("REDIS_URL database must be a number")
+		}
+	}
+
+	return backend, nil
+}
+
+func (r *redisBackend) ping() error {
+	_, err := r.do("PING")
+	return err
+}
+
+func (r *redisBackend) Get(key string) ([]byte, bool, error) {
+	reply, err := r.do("GET", r.prefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	return reply.([]byte), true, nil
+}
+
+func (r *redisBackend) Put(key string, value []byte, ttl time.Duration) error {
+	args := []interface{}{"SET", r.prefix + key, value}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := r.do(args...)
+	return err
+}
+
+func (r *redisBackend) Delete(key string) (bool, error) {
+	reply, err := r.do("DEL", r.prefix+key)
+	if err != nil {
+		return false, err
+	}
+	return reply.(int64) > 0, nil
+}
+
+// Keys walks the keyspace with SCAN rather than KEYS, so that a large Redis is not blocked while it is listed
+func (r *redisBackend) Keys(prefix string) ([]string, error) {
+	keys := []string{}
+	cursor := "0"
+	for {
+		reply, err := r.do("SCAN", cursor, "MATCH", escapeRedisPattern(r.prefix+prefix)+"*", "COUNT", redisScanCount)
+		if err != nil {
+			return nil, err
+		}
+
+		page, ok := reply.([]interface{})
+		if !ok || len(page) != 2 {
+			return nil, errors.New("redis: unexpected SCAN reply")
+		}
+		for _, key := range page[1].([]interface{}) {
+			keys = append(keys, strings.TrimPrefix(string(key.([]byte)), r.prefix))
+		}
+
+		if cursor = string(page[0].([]byte)); cursor == "0" {
+			return keys, nil
+		}
+	}
+}
+
+func (r *redisBackend) Incr(key string) (int64, error) {
+	reply, err := r.do("INCR", r.prefix+key)
+	if err != nil {
+		return 0, err
+	}
+	return reply.(int64), nil
+}
+
+func (r *redisBackend) Append(list string, value []byte, keep int) error {
+	if _, err := r.do("RPUSH", r.prefix+list, value); err != nil {
+		return err
+	}
+	if keep > 0 {
+		_, err := r.do("LTRIM", r.prefix+list, strconv.Itoa(-keep), "-1")
+		return err
+	}
+	return nil
+}
+
+func (r *redisBackend) Range(list string) ([][]byte, error) {
+	reply, err := r.do("LRANGE", r.prefix+list, "0", "-1")
+	if err != nil {
+		return nil, err
+	}
+
+	values := [][]byte{}
+	for _, value := range reply.([]interface{}) {
+		values = append(values, value.([]byte))
+	}
+	return values, nil
+}
+
+func (r *redisBackend) Pop(list string) ([]byte, bool, error) {
+	reply, err := r.do("LPOP", r.prefix+list)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	return reply.([]byte), true, nil
+}
+
+func (r *redisBackend) Len(list string) (int, error) {
+	reply, err := r.do("LLEN", r.prefix+list)
+	if err != nil {
+		return 0, err
+	}
+	return int(reply.(int64)), nil
+}
+
+// do sends a command on an idle connection, or a new one, and reads its reply. Bulk strings are returned as
+// []byte, integers as int64, arrays as []interface{} and a missing value as nil. A connection which fails is
+// closed rather than reused.
+func (r *redisBackend) do(args ...interface{}) (interface{}, error) {
+	connection, err := r.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := connection.command(args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		connection.conn.Close()
+		return nil, err
+	}
+
+	select {
+	case r.idle <- connection:
+	default:
+		connection.conn.Close()
+	}
+	return reply, err
+}
+
+func (r *redisBackend) connection() (*redisConnection, error) {
+	select {
+	case connection := <-r.idle:
+		return connection, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	var err error
+	if r.useTLS {
+		host, _, _ := net.SplitHostPort(r.address)
+		conn, err = tls.DialWithDialer(dialer, "tcp", r.address, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", r.address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis unreachable at %s: %v", r.address, err)
+	}
+
+	connection := &redisConnection{conn: conn, reader: bufio.NewReader(conn)}
+	if r.password != "" {
+		auth := []interface{}{"AUTH", r.password}
+		if r.username != "" {
+			auth = []interface{}{"AUTH", r.username, r.password}
+		}
+		if _, err := connection.command(auth...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.database != "" {
+		if _, err := connection.command("SELECT", r.database); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return connection, nil
+}
+
+func (c *redisConnection) command(args ...interface{}) (interface{}, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(redisTimeout)); err != nil {
+		return nil, err
+	}
+
+	var request strings.Builder
+	fmt.Fprintf(&request, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var value string
+		switch arg := arg.(type) {
+		case []byte:
+			value = string(arg)
+		default:
+			value = fmt.Sprint(arg)
+		}
+		fmt.Fprintf(&request, "$%d\r\n%s\r\n", len(value), value)
+	}
+	if _, err := io.WriteString(c.conn, request.String()); err != nil {
+		return nil, err
+	}
+
+	return c.readReply()
+}
+
+func (c *redisConnection) readReply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < 0 {
+			return nil, err
+		}
+		value := make([]byte, length+2)
+		if _, err := io.ReadFull(c.reader, value); err != nil {
+			return nil, err
+		}
+		return value[:length], nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, count)
+		for i := range values {
+			if values[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
+
+// escapeRedisPattern escapes the characters which SCAN MATCH treats as wildcards
+func escapeRedisPattern(pattern string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(pattern)
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	// modernc.org/sqlite is SQLite translated to Go, so it needs no cgo
+	_ "modernc.org/sqlite"
+)
+
+// sqliteBusyTimeout is how long a write waits for another launcher process sharing the file to finish its own
+const sqliteBusyTimeout = 5 * time.Second
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS state_values (
+	key TEXT PRIMARY KEY,
+	value BLOB NOT NULL,
+	expires_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS state_lists (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	list TEXT NOT NULL,
+	value BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS state_lists_list ON state_lists (list, id);
+`
+
+// sqliteBackend keeps the state in a SQLite file, values in one table with their expiry as Unix nanoseconds (zero
+// for none) and list values in another in the order they were appended
+type sqliteBackend struct {
+	db *sql.DB
+}
+
+func newSQLiteBackend(path string) (*sqliteBackend, error) {
+	if path == "" {
+		return nil, errors.New("SQLITE_PATH must be set when STATE_BACKEND is sqlite")
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database %s: %v", path, err)
+	}
+	// A single connection serialises the launcher's own writes, and keeps the busy timeout set on it
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA busy_timeout = %d", sqliteBusyTimeout.Milliseconds())); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open SQLite database %s: %v", path, err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create SQLite tables in %s: %v", path, err)
+	}
+
+	return &sqliteBackend{db: db}, nil
+}
+
+func (s *sqliteBackend) ping() error {
+	return s.db.Ping()
+}
+
+func (s *sqliteBackend) Get(key string) ([]byte, bool, error) {
+	var value []byte
+	err := s.db.QueryRow(
+		"SELECT value FROM state_values WHERE key = ? AND (expires_at = 0 OR expires_at > ?)", key, time.Now().UnixNano(),
+	).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s *sqliteBackend) Put(key string, value []byte, ttl time.Duration) error {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).UnixNano()
+	}
+	_, err := s.db.Exec(
+		"INSERT INTO state_values (key, value, expires_at) VALUES (?, ?, ?) "+
+			"ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at",
+		key, value, expiresAt,
+	)
+	return err
+}
+
+func (s *sqliteBackend) Delete(key string) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// An expired value is deleted but does not count as one
+	values, err := tx.Exec("DELETE FROM state_values WHERE key = ? AND (expires_at = 0 OR expires_at > ?)", key, time.Now().UnixNano())
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("DELETE FROM state_values WHERE key = ?", key); err != nil {
+		return false, err
+	}
+	listValues, err := tx.Exec("DELETE FROM state_lists WHERE list = ?", key)
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	deletedValues, _ := values.RowsAffected()
+	deletedListValues, _ := listValues.RowsAffected()
+	return deletedValues > 0 || deletedListValues > 0, nil
+}
+
+func (s *sqliteBackend) Keys(prefix string) ([]string, error) {
+	rows, err := s.db.Query(
+		"SELECT key FROM state_values WHERE substr(key, 1, ?) = ? AND (expires_at = 0 OR expires_at > ?) ORDER BY key",
+		len(prefix), prefix, time.Now().UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *sqliteBackend) Incr(key string) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var stored string
+	err = tx.QueryRow(
+		"SELECT CAST(value AS TEXT) FROM state_values WHERE key = ? AND (expires_at = 0 OR expires_at > ?)", key, time.Now().UnixNano(),
+	).Scan(&stored)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	number, _ := strconv.ParseInt(stored, 10, 64)
+	number++
+
+	_, err = tx.Exec(
+		"INSERT INTO state_values (key, value, expires_at) VALUES (?, ?, 0) "+
+			"ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = 0",
+		key, []byte(strconv.FormatInt(number, 10)),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return number, tx.Commit()
+}
+
+func (s *sqliteBackend) Append(list string, value []byte, keep int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("INSERT INTO state_lists (list, value) VALUES (?, ?)", list, value); err != nil {
+		return err
+	}
+	if keep > 0 {
+		_, err := tx.Exec(
+			"DELETE FROM state_lists WHERE list = ? AND id NOT IN (SELECT id FROM state_lists WHERE list = ? ORDER BY id DESC LIMIT ?)",
+			list, list, keep,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteBackend) Range(list string) ([][]byte, error) {
+	rows, err := s.db.Query("SELECT value FROM state_lists WHERE list = ? ORDER BY id", list)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := [][]byte{}
+	for rows.Next() {
+		var value []byte
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func (s *sqliteBackend) Pop(list string) ([]byte, bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var value []byte
+	err = tx.QueryRow("SELECT id, value FROM state_lists WHERE list = ? ORDER BY id LIMIT 1", list).Scan(&id, &value)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := tx.Exec("DELETE FROM state_lists WHERE id = ?", id); err != nil {
+		return nil, false, err
+	}
+	return value, true, tx.Commit()
+}
+
+func (s *sqliteBackend) Len(list string) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT count(*) FROM state_lists WHERE list = ?", list).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// Backend keeps the launcher's state: launch profiles, history, launch links and pooled tokens. Values are opaque
+// bytes under string keys, and lists are kept in the order values were appended. The in-memory backend is the
+// default, and a shared backend such as Redis or SQLite lets the state survive restarts and be shared by replicas.
+type Backend interface {
+	// Get returns the value under key, reporting whether there is one
+	Get(key string) ([]byte, bool, error)
+	// Put stores a value under key, which is forgotten after ttl unless ttl is zero
+	Put(key string, value []byte, ttl time.Duration) error
+	// Delete removes the value or list under key, reporting whether there was one
+	Delete(key string) (bool, error)
+	// Keys lists the keys of the values starting with prefix
+	Keys(prefix string) ([]string, error)
+	// Incr adds one to the number under key, which starts at zero, returning the new number
+	Incr(key string) (int64, error)
+	// Append adds a value to the end of a list, keeping only the last keep values unless keep is zero
+	Append(list string, value []byte, keep int) error
+	// Range returns the values of a list, oldest first
+	Range(list string) ([][]byte, error)
+	// Pop removes and returns the oldest value of a list, reporting whether there was one
+	Pop(list string) ([]byte, bool, error)
+	// Len is the number of values in a list
+	Len(list string) (int, error)
+}
+
+const (
+	// BackendMemory keeps the state in the launcher process, so it is lost on restart
+	BackendMemory = "memory"
+	// BackendRedis keeps the state in the Redis at REDIS_URL
+	BackendRedis = "redis"
+	// BackendSQLite keeps the state in the SQLite file at SQLITE_PATH
+	BackendSQLite = "sqlite"
+)
+
+var (
+	defaultBackend Backend
+	defaultOnce    sync.Once
+	defaultErr     error
+)
+
+// Default is the backend chosen by STATE_BACKEND, created on first use
+func Default() Backend {
+	defaultOnce.Do(func() {
+		defaultBackend, defaultErr = newBackend(settings.Get("STATE_BACKEND"))
+		if defaultErr != nil {
+			defaultBackend = newMemoryBackend()
+		}
+	})
+	return defaultBackend
+}
+
+// Shared reports whether the state is kept outside the launcher process, where it survives a restart and is seen
+// by every replica
+func Shared() bool {
+	_, memory := Default().(*memoryBackend)
+	return !memory
+}
+
+// Check reports a STATE_BACKEND which is not known, or a shared backend which cannot be reached
+func Check() error {
+	Default()
+	if defaultErr != nil {
+		return defaultErr
+	}
+	switch backend := defaultBackend.(type) {
+	case *redisBackend:
+		return backend.ping()
+	case *sqliteBackend:
+		return backend.ping()
+	}
+	return nil
+}
+
+func newBackend(name string) (Backend, error) {
+	switch name {
+	case "", BackendMemory:
+		return newMemoryBackend(), nil
+	case BackendRedis:
+		return newRedisBackend(settings.Get("REDIS_URL"), settings.Get("STATE_KEY_PREFIX"))
+	case BackendSQLite:
+		return newSQLiteBackend(settings.Get("SQLITE_PATH"))
+	default:
+		return nil, fmt.Errorf("unknown STATE_BACKEND %s, expected %s, %s or %s", name, BackendMemory, BackendRedis, BackendSQLite)
+	}
+}
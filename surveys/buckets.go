@@ -0,0 +1,285 @@
+package surveys
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/reload"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// schemaBucket is an S3 or GCS bucket, and the prefix within it, configured in SCHEMA_BUCKETS as
+// s3://bucket/prefix or gs://bucket/prefix
+type schemaBucket struct {
+	Scheme string
+	Bucket string
+	Prefix string
+}
+
+// bucketObject is a schema found in a bucket, named by its filename without .json
+type bucketObject struct {
+	Name   string
+	Key    string
+	Source schemaBucket
+}
+
+var (
+	cachedBucketObjects      []bucketObject
+	cachedBucketObjectsUntil time.Time
+	bucketObjectsMutex       sync.Mutex
+)
+
+func init() {
+	reload.Register("schema_buckets", clearBucketObjectsCache)
+}
+
+func schemaBuckets() []schemaBucket {
+	buckets := []schemaBucket{}
+	for _, source := range strings.Split(settings.Get("SCHEMA_BUCKETS"), ",") {
+		if source = strings.TrimSpace(source); source == "" {
+			continue
+		}
+
+		parsed, err := url.Parse(source)
+		if err != nil || (parsed.Scheme != "s3" && parsed.Scheme != "gs") || parsed.Host == "" {
+			logging.Error("Ignoring schema bucket, expected s3://bucket/prefix or gs://bucket/prefix", "source", source)
+			continue
+		}
+
+		buckets = append(buckets, schemaBucket{Scheme: parsed.Scheme, Bucket: parsed.Host, Prefix: strings.TrimPrefix(parsed.Path, "/")})
+	}
+	return buckets
+}
+
+// getAvailableSchemasFromBuckets lists the schemas in SCHEMA_BUCKETS. Their URLs are served by the launcher's
+// schema proxy when SCHEMA_BUCKET_PROXY_URL is set, and are the objects' own URLs otherwise.
+func getAvailableSchemasFromBuckets() []LauncherSchema {
+	schemaList := []LauncherSchema{}
+
+	objects, err := fetchBucketObjects()
+	if err != nil {
+		logging.Error("Failed to list schema buckets", "err", err)
+		return schemaList
+	}
+
+	proxyURL := strings.TrimSuffix(settings.Get("SCHEMA_BUCKET_PROXY_URL"), "/")
+	for _, object := range objects {
+		schemaURL := object.Source.objectURL(object.Key)
+		if proxyURL != "" {
+			schemaURL = proxyURL + "/bucket-schemas/" + url.PathEscape(object.Name)
+		}
+		schemaList = append(schemaList, LauncherSchema{Name: object.Name, URL: schemaURL})
+	}
+
+	return schemaList
+}
+
+// fetchBucketObjects lists the schemas of every bucket, caching a successful listing for SCHEMA_LIST_CACHE_SECONDS
+func fetchBucketObjects() ([]bucketObject, error) {
+	bucketObjectsMutex.Lock()
+	defer bucketObjectsMutex.Unlock()
+
+	if cachedBucketObjects != nil && time.Now().Before(cachedBucketObjectsUntil) {
+		return cachedBucketObjects, nil
+	}
+
+	objects := []bucketObject{}
+	for _, bucket := range schemaBuckets() {
+		keys, err := bucket.listKeys(context.Background())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			filename := path.Base(key)
+			if !strings.HasSuffix(filename, ".json") || !eqIDFormTypeRegex.MatchString(filename) {
+				continue
+			}
+			objects = append(objects, bucketObject{Name: strings.TrimSuffix(filename, ".json"), Key: key, Source: bucket})
+		}
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+
+	cachedBucketObjects = objects
+	cachedBucketObjectsUntil = time.Now().Add(schemaListCacheTTL())
+
+	return objects, nil
+}
+
+func clearBucketObjectsCache() error {
+	bucketObjectsMutex.Lock()
+	defer bucketObjectsMutex.Unlock()
+
+	cachedBucketObjects = nil
+	return nil
+}
+
+// FetchBucketSchema reads the schema JSON of the named bucket schema with the launcher's credentials, so that
+// the runner can load schemas from buckets which it cannot read itself
+func FetchBucketSchema(ctx context.Context, name string) ([]byte, bool, error) {
+	objects, err := fetchBucketObjects()
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, object := range objects {
+		if object.Name != name {
+			continue
+		}
+
+		objectURL := object.Source.objectURL(object.Key)
+		if object.Source.Scheme == "gs" {
+			objectURL = fmt.Sprintf("https://storage.googleapis.com/storage/v1/b/%s/o/%s?alt=media", object.Source.Bucket, url.PathEscape(object.Key))
+		}
+
+		body, err := object.Source.get(ctx, objectURL)
+		return body, true, err
+	}
+
+	return nil, false, nil
+}
+
+// objectURL is the URL an object is downloaded from without the launcher
+func (b schemaBucket) objectURL(key string) string {
+	escapedKey := (&url.URL{Path: key}).EscapedPath()
+	if b.Scheme == "gs" {
+		return fmt.Sprintf("https://storage.googleapis.com/%s/%s", b.Bucket, escapedKey)
+	}
+	return b.s3BaseURL() + "/" + escapedKey
+}
+
+// s3BaseURL is the virtual hosted bucket URL in AWS_REGION, or the path style URL at S3_ENDPOINT_URL for
+// S3 compatible stores such as MinIO or LocalStack
+func (b schemaBucket) s3BaseURL() string {
+	if endpoint := strings.TrimSuffix(settings.Get("S3_ENDPOINT_URL"), "/"); endpoint != "" {
+		return endpoint + "/" + b.Bucket
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com", b.Bucket, settings.Get("AWS_REGION"))
+}
+
+func (b schemaBucket) listKeys(ctx context.Context) ([]string, error) {
+	keys := []string{}
+	pageToken := ""
+
+	for {
+		var listURL string
+		if b.Scheme == "gs" {
+			query := url.Values{"prefix": {b.Prefix}, "fields": {"items/name,nextPageToken"}}
+			if pageToken != "" {
+				query.Set("pageToken", pageToken)
+			}
+			listURL = fmt.Sprintf("https://storage.googleapis.com/storage/v1/b/%s/o?%s", b.Bucket, query.Encode())
+		} else {
+			query := url.Values{"list-type": {"2"}, "prefix": {b.Prefix}}
+			if pageToken != "" {
+				query.Set("continuation-token", pageToken)
+			}
+			listURL = b.s3BaseURL() + "/?" + strings.ReplaceAll(query.Encode(), "+", "%20")
+		}
+
+		body, err := b.get(ctx, listURL)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageKeys []string
+		if b.Scheme == "gs" {
+			pageKeys, pageToken, err = parseGCSListing(body)
+		} else {
+			pageKeys, pageToken, err = parseS3Listing(body)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid listing of %s://%s: %v", b.Scheme, b.Bucket, err)
+		}
+
+		keys = append(keys, pageKeys...)
+		if pageToken == "" {
+			return keys, nil
+		}
+	}
+}
+
+func parseGCSListing(body []byte) ([]string, string, error) {
+	var listing struct {
+		Items []struct {
+			Name string `json:"name"`
+		} `json:"items"`
+		NextPageToken string `json:"nextPageToken"`
+	}
+	if err := json.Unmarshal(body, &listing); err != nil {
+		return nil, "", err
+	}
+
+	keys := []string{}
+	for _, item := range listing.Items {
+		keys = append(keys, item.Name)
+	}
+	return keys, listing.NextPageToken, nil
+}
+
+func parseS3Listing(body []byte) ([]string, string, error) {
+	var listing struct {
+		Contents []struct {
+			Key string `xml:"Key"`
+		} `xml:"Contents"`
+		IsTruncated           bool   `xml:"IsTruncated"`
+		NextContinuationToken string `xml:"NextContinuationToken"`
+	}
+	if err := xml.Unmarshal(body, &listing); err != nil {
+		return nil, "", err
+	}
+
+	keys := []string{}
+	for _, object := range listing.Contents {
+		keys = append(keys, object.Key)
+	}
+	if !listing.IsTruncated {
+		return keys, "", nil
+	}
+	return keys, listing.NextContinuationToken, nil
+}
+
+// get reads a bucket URL, authorised by GCS_ACCESS_TOKEN for GCS, or signed with AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY for S3. Requests are anonymous, for public buckets, when those are unset.
+func (b schemaBucket) get(ctx context.Context, bucketURL string) ([]byte, error) {
+	headers := http.Header{}
+	if b.Scheme == "gs" {
+		if token := settings.Get("GCS_ACCESS_TOKEN"); token != "" {
+			headers.Set("Authorization", "Bearer "+token)
+		}
+	} else if clients.HasAWSCredentials() {
+		var err error
+		if headers, err = clients.AWSSignatureHeaders("GET", bucketURL, nil, "s3", settings.Get("AWS_REGION"), time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := clients.GetWithHeaders(ctx, bucketURL, headers)
+	if err != nil {
+		return nil, fmt.Errorf("bucket unreachable at %s: %v", bucketURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("bucket returned %d for %s", resp.StatusCode, bucketURL)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", bucketURL, err)
+	}
+	return body, nil
+}
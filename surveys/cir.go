@@ -0,0 +1,79 @@
+package surveys
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// CIRInstrument is a questionnaire held by the Collection Instrument Registry (CIR), published or not
+type CIRInstrument struct {
+	ID            string `json:"id"`
+	SurveyID      string `json:"survey_id"`
+	FormType      string `json:"form_type"`
+	Language      string `json:"language"`
+	Title         string `json:"title"`
+	CIVersion     int    `json:"ci_version"`
+	SchemaVersion string `json:"schema_version"`
+	SchemaURL     string `json:"schema_url"`
+}
+
+func cirAPIURL() string {
+	return strings.TrimSuffix(settings.Get("CIR_API_URL"), "/")
+}
+
+// CIRSchemaURL is the URL of the schema JSON of the instrument with the given id
+func CIRSchemaURL(id string) string {
+	return cirAPIURL() + "/v1/retrieve_collection_instrument?" + url.Values{"guid": {id}}.Encode()
+}
+
+// CIRHeaders are the headers of a request to url, which carry CIR_API_TOKEN as a bearer token when url is
+// one of CIR_API_URL, so that schemas which are not yet published can be read
+func CIRHeaders(url string) http.Header {
+	headers := http.Header{}
+	if token := settings.Get("CIR_API_TOKEN"); token != "" && cirAPIURL() != "" && strings.HasPrefix(url, cirAPIURL()+"/") {
+		headers.Set("Authorization", "Bearer "+token)
+	}
+	return headers
+}
+
+// FetchCIRInstruments lists the instruments held by the CIR at CIR_API_URL, with the URLs of their schemas
+func FetchCIRInstruments(ctx context.Context) ([]CIRInstrument, error) {
+	if cirAPIURL() == "" {
+		return nil, fmt.Errorf("CIR_API_URL is not set")
+	}
+
+	url := cirAPIURL() + "/v1/ci_metadata"
+	resp, err := clients.GetWithHeaders(ctx, url, CIRHeaders(url))
+	if err != nil {
+		return nil, fmt.Errorf("collection instrument registry unreachable at %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("collection instrument registry returned %d for %s", resp.StatusCode, url)
+	}
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection instruments from %s: %v", url, err)
+	}
+
+	instruments := []CIRInstrument{}
+	if err := json.Unmarshal(responseBody, &instruments); err != nil {
+		return nil, fmt.Errorf("invalid collection instruments from %s: %v", url, err)
+	}
+
+	for i := range instruments {
+		instruments[i].SchemaURL = CIRSchemaURL(instruments[i].ID)
+	}
+
+	return instruments, nil
+}
@@ -0,0 +1,92 @@
+package surveys
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/metrics"
+	"github.com/ONSdigital/eq-questionnaire-launcher/reload"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+var (
+	cachedSchemaList      []string
+	cachedSchemaListUntil time.Time
+	schemaListMutex       sync.Mutex
+)
+
+func init() {
+	reload.Register("schema_list", clearSchemaListCache)
+}
+
+// FetchSchemaList returns the names of the schemas served by the runner at SURVEY_RUNNER_SCHEMA_URL.
+//
+// A successful response is cached for SCHEMA_LIST_CACHE_SECONDS, failures are not cached.
+func FetchSchemaList() ([]string, error) {
+	schemaListMutex.Lock()
+	defer schemaListMutex.Unlock()
+
+	if cachedSchemaList != nil && time.Now().Before(cachedSchemaListUntil) {
+		return cachedSchemaList, nil
+	}
+
+	start := time.Now()
+	schemaList, err := fetchSchemaListFromRunner()
+	metrics.ObserveSchemaListFetch(start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	cachedSchemaList = schemaList
+	cachedSchemaListUntil = time.Now().Add(schemaListCacheTTL())
+
+	return schemaList, nil
+}
+
+func fetchSchemaListFromRunner() ([]string, error) {
+	url := fmt.Sprintf("%s/schemas", settings.Get("SURVEY_RUNNER_SCHEMA_URL"))
+
+	resp, err := clients.GetHTTPClient().Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("survey runner unreachable at %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("survey runner returned %d for %s", resp.StatusCode, url)
+	}
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema list from %s: %v", url, err)
+	}
+
+	schemaList := []string{}
+	if err := json.Unmarshal(responseBody, &schemaList); err != nil {
+		return nil, fmt.Errorf("invalid schema list from %s: %v", url, err)
+	}
+
+	return schemaList, nil
+}
+
+func schemaListCacheTTL() time.Duration {
+	seconds, err := strconv.Atoi(settings.Get("SCHEMA_LIST_CACHE_SECONDS"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// clearSchemaListCache forces the next FetchSchemaList to ask the runner again
+func clearSchemaListCache() error {
+	schemaListMutex.Lock()
+	defer schemaListMutex.Unlock()
+
+	cachedSchemaList = nil
+	return nil
+}
@@ -2,31 +2,33 @@ package surveys
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"regexp"
 
-	"fmt"
 	"io/ioutil"
 	"sort"
 	"strings"
 
 	"github.com/AreaHQ/jsonhal"
 	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
 	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
 )
 
 // LauncherSchema is a representation of a schema in the Launcher
 type LauncherSchema struct {
-	Name string
-	URL  string
+	Name string `json:"name"`
+	URL  string `json:"url"`
 }
 
 // LauncherSchemas is a separation of Test and Live schemas
 type LauncherSchemas struct {
-	Business []LauncherSchema
-	Social   []LauncherSchema
-	Test     []LauncherSchema
-	Other    []LauncherSchema
+	Business []LauncherSchema `json:"business"`
+	Social   []LauncherSchema `json:"social"`
+	Test     []LauncherSchema `json:"test"`
+	Other    []LauncherSchema `json:"other"`
+	Bucket   []LauncherSchema `json:"bucket"`
 }
 
 // RegisterResponse is the response from the eq-survey-register request
@@ -43,7 +45,17 @@ type Schema struct {
 	Name string `json:"name"`
 }
 
-var eqIDFormTypeRegex = regexp.MustCompile(`^(?P<eq_id>[a-z0-9]+)_(?P<form_type>\w+)`)
+var eqIDFormTypeRegex = regexp.MustCompile(`^(?P<eq_id>[A-Za-z0-9-]+)_(?P<form_type>[\w-]+?)(?:\.json)?$`)
+
+// ExtractEqIDFormType splits a schema name or filename such as mbs_0106.json into its eq_id and form_type
+func ExtractEqIDFormType(schema string) (eqID string, formType string, err error) {
+	match := eqIDFormTypeRegex.FindStringSubmatch(schema)
+	if match == nil {
+		return "", "", fmt.Errorf("schema %q is not of the form <eq_id>_<form_type>", schema)
+	}
+
+	return match[eqIDFormTypeRegex.SubexpIndex("eq_id")], match[eqIDFormTypeRegex.SubexpIndex("form_type")], nil
+}
 
 // LauncherSchemaFromFilename creates a LauncherSchema record from a schema filename
 func LauncherSchemaFromFilename(filename string) LauncherSchema {
@@ -52,7 +64,8 @@ func LauncherSchemaFromFilename(filename string) LauncherSchema {
 	}
 }
 
-// GetAvailableSchemas Gets the list of static schemas an joins them with any schemas from the eq-survey-register if defined
+// GetAvailableSchemas Gets the list of static schemas an joins them with any schemas from the eq-survey-register
+// and the SCHEMA_BUCKETS if defined
 func GetAvailableSchemas() LauncherSchemas {
 	schemaList := LauncherSchemas{}
 
@@ -61,14 +74,15 @@ func GetAvailableSchemas() LauncherSchemas {
 	for _, launcherSchema := range runnerSchemas {
 		if strings.HasPrefix(launcherSchema.Name, "test_") {
 			schemaList.Test = append(schemaList.Test, launcherSchema)
-		}  else if strings.HasPrefix(launcherSchema.Name, "lms_") {
+		} else if strings.HasPrefix(launcherSchema.Name, "lms_") {
 			schemaList.Social = append(schemaList.Social, launcherSchema)
-		}  else {
+		} else {
 			schemaList.Business = append(schemaList.Business, launcherSchema)
 		}
 	}
 
 	schemaList.Other = getAvailableSchemasFromRegister()
+	schemaList.Bucket = getAvailableSchemasFromBuckets()
 
 	sort.Sort(ByFilename(schemaList.Business))
 	sort.Sort(ByFilename(schemaList.Social))
@@ -103,7 +117,7 @@ func getAvailableSchemasFromRegister() []LauncherSchema {
 
 		var registerResponse RegisterResponse
 		if err := json.Unmarshal(responseBody, &registerResponse); err != nil {
-			log.Print(err)
+			logging.Error("Invalid survey register response", "err", err)
 			return schemaList
 		}
 
@@ -112,7 +126,7 @@ func getAvailableSchemasFromRegister() []LauncherSchema {
 		schemasJSON, _ := json.Marshal(registerResponse.Embedded["schemas"])
 
 		if err := json.Unmarshal(schemasJSON, &schemas); err != nil {
-			log.Println(err)
+			logging.Error("Invalid schemas in survey register response", "err", err)
 		}
 
 		for _, schema := range schemas {
@@ -131,64 +145,41 @@ func getAvailableSchemasFromRunner() []LauncherSchema {
 
 	schemaList := []LauncherSchema{}
 
-	hostURL := settings.Get("SURVEY_RUNNER_SCHEMA_URL")
-
-	log.Printf("Survey Runner Schema URL: %s", hostURL)
+	logging.Debug("Loading schemas from survey runner", "url", settings.Get("SURVEY_RUNNER_SCHEMA_URL"))
 
-	url := fmt.Sprintf("%s/schemas", hostURL)
-
-	resp, err := clients.GetHTTPClient().Get(url)
+	schemaNames, err := FetchSchemaList()
 	if err != nil {
+		logging.Error("Failed to fetch schema list", "err", err)
 		return []LauncherSchema{}
 	}
 
-	if resp.StatusCode != 200 {
-		return []LauncherSchema{}
-	}
-
-	responseBody, err := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		return []LauncherSchema{}
-	}
-
-	var schemaListResponse []string
-
-	if err := json.Unmarshal(responseBody, &schemaListResponse); err != nil {
-		log.Print(err)
-		return []LauncherSchema{}
-	}
-
-	for _, schema := range schemaListResponse {
+	for _, schema := range schemaNames {
 		schemaList = append(schemaList, LauncherSchemaFromFilename(schema))
 	}
 
 	return schemaList
 }
 
-// FindSurveyByName Finds the schema in the list of available schemas
-func FindSurveyByName(name string) LauncherSchema {
+// FindSurveyByName Finds the schema in the list of available schemas, which is an error when the runner does not
+// list it, including when the list could not be fetched
+func FindSurveyByName(name string) (LauncherSchema, error) {
+	survey, ok := LookupSurveyByName(name)
+	if !ok {
+		return LauncherSchema{}, fmt.Errorf("Survey not found: %s", name)
+	}
+	return survey, nil
+}
+
+// LookupSurveyByName finds the schema in the list of available schemas, reporting whether it was found
+func LookupSurveyByName(name string) (LauncherSchema, bool) {
 	availableSchemas := GetAvailableSchemas()
 
-	for _, survey := range availableSchemas.Business {
-		if survey.Name == name {
-			return survey
-		}
-	}
-	for _, survey := range availableSchemas.Social {
-		if survey.Name == name {
-			return survey
-		}
-	}
-	for _, survey := range availableSchemas.Test {
-		if survey.Name == name {
-			return survey
-		}
-	}
-	for _, survey := range availableSchemas.Other {
-		if survey.Name == name {
-			return survey
+	for _, group := range [][]LauncherSchema{availableSchemas.Business, availableSchemas.Social, availableSchemas.Test, availableSchemas.Other, availableSchemas.Bucket} {
+		for _, survey := range group {
+			if survey.Name == name {
+				return survey, true
+			}
 		}
 	}
-	panic("Survey not found")
+	return LauncherSchema{}, false
 }
@@ -0,0 +1,122 @@
+package surveys
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// ErrUploadedSchemaNotFound is returned when reading a schema which has not been uploaded
+var ErrUploadedSchemaNotFound = errors.New("uploaded schema not found")
+
+// uploadedSchemaNameRegex limits names to those which are safe as both a filename and a URL path segment
+var uploadedSchemaNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var (
+	uploadedSchemas      = make(map[string][]byte)
+	uploadedSchemasMutex sync.Mutex
+)
+
+// UploadedSchemaName is the name an uploaded file is stored under, its filename without .json
+func UploadedSchemaName(filename string) string {
+	return strings.TrimSuffix(filepath.Base(filename), ".json")
+}
+
+// SaveUploadedSchema stores a schema under the name, replacing any schema of that name. It is kept in the
+// SCHEMA_UPLOADS_PATH directory when that is set, and in memory otherwise.
+func SaveUploadedSchema(name string, schema []byte) error {
+	if !uploadedSchemaNameRegex.MatchString(name) {
+		return fmt.Errorf("schema name may only have letters, digits, _ and -: %s", name)
+	}
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		return fmt.Errorf("schema must be a JSON object: %v", err)
+	}
+
+	uploadedSchemasMutex.Lock()
+	defer uploadedSchemasMutex.Unlock()
+
+	dir := settings.Get("SCHEMA_UPLOADS_PATH")
+	if dir == "" {
+		uploadedSchemas[name] = schema
+		return nil
+	}
+
+	file, err := ioutil.TempFile(dir, name+".json.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(schema); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), filepath.Join(dir, name+".json"))
+}
+
+// LoadUploadedSchema returns the schema JSON uploaded under the name
+func LoadUploadedSchema(name string) ([]byte, error) {
+	if !uploadedSchemaNameRegex.MatchString(name) {
+		return nil, ErrUploadedSchemaNotFound
+	}
+
+	uploadedSchemasMutex.Lock()
+	defer uploadedSchemasMutex.Unlock()
+
+	dir := settings.Get("SCHEMA_UPLOADS_PATH")
+	if dir == "" {
+		schema, ok := uploadedSchemas[name]
+		if !ok {
+			return nil, ErrUploadedSchemaNotFound
+		}
+		return schema, nil
+	}
+
+	schema, err := ioutil.ReadFile(filepath.Join(dir, name+".json"))
+	if os.IsNotExist(err) {
+		return nil, ErrUploadedSchemaNotFound
+	}
+	return schema, err
+}
+
+// ListUploadedSchemas returns the sorted names of the uploaded schemas
+func ListUploadedSchemas() ([]string, error) {
+	uploadedSchemasMutex.Lock()
+	defer uploadedSchemasMutex.Unlock()
+
+	names := []string{}
+	dir := settings.Get("SCHEMA_UPLOADS_PATH")
+	if dir == "" {
+		for name := range uploadedSchemas {
+			names = append(names, name)
+		}
+	} else {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if name := strings.TrimSuffix(file.Name(), ".json"); !file.IsDir() && strings.HasSuffix(file.Name(), ".json") && uploadedSchemaNameRegex.MatchString(name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
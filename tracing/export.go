@@ -0,0 +1,248 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/outbound"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+const defaultOTLPEndpoint = "http://localhost:4318"
+
+var (
+	exportOnce  sync.Once
+	exportQueue chan *Span
+	exportDone  chan struct{}
+	flushes     chan chan struct{}
+)
+
+// exportClient sends spans through the proxies and CA bundle of every outbound request, but without the tracing
+// transport, so that exports are not themselves traced
+var exportClient = &http.Client{Timeout: 10 * time.Second, Transport: outbound.Transport()}
+
+func intSetting(name string, defaultValue int) int {
+	value, err := strconv.Atoi(settings.Get(name))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// queueSpan hands an ended span to the exporter, dropping it when OTEL_BSP_MAX_QUEUE_SIZE spans are waiting
+func queueSpan(span *Span) {
+	exportOnce.Do(startExporter)
+	select {
+	case exportQueue <- span:
+	default:
+		logging.Warn("Dropping span, the export queue is full", "span", span.name)
+	}
+}
+
+// startExporter sends queued spans in batches of up to OTEL_BSP_MAX_EXPORT_BATCH_SIZE, at least every
+// OTEL_BSP_SCHEDULE_DELAY milliseconds
+func startExporter() {
+	exportQueue = make(chan *Span, intSetting("OTEL_BSP_MAX_QUEUE_SIZE", 2048))
+	exportDone = make(chan struct{})
+	flushes = make(chan chan struct{})
+
+	batchSize := intSetting("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", 512)
+	ticker := time.NewTicker(time.Duration(intSetting("OTEL_BSP_SCHEDULE_DELAY", 5000)) * time.Millisecond)
+
+	go func() {
+		defer close(exportDone)
+		defer ticker.Stop()
+
+		batch := []*Span{}
+		send := func() {
+			if len(batch) > 0 {
+				exportSpans(batch)
+				batch = []*Span{}
+			}
+		}
+
+		for {
+			select {
+			case span := <-exportQueue:
+				batch = append(batch, span)
+				if len(batch) >= batchSize {
+					send()
+				}
+			case <-ticker.C:
+				send()
+			case flushed := <-flushes:
+				for drained := false; !drained; {
+					select {
+					case span := <-exportQueue:
+						batch = append(batch, span)
+					default:
+						drained = true
+					}
+				}
+				send()
+				close(flushed)
+			}
+		}
+	}()
+}
+
+// Shutdown exports the spans which are waiting, giving up when ctx is done
+func Shutdown(ctx context.Context) {
+	if exportQueue == nil {
+		return
+	}
+
+	flushed := make(chan struct{})
+	select {
+	case flushes <- flushed:
+	case <-ctx.Done():
+		return
+	}
+
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+		logging.Warn("Spans were not exported before shutdown")
+	}
+}
+
+// tracesEndpoint is OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or /v1/traces under OTEL_EXPORTER_OTLP_ENDPOINT
+func tracesEndpoint() string {
+	if endpoint := settings.Get("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+
+	endpoint := settings.Get("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if endpoint == "" {
+		endpoint = defaultOTLPEndpoint
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+}
+
+// keyValueList parses the comma separated key=value pairs of OTEL_EXPORTER_OTLP_HEADERS and
+// OTEL_RESOURCE_ATTRIBUTES, whose values may be percent encoded
+func keyValueList(list string) map[string]string {
+	pairs := map[string]string{}
+	for _, pair := range strings.Split(list, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			continue
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(parts[1]))
+		if err != nil {
+			value = strings.TrimSpace(parts[1])
+		}
+		pairs[strings.TrimSpace(parts[0])] = value
+	}
+	return pairs
+}
+
+func resourceAttributes() map[string]interface{} {
+	attributes := map[string]interface{}{"service.name": "eq-questionnaire-launcher"}
+	for key, value := range keyValueList(settings.Get("OTEL_RESOURCE_ATTRIBUTES")) {
+		attributes[key] = value
+	}
+	if serviceName := settings.Get("OTEL_SERVICE_NAME"); serviceName != "" {
+		attributes["service.name"] = serviceName
+	}
+	return attributes
+}
+
+// otlpAttributes are attributes in the OTLP JSON encoding, where integers are strings
+func otlpAttributes(attributes map[string]interface{}) []map[string]interface{} {
+	list := []map[string]interface{}{}
+	for key, value := range attributes {
+		var encoded map[string]interface{}
+		switch typed := value.(type) {
+		case bool:
+			encoded = map[string]interface{}{"boolValue": typed}
+		case int:
+			encoded = map[string]interface{}{"intValue": strconv.Itoa(typed)}
+		case int64:
+			encoded = map[string]interface{}{"intValue": strconv.FormatInt(typed, 10)}
+		default:
+			encoded = map[string]interface{}{"stringValue": fmt.Sprint(typed)}
+		}
+		list = append(list, map[string]interface{}{"key": key, "value": encoded})
+	}
+	return list
+}
+
+func otlpSpan(span *Span) map[string]interface{} {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+
+	encoded := map[string]interface{}{
+		"traceId":           hex.EncodeToString(span.traceID[:]),
+		"spanId":            hex.EncodeToString(span.spanID[:]),
+		"name":              span.name,
+		"kind":              int(span.kind),
+		"startTimeUnixNano": strconv.FormatInt(span.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(span.end.UnixNano(), 10),
+		"attributes":        otlpAttributes(span.attributes),
+	}
+	if span.parentID != (spanID{}) {
+		encoded["parentSpanId"] = hex.EncodeToString(span.parentID[:])
+	}
+	if span.errMessage != "" {
+		encoded["status"] = map[string]interface{}{"code": 2, "message": span.errMessage}
+	}
+	return encoded
+}
+
+// exportSpans posts the spans to the OTLP/HTTP traces endpoint in the OTLP JSON encoding
+func exportSpans(spans []*Span) {
+	encodedSpans := []map[string]interface{}{}
+	for _, span := range spans {
+		encodedSpans = append(encodedSpans, otlpSpan(span))
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": otlpAttributes(resourceAttributes())},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "github.com/ONSdigital/eq-questionnaire-launcher"},
+				"spans": encodedSpans,
+			}},
+		}},
+	})
+	if err != nil {
+		logging.Error("Failed to encode spans", "err", err)
+		return
+	}
+
+	endpoint := tracesEndpoint()
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		logging.Error("Invalid OTLP traces endpoint", "endpoint", endpoint, "err", err)
+		return
+	}
+	for name, value := range keyValueList(settings.Get("OTEL_EXPORTER_OTLP_HEADERS")) {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := exportClient.Do(req)
+	if err != nil {
+		logging.Error("Failed to export spans", "endpoint", endpoint, "spans", len(spans), "err", err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		logging.Error("OTLP endpoint rejected spans", "endpoint", endpoint, "spans", len(spans), "status", resp.StatusCode)
+	}
+}
@@ -0,0 +1,215 @@
+// Package tracing records OpenTelemetry spans of launches and exports them over OTLP/HTTP, configured by the
+// standard OTEL_* environment variables. Trace context is propagated in W3C traceparent headers, so spans join
+// the traces of callers and of the runner.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// Kind is the OpenTelemetry kind of a span
+type Kind int
+
+// The kinds of span, numbered as in OTLP
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+type traceID [16]byte
+type spanID [8]byte
+
+// Span is an operation within a trace. A nil *Span, as returned when tracing is disabled, ignores every call.
+type Span struct {
+	name     string
+	kind     Kind
+	traceID  traceID
+	spanID   spanID
+	parentID spanID
+	sampled  bool
+	start    time.Time
+
+	mutex      sync.Mutex
+	end        time.Time
+	attributes map[string]interface{}
+	errMessage string
+	ended      bool
+}
+
+type spanContextKey struct{}
+
+// remoteParent is the trace context of a caller, from its traceparent header
+type remoteParent struct {
+	traceID traceID
+	spanID  spanID
+	sampled bool
+}
+
+type remoteParentKey struct{}
+
+// Enabled reports whether spans are recorded, which is when OTEL_TRACES_EXPORTER is otlp, or is unset and an
+// OTLP endpoint is set, and OTEL_SDK_DISABLED is not true
+func Enabled() bool {
+	if settings.Get("OTEL_SDK_DISABLED") == "true" {
+		return false
+	}
+	switch settings.Get("OTEL_TRACES_EXPORTER") {
+	case "otlp":
+		return true
+	case "":
+		return settings.Get("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || settings.Get("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+	}
+	return false
+}
+
+// Start begins a span as a child of the span in ctx, or of the remote caller, or as the root of a new trace,
+// returning a context which carries it
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	if !Enabled() {
+		return ctx, nil
+	}
+
+	span := &Span{name: name, kind: kind, start: time.Now(), attributes: map[string]interface{}{}}
+	rand.Read(span.spanID[:])
+
+	if parent := FromContext(ctx); parent != nil {
+		span.traceID, span.parentID, span.sampled = parent.traceID, parent.spanID, parent.sampled
+	} else if remote, ok := ctx.Value(remoteParentKey{}).(remoteParent); ok {
+		span.traceID, span.parentID = remote.traceID, remote.spanID
+		span.sampled = sample(span.traceID, &remote)
+	} else {
+		rand.Read(span.traceID[:])
+		span.sampled = sample(span.traceID, nil)
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// FromContext is the span carried by ctx, or nil
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// SetAttribute records a string, bool or integer attribute of the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed with the message
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.errMessage = message
+}
+
+// End completes the span and queues it for export when it is sampled
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mutex.Unlock()
+
+	if s.sampled {
+		queueSpan(s)
+	}
+}
+
+// TraceID is the hex trace ID of the span, or empty for a nil span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// Extract returns a context carrying the trace context of the request's traceparent header, so that spans
+// started with it join the caller's trace
+func Extract(ctx context.Context, header http.Header) context.Context {
+	parts := strings.Split(strings.TrimSpace(header.Get("traceparent")), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return ctx
+	}
+
+	var remote remoteParent
+	traceBytes, traceErr := hex.DecodeString(parts[1])
+	spanBytes, spanErr := hex.DecodeString(parts[2])
+	flags, flagsErr := strconv.ParseUint(parts[3], 16, 8)
+	if traceErr != nil || spanErr != nil || flagsErr != nil || len(traceBytes) != 16 || len(spanBytes) != 8 {
+		return ctx
+	}
+	copy(remote.traceID[:], traceBytes)
+	copy(remote.spanID[:], spanBytes)
+	if remote.traceID == (traceID{}) || remote.spanID == (spanID{}) {
+		return ctx
+	}
+	remote.sampled = flags&1 == 1
+
+	return context.WithValue(ctx, remoteParentKey{}, remote)
+}
+
+// Inject sets the traceparent header of an outgoing request to the span in ctx
+func Inject(ctx context.Context, header http.Header) {
+	span := FromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	flags := "00"
+	if span.sampled {
+		flags = "01"
+	}
+	header.Set("traceparent", fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(span.traceID[:]), hex.EncodeToString(span.spanID[:]), flags))
+}
+
+// sample decides whether a new trace, or one joined from a remote parent, is recorded according to
+// OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG
+func sample(id traceID, remote *remoteParent) bool {
+	sampler := settings.Get("OTEL_TRACES_SAMPLER")
+	if strings.HasPrefix(sampler, "parentbased_") {
+		if remote != nil {
+			return remote.sampled
+		}
+		sampler = strings.TrimPrefix(sampler, "parentbased_")
+	}
+
+	switch sampler {
+	case "always_off":
+		return false
+	case "traceidratio":
+		ratio, err := strconv.ParseFloat(settings.Get("OTEL_TRACES_SAMPLER_ARG"), 64)
+		if err != nil {
+			ratio = 1
+		}
+		// as in the OpenTelemetry SDKs, the low 8 bytes of the trace ID are compared against the ratio
+		return binary.BigEndian.Uint64(id[8:])>>1 < uint64(ratio*(1<<63))
+	}
+	return true
+}
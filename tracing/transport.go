@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"net/http"
+)
+
+type transport struct {
+	next http.RoundTripper
+}
+
+// Transport wraps an HTTP transport so that each request made within a span is recorded as a client span and
+// carries its trace context to the server
+func Transport(next http.RoundTripper) http.RoundTripper {
+	return &transport{next: next}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if FromContext(req.Context()) == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx, span := Start(req.Context(), "HTTP "+req.Method, KindClient)
+	defer span.End()
+	span.SetAttribute("http.request.method", req.Method)
+	span.SetAttribute("server.address", req.URL.Hostname())
+	span.SetAttribute("url.path", req.URL.Path)
+
+	// the request is cloned rather than changed, as a RoundTripper must not modify it
+	traced := req.Clone(ctx)
+	Inject(ctx, traced.Header)
+
+	resp, err := t.next.RoundTrip(traced)
+	if err != nil {
+		span.SetError(err.Error())
+		return nil, err
+	}
+
+	span.SetAttribute("http.response.status_code", resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		span.SetError(http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
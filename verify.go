@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+)
+
+// maxVerifyRedirects caps the redirects followed from the runner's /session, as a browser would
+const maxVerifyRedirects = 10
+
+// launchVerification is what the runner made of a launch, found by following its response to the token as a
+// browser would. The body is reported only when the runner responds with an error.
+type launchVerification struct {
+	Succeeded      bool     `json:"succeeded"`
+	RunnerStatus   int      `json:"runner_status"`
+	FinalURL       string   `json:"final_url"`
+	Redirects      []string `json:"redirects"`
+	RunnerResponse string   `json:"runner_response,omitempty"`
+}
+
+// verifyLaunch opens the runner session URL of a token server-side, keeping the runner's cookies and following
+// its redirects to the page the respondent would land on
+func verifyLaunch(ctx context.Context, sessionURL string) (*launchVerification, error) {
+	_, verification, err := openRunnerSession(ctx, sessionURL)
+	return verification, err
+}
+
+// openRunnerSession opens a runner session like verifyLaunch, also returning a client which holds the session's
+// cookies for further requests to the runner
+func openRunnerSession(ctx context.Context, sessionURL string) (*http.Client, *launchVerification, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	verification := &launchVerification{Redirects: []string{}}
+
+	client := *clients.GetHTTPClient()
+	client.Jar = jar
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxVerifyRedirects {
+			return errors.New("stopped after too many redirects")
+		}
+		verification.Redirects = append(verification.Redirects, req.URL.Path)
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", sessionURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	client.CheckRedirect = nil
+
+	verification.RunnerStatus = resp.StatusCode
+	verification.FinalURL = resp.Request.URL.Scheme + "://" + resp.Request.URL.Host + resp.Request.URL.Path
+	verification.Succeeded = resp.StatusCode < 400
+
+	if !verification.Succeeded {
+		responseBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxFlushResponseBytes))
+		verification.RunnerResponse = string(responseBody)
+	}
+
+	logging.Info("Runner session opened", "status", resp.StatusCode, "final_url", verification.FinalURL, "redirects", len(verification.Redirects))
+	return &client, verification, nil
+}
@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/eq-questionnaire-launcher/clients"
+	"github.com/ONSdigital/eq-questionnaire-launcher/logging"
+	"github.com/ONSdigital/eq-questionnaire-launcher/metrics"
+	"github.com/ONSdigital/eq-questionnaire-launcher/settings"
+)
+
+// queueSize bounds the events waiting to be sent, so a slow or unreachable receiver cannot hold up launches
+const queueSize = 256
+
+// summaryClaims are the claims copied into an event, which identify the respondent and the questionnaire.
+// v2 claims nest some of them under survey_metadata.data.
+var summaryClaims = []string{"version", "schema_name", "schema_url", "collection_exercise_sid", "case_id",
+	"response_id", "user_id", "ru_ref", "ru_name", "period_id", "form_type", "survey_id", "language_code",
+	"region_code", "channel", "roles"}
+
+// Event is a launch as sent to WEBHOOK_URL
+type Event struct {
+	Event      string                 `json:"event"`
+	Time       time.Time              `json:"time"`
+	TxID       string                 `json:"tx_id"`
+	SchemaName string                 `json:"schema_name"`
+	SchemaURL  string                 `json:"schema_url,omitempty"`
+	Outcome    string                 `json:"outcome"`
+	Succeeded  bool                   `json:"succeeded"`
+	Claims     map[string]interface{} `json:"claims,omitempty"`
+}
+
+var (
+	queue     chan Event
+	startOnce sync.Once
+)
+
+// Enabled reports whether WEBHOOK_URL is set
+func Enabled() bool {
+	return settings.Get("WEBHOOK_URL") != ""
+}
+
+// NotifyLaunch queues a launch event for WEBHOOK_URL with a summary of the claims the token was made with,
+// which are nil for launches that failed before a token was made. Events are sent in order by a single
+// sender and dropped when the queue is full.
+func NotifyLaunch(values url.Values, txID string, outcome string, succeeded bool, claims map[string]interface{}) {
+	if !Enabled() {
+		return
+	}
+
+	startOnce.Do(func() {
+		queue = make(chan Event, queueSize)
+		go send()
+	})
+
+	event := Event{
+		Event:      "launch",
+		Time:       time.Now().UTC(),
+		TxID:       txID,
+		SchemaName: values.Get("schema_name"),
+		SchemaURL:  values.Get("schema_url"),
+		Outcome:    outcome,
+		Succeeded:  succeeded,
+		Claims:     summariseClaims(claims),
+	}
+
+	select {
+	case queue <- event:
+	default:
+		metrics.WebhookDelivered("dropped")
+		logging.Warn("Webhook queue full, dropping launch event", "tx_id", txID)
+	}
+}
+
+func summariseClaims(claims map[string]interface{}) map[string]interface{} {
+	if claims == nil {
+		return nil
+	}
+
+	nested := map[string]interface{}{}
+	if surveyMetadata, ok := claims["survey_metadata"].(map[string]interface{}); ok {
+		if data, ok := surveyMetadata["data"].(map[string]interface{}); ok {
+			nested = data
+		}
+	}
+
+	summary := make(map[string]interface{})
+	for _, name := range summaryClaims {
+		if value, ok := claims[name]; ok {
+			summary[name] = value
+		} else if value, ok := nested[name]; ok {
+			summary[name] = value
+		}
+	}
+	return summary
+}
+
+func send() {
+	for event := range queue {
+		if err := post(event); err != nil {
+			metrics.WebhookDelivered("failure")
+			logging.Warn("Failed to send launch webhook", "url", settings.Get("WEBHOOK_URL"), "tx_id", event.TxID, "err", err)
+			continue
+		}
+		metrics.WebhookDelivered("success")
+	}
+}
+
+// post sends an event, signed with an X-Launcher-Signature of sha256= and the hex HMAC-SHA256 of the body
+// with WEBHOOK_SECRET when that is set, so receivers can check it came from the launcher
+func post(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	if secret := settings.Get("WEBHOOK_SECRET"); secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		headers.Set("X-Launcher-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := clients.PostWithHeaders(context.Background(), settings.Get("WEBHOOK_URL"), headers, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %d", resp.StatusCode)
+	}
+	return nil
+}